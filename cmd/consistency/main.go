@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	migration "friend_connection_rest_api/migrations"
+	friendshipService "friend_connection_rest_api/services/friendship"
+	"friend_connection_rest_api/utils"
+)
+
+// Scan friendships and print the anomalies found, they are repaired only with -apply.
+// Exit status is 1 when anomalies are left unrepaired.
+func main() {
+	apply := flag.Bool("apply", false, "repair the anomalies, by default only report them")
+	flag.Parse()
+
+	db := utils.CreateConnection()
+	if err := migration.InitMigration(db); err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := friendshipService.NewConsistencyManager(db).CheckConsistency(context.Background(), *apply)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(report.Anomalies) > 0 && report.Applied == false {
+		os.Exit(1)
+	}
+}
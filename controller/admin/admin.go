@@ -0,0 +1,5 @@
+package admin
+
+type RequestCheckConsistency struct {
+	Apply bool `form:"apply"`
+}
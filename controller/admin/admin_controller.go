@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	httpRes "friend_connection_rest_api/controller/common_respone"
+	"friend_connection_rest_api/services/friendship"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdminToken allow only requests with the X-Admin-Token header equal to token,
+// admin endpoints are disabled when token is empty
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusNotFound, httpRes.HTTPError{Message: "Admin Endpoints Disabled"})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, httpRes.HTTPError{Message: "Permission Denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckConsistencyController godoc
+// @Summary Check friendships consistency
+// @Description Report anomalies of friendships, they are repaired when apply is true
+// @Tags Admin
+// @Param X-Admin-Token header string true "Admin token"
+// @Param apply query bool false "Repair the anomalies"
+// @Produce  json
+// @Success 200 {object} friendship.ConsistencyReport
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Failure 500 {object} httpRes.HTTPError
+// @Router /admin/consistency [post]
+func CheckConsistencyController(c *gin.Context, service friendship.ConsistencyServices) {
+	var req RequestCheckConsistency
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Request Invalid"})
+		return
+	}
+
+	rs, err := service.CheckConsistency(c.Request.Context(), req.Apply)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rs)
+}
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"friend_connection_rest_api/services/friendship"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckConsistencyController(t *testing.T) {
+	// Given
+	report := friendship.ConsistencyReport{
+		Scanned:   3,
+		Anomalies: []friendship.Anomaly{{Kind: friendship.AnomalySelfEdge, Count: 1, Samples: []friendship.FriendshipSample{{ID: 1, FirstUser: "a@gmail.com", SecondUser: "a@gmail.com"}}}},
+	}
+	testCase := []struct {
+		scenario       string
+		adminToken     string
+		header         string
+		query          string
+		mockApply      bool
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Dry Run",
+			adminToken:     "secret",
+			header:         "secret",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"scanned":3,"applied":false,"anomalies":[{"kind":"self_edge","count":1,"samples":[{"id":1,"first_user":"a@gmail.com","second_user":"a@gmail.com"}]}]}`,
+		},
+		{
+			scenario:       "Apply",
+			adminToken:     "secret",
+			header:         "secret",
+			query:          "?apply=true",
+			mockApply:      true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"scanned":3,"applied":false,"anomalies":[{"kind":"self_edge","count":1,"samples":[{"id":1,"first_user":"a@gmail.com","second_user":"a@gmail.com"}]}]}`,
+		},
+		{
+			scenario:       "Check Fail",
+			adminToken:     "secret",
+			header:         "secret",
+			mockError:      errors.New("Any error"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Any error"}`,
+		},
+		{
+			scenario:       "Invalid Apply",
+			adminToken:     "secret",
+			header:         "secret",
+			query:          "?apply=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Request Invalid"}`,
+		},
+		{
+			scenario:       "Wrong Token",
+			adminToken:     "secret",
+			header:         "guess",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Permission Denied"}`,
+		},
+		{
+			scenario:       "Admin Disabled",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"Admin Endpoints Disabled"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockConsistency := new(friendship.ConsistencyMockService)
+			mockConsistency.On("CheckConsistency", mock.Anything, tc.mockApply).Return(report, tc.mockError)
+
+			r := gin.New()
+			r.POST("/admin/consistency", RequireAdminToken(tc.adminToken), func(c *gin.Context) {
+				CheckConsistencyController(c, mockConsistency)
+			})
+
+			req, _ := http.NewRequest("POST", "/admin/consistency"+tc.query, nil)
+			req.Header.Set("X-Admin-Token", tc.header)
+			w := httptest.NewRecorder()
+
+			// When
+			r.ServeHTTP(w, req)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
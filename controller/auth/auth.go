@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	httpRes "friend_connection_rest_api/controller/common_respone"
+	userService "friend_connection_rest_api/services/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callerKey is the context key of the authenticated email
+const callerKey = "caller"
+
+// Authenticate resolve the bearer access token to the email of the caller,
+// requests without Authorization header are served as anonymous
+func Authenticate(service userService.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, httpRes.HTTPError{Message: "Invalid Authorization Header"})
+			return
+		}
+
+		email, err := service.Authenticate(c.Request.Context(), strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, httpRes.HTTPError{Message: err.Error()})
+			return
+		}
+
+		c.Set(callerKey, email)
+		c.Next()
+	}
+}
+
+// Caller return the authenticated email, it is empty for anonymous request
+func Caller(c *gin.Context) string {
+	return c.GetString(callerKey)
+}
+
+// SetCaller is used to set the authenticated email without the middleware
+func SetCaller(c *gin.Context, email string) {
+	c.Set(callerKey, email)
+}
+
+// RequireCaller write the error respone when the request is not authenticated as email,
+// anonymous request is rejected with 401 and another caller with 403
+func RequireCaller(c *gin.Context, email string) bool {
+	caller := Caller(c)
+	if caller == "" {
+		c.JSON(http.StatusUnauthorized, httpRes.HTTPError{Message: "Authentication Required"})
+		return false
+	}
+
+	if caller != email {
+		c.JSON(http.StatusForbidden, httpRes.HTTPError{Message: "Permission Denied"})
+		return false
+	}
+	return true
+}
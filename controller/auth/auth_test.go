@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"friend_connection_rest_api/services/user"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthenticate(t *testing.T) {
+	testCase := []struct {
+		scenario       string
+		header         string
+		mockEmail      string
+		mockError      error
+		expectedStatus int
+		expectedCaller string
+	}{
+		{
+			scenario:       "Anonymous",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			scenario:       "Valid Token",
+			header:         "Bearer token",
+			mockEmail:      "abc@gmail.com",
+			expectedStatus: http.StatusOK,
+			expectedCaller: "abc@gmail.com",
+		},
+		{
+			scenario:       "Invalid Token",
+			header:         "Bearer token",
+			mockError:      errors.New("Invalid Access Token"),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			scenario:       "Invalid Header",
+			header:         "Basic token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			userMock := new(user.UserMockService)
+			userMock.On("Authenticate", mock.Anything, "token").Return(tc.mockEmail, tc.mockError)
+
+			caller := ""
+			r := gin.New()
+			r.Use(Authenticate(userMock))
+			r.GET("/", func(c *gin.Context) {
+				caller = Caller(c)
+				c.Status(http.StatusOK)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedCaller, caller)
+		})
+	}
+}
+
+func TestRequireCaller(t *testing.T) {
+	testCase := []struct {
+		scenario       string
+		caller         string
+		expected       bool
+		expectedStatus int
+	}{
+		{
+			scenario:       "Caller",
+			caller:         "abc@gmail.com",
+			expected:       true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			scenario:       "Anonymous",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			scenario:       "Another User",
+			caller:         "xyz@gmail.com",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			SetCaller(c, tc.caller)
+
+			assert.Equal(t, tc.expected, RequireCaller(c, "abc@gmail.com"))
+			assert.Equal(t, tc.expectedStatus, w.Code)
+		})
+	}
+}
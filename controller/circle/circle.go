@@ -0,0 +1,21 @@
+package circle
+
+import (
+	"friend_connection_rest_api/services/friendship"
+)
+
+// Using for Create or Rename a circle
+type RequestCircle struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// Using for Add friends to a circle
+type RequestCircleMembers struct {
+	Members []string `json:"members" binding:"required"`
+}
+
+type ResponeListCircles struct {
+	Success bool                    `json:"success"`
+	Circles []friendship.CircleInfo `json:"circles"`
+	Count   uint                    `json:"count"`
+}
@@ -0,0 +1,282 @@
+package circle
+
+import (
+	"net/http"
+	"strings"
+
+	"friend_connection_rest_api/controller/auth"
+	httpRes "friend_connection_rest_api/controller/common_respone"
+	"friend_connection_rest_api/services/friendship"
+	"friend_connection_rest_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCircleController godoc
+// @Summary Create a circle
+// @Description Create a named circle owned by the authenticated user
+// @Tags Circle
+// @Security BearerAuth
+// @Consume json
+// @Param email path string true "Owner email"
+// @Param request body RequestCircle true "RequestCircle"
+// @Produce  json
+// @Success 201 {object} httpRes.HTTPSuccess
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/circles [post]
+func CreateCircleController(c *gin.Context, service friendship.CircleServices) {
+	owner := c.Param("email")
+	reqCircle := RequestCircle{}
+
+	if err := c.BindJSON(&reqCircle); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(owner) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	if isCircleName(reqCircle.Name) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Circle Name Invalid"})
+		return
+	}
+
+	rs := service.CreateCircle(c.Request.Context(), owner, reqCircle.Name)
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(201, httpRes.HTTPSuccess{Success: true})
+}
+
+// GetCirclesController godoc
+// @Summary List circles
+// @Description Get circles of the authenticated user with their members
+// @Tags Circle
+// @Security BearerAuth
+// @Param email path string true "Owner email"
+// @Produce  json
+// @Success 200 {object} ResponeListCircles
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/circles [get]
+func GetCirclesController(c *gin.Context, service friendship.CircleServices) {
+	owner := c.Param("email")
+
+	if utils.ValidateEmail(owner) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	rs, err := service.GetCircles(c.Request.Context(), owner)
+
+	if err != nil {
+		c.JSON(400, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(200, toListCirclesStruct(rs))
+}
+
+// RenameCircleController godoc
+// @Summary Rename a circle
+// @Description Rename a circle of an user
+// @Tags Circle
+// @Security BearerAuth
+// @Consume json
+// @Param email path string true "Owner email"
+// @Param name path string true "Circle name"
+// @Param request body RequestCircle true "RequestCircle"
+// @Produce  json
+// @Success 200 {object} httpRes.HTTPSuccess
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/circles/{name} [put]
+func RenameCircleController(c *gin.Context, service friendship.CircleServices) {
+	owner := c.Param("email")
+	reqCircle := RequestCircle{}
+
+	if err := c.BindJSON(&reqCircle); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(owner) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	if isCircleName(reqCircle.Name) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Circle Name Invalid"})
+		return
+	}
+
+	rs := service.RenameCircle(c.Request.Context(), owner, c.Param("name"), reqCircle.Name)
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(200, httpRes.HTTPSuccess{Success: true})
+}
+
+// DeleteCircleController godoc
+// @Summary Delete a circle
+// @Description Delete a circle of an user
+// @Tags Circle
+// @Security BearerAuth
+// @Param email path string true "Owner email"
+// @Param name path string true "Circle name"
+// @Produce  json
+// @Success 200 {object} httpRes.HTTPSuccess
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/circles/{name} [delete]
+func DeleteCircleController(c *gin.Context, service friendship.CircleServices) {
+	owner := c.Param("email")
+
+	if utils.ValidateEmail(owner) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	rs := service.DeleteCircle(c.Request.Context(), owner, c.Param("name"))
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(200, httpRes.HTTPSuccess{Success: true})
+}
+
+// AddCircleMembersController godoc
+// @Summary Add members to a circle
+// @Description Add friends of the owner to a circle
+// @Tags Circle
+// @Security BearerAuth
+// @Consume json
+// @Param email path string true "Owner email"
+// @Param name path string true "Circle name"
+// @Param request body RequestCircleMembers true "RequestCircleMembers"
+// @Produce  json
+// @Success 201 {object} httpRes.HTTPSuccess
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/circles/{name}/members [post]
+func AddCircleMembersController(c *gin.Context, service friendship.CircleServices) {
+	owner := c.Param("email")
+	reqMembers := RequestCircleMembers{}
+
+	if err := c.BindJSON(&reqMembers); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(owner) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	if len(reqMembers.Members) == 0 {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Request Invalid"})
+		return
+	}
+
+	for _, member := range reqMembers.Members {
+		if utils.ValidateEmail(member) == false {
+			c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+			return
+		}
+	}
+
+	rs := service.AddCircleMembers(c.Request.Context(), owner, c.Param("name"), reqMembers.Members)
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(201, httpRes.HTTPSuccess{Success: true})
+}
+
+// RemoveCircleMemberController godoc
+// @Summary Remove a member from a circle
+// @Description Remove a member from a circle of an user
+// @Tags Circle
+// @Security BearerAuth
+// @Param email path string true "Owner email"
+// @Param name path string true "Circle name"
+// @Param member path string true "Member email"
+// @Produce  json
+// @Success 200 {object} httpRes.HTTPSuccess
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/circles/{name}/members/{member} [delete]
+func RemoveCircleMemberController(c *gin.Context, service friendship.CircleServices) {
+	owner := c.Param("email")
+	member := c.Param("member")
+
+	if utils.ValidateEmail(owner) == false || utils.ValidateEmail(member) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	rs := service.RemoveCircleMember(c.Request.Context(), owner, c.Param("name"), member)
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(200, httpRes.HTTPSuccess{Success: true})
+}
+
+// isCircleName circle name is used in path so it can not be blank or contain slash
+func isCircleName(name string) bool {
+	return strings.TrimSpace(name) != "" && strings.Contains(name, "/") == false
+}
+
+func toListCirclesStruct(list []friendship.CircleInfo) ResponeListCircles {
+	listCirclesRespone := ResponeListCircles{}
+	listCirclesRespone.Success = true
+	listCirclesRespone.Circles = append([]friendship.CircleInfo{}, list...)
+	listCirclesRespone.Count = uint(len(list))
+	return listCirclesRespone
+}
@@ -0,0 +1,270 @@
+package circle
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"friend_connection_rest_api/controller/auth"
+	"friend_connection_rest_api/services/friendship"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateCircleController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		owner          string
+		body           string
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Create Circle Success",
+			owner:          "owner@gmail.com",
+			body:           `{"name":"family"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"success":true}`,
+		},
+		{
+			scenario:       "Create Circle Fail",
+			owner:          "owner@gmail.com",
+			body:           `{"name":"family"}`,
+			mockError:      errors.New("Circle was exist"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Circle was exist"}`,
+		},
+		{
+			scenario:       "Invalid Circle Name",
+			owner:          "owner@gmail.com",
+			body:           `{"name":"family/work"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Circle Name Invalid"}`,
+		},
+		{
+			scenario:       "Invalid Email",
+			owner:          "owner",
+			body:           `{"name":"family"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Email Invalid Format"}`,
+		},
+		{
+			scenario:       "Empty request body",
+			owner:          "owner@gmail.com",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"BindJson Error, cause body request invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockCircle := new(friendship.CircleMockService)
+			mockCircle.On("CreateCircle", mock.Anything, tc.owner, "family").Return(tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.owner)
+			c.Request, _ = http.NewRequest("POST", "/users/"+tc.owner+"/circles", strings.NewReader(tc.body))
+			c.Params = gin.Params{{Key: "email", Value: tc.owner}}
+
+			// When
+			CreateCircleController(c, mockCircle)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestGetCirclesController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		owner          string
+		caller         string
+		mockRespone    []friendship.CircleInfo
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario: "Get Circles Success",
+			owner:    "owner@gmail.com",
+			caller:   "owner@gmail.com",
+			mockRespone: []friendship.CircleInfo{
+				{Name: "family", Members: []string{"mom@gmail.com"}},
+				{Name: "work", Members: []string{}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true,"circles":[{"name":"family","members":["mom@gmail.com"]},{"name":"work","members":[]}],"count":2}`,
+		},
+		{
+			scenario:       "Get Circles Fail",
+			owner:          "owner@gmail.com",
+			caller:         "owner@gmail.com",
+			mockError:      errors.New("User Not Exist"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"User Not Exist"}`,
+		},
+		{
+			scenario:       "Invalid Email",
+			owner:          "owner",
+			caller:         "owner@gmail.com",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Email Invalid Format"}`,
+		},
+		{
+			scenario:       "Circles Of Another User",
+			owner:          "owner@gmail.com",
+			caller:         "other@gmail.com",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Permission Denied"}`,
+		},
+		{
+			scenario:       "Without Authentication",
+			owner:          "owner@gmail.com",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Authentication Required"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockCircle := new(friendship.CircleMockService)
+			mockCircle.On("GetCircles", mock.Anything, tc.owner).Return(tc.mockRespone, tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.caller)
+			c.Request, _ = http.NewRequest("GET", "/users/"+tc.owner+"/circles", nil)
+			c.Params = gin.Params{{Key: "email", Value: tc.owner}}
+
+			// When
+			GetCirclesController(c, mockCircle)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestRenameCircleController(t *testing.T) {
+	mockCircle := new(friendship.CircleMockService)
+	mockCircle.On("RenameCircle", mock.Anything, "owner@gmail.com", "family", "home").Return(nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	auth.SetCaller(c, "owner@gmail.com")
+	c.Request, _ = http.NewRequest("PUT", "/users/owner@gmail.com/circles/family", strings.NewReader(`{"name":"home"}`))
+	c.Params = gin.Params{{Key: "email", Value: "owner@gmail.com"}, {Key: "name", Value: "family"}}
+
+	RenameCircleController(c, mockCircle)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, `{"success":true}`, string(body))
+}
+
+func TestDeleteCircleController(t *testing.T) {
+	mockCircle := new(friendship.CircleMockService)
+	mockCircle.On("DeleteCircle", mock.Anything, "owner@gmail.com", "family").Return(errors.New("Circle Not Exist"))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	auth.SetCaller(c, "owner@gmail.com")
+	c.Request, _ = http.NewRequest("DELETE", "/users/owner@gmail.com/circles/family", nil)
+	c.Params = gin.Params{{Key: "email", Value: "owner@gmail.com"}, {Key: "name", Value: "family"}}
+
+	DeleteCircleController(c, mockCircle)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	assert.Equal(t, `{"error":"Circle Not Exist"}`, string(body))
+}
+
+func TestAddCircleMembersController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		body           string
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Add Members Success",
+			body:           `{"members":["friend@gmail.com"]}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"success":true}`,
+		},
+		{
+			scenario:       "Add Members Fail",
+			body:           `{"members":["friend@gmail.com"]}`,
+			mockError:      errors.New("Member Is Not Friend"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Member Is Not Friend"}`,
+		},
+		{
+			scenario:       "Invalid Member Email",
+			body:           `{"members":["friend"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Email Invalid Format"}`,
+		},
+		{
+			scenario:       "Empty Members",
+			body:           `{"members":[]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Request Invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockCircle := new(friendship.CircleMockService)
+			mockCircle.On("AddCircleMembers", mock.Anything, "owner@gmail.com", "family", []string{"friend@gmail.com"}).Return(tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, "owner@gmail.com")
+			auth.SetCaller(c, "owner@gmail.com")
+			c.Request, _ = http.NewRequest("POST", "/users/owner@gmail.com/circles/family/members", strings.NewReader(tc.body))
+			c.Params = gin.Params{{Key: "email", Value: "owner@gmail.com"}, {Key: "name", Value: "family"}}
+
+			// When
+			AddCircleMembersController(c, mockCircle)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestRemoveCircleMemberController(t *testing.T) {
+	mockCircle := new(friendship.CircleMockService)
+	mockCircle.On("RemoveCircleMember", mock.Anything, "owner@gmail.com", "family", "friend@gmail.com").Return(nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	auth.SetCaller(c, "owner@gmail.com")
+	c.Request, _ = http.NewRequest("DELETE", "/users/owner@gmail.com/circles/family/members/friend@gmail.com", nil)
+	c.Params = gin.Params{{Key: "email", Value: "owner@gmail.com"}, {Key: "name", Value: "family"}, {Key: "member", Value: "friend@gmail.com"}}
+
+	RemoveCircleMemberController(c, mockCircle)
+
+	body, _ := ioutil.ReadAll(w.Result().Body)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, `{"success":true}`, string(body))
+}
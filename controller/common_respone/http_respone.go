@@ -1,7 +1,7 @@
 package common_respone
 
 type HTTPSuccess struct {
-	Success bool `json:"success" example:true`
+	Success bool `json:"success" example:"true"`
 }
 
 type HTTPError struct {
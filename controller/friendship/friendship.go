@@ -1,5 +1,9 @@
 package friendship
 
+import (
+	"friend_connection_rest_api/services/friendship"
+)
+
 // Using for Retrieve List friends of an user or List common friends of two users
 type ResponeListFriends struct {
 	Success bool     `json:"success"`
@@ -12,6 +16,13 @@ type ResponeReceiveUpdate struct {
 	Recipients []string `json:"recipients"`
 }
 
+// Using when target is not registered and an invitation was recorded
+type ResponeInvitation struct {
+	Success bool   `json:"success"`
+	Invited bool   `json:"invited"`
+	Message string `json:"message"`
+}
+
 // Using for Request Add Friend and Retrieve the common friends
 type RequestFriend struct {
 	Friends []string `json:"friends" binding:"required"`
@@ -24,10 +35,41 @@ type RequestListFriends struct {
 type RequestReceiveUpdate struct {
 	Sender string `json:"sender" binding:"required"`
 	Text   string `json:"text" binding:"required"`
+	Circle string `json:"circle"`
 }
 
-// Using for request Subscribe or Block Update
+// Using for request Subscribe, Block, Mute or Unmute Update
 type RequestUpdate struct {
 	Requestor string `json:"requestor" binding:"required"`
 	Target    string `json:"target" binding:"required"`
 }
+
+// Using for import contacts of an user
+type RequestImportContacts struct {
+	Contacts []string `json:"contacts" binding:"required"`
+	Action   string   `json:"action"`
+}
+
+type ResponeImportContacts struct {
+	Success  bool                       `json:"success"`
+	Contacts []friendship.ContactStatus `json:"contacts"`
+	Invalid  []string                   `json:"invalid"`
+	Count    uint                       `json:"count"`
+}
+
+// Using for execute many graph operations in one request
+type RequestBatchOperation struct {
+	Operation string `json:"operation" binding:"required"`
+	Requestor string `json:"requestor" binding:"required"`
+	Target    string `json:"target" binding:"required"`
+}
+
+type RequestBatch struct {
+	Atomic     bool                    `json:"atomic"`
+	Operations []RequestBatchOperation `json:"operations" binding:"required,dive"`
+}
+
+type ResponeBatch struct {
+	Success bool                     `json:"success"`
+	Results []friendship.BatchResult `json:"results"`
+}
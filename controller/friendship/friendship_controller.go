@@ -1,8 +1,15 @@
 package friendship
 
 import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"friend_connection_rest_api/controller/auth"
 	httpRes "friend_connection_rest_api/controller/common_respone"
 	"friend_connection_rest_api/services/friendship"
 	"friend_connection_rest_api/services/user"
@@ -11,6 +18,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxImportContacts is the maximum number of contacts in one import request
+const maxImportContacts = 1000
+
 func MakeFriendController(c *gin.Context, service friendship.FrienshipServices) {
 	var reqFriend RequestFriend
 
@@ -32,13 +42,27 @@ func MakeFriendController(c *gin.Context, service friendship.FrienshipServices)
 		return
 	}
 
-	rs := service.MakeFriend(friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
+	if !auth.RequireCaller(c, firstUser) {
+		return
+	}
+
+	rs := service.MakeFriend(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
 
 	if rs == nil {
 		c.JSON(201, httpRes.HTTPSuccess{Success: true})
 		return
 	}
 
+	if rs == friendship.ErrInvitationPending {
+		c.JSON(http.StatusAccepted, toInvitationStruct(rs))
+		return
+	}
+
+	if rs == friendship.ErrPrivacyRestricted {
+		c.JSON(http.StatusForbidden, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
 	c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
 }
 
@@ -55,13 +79,24 @@ func GetFriendsListController(c *gin.Context, service friendship.FrienshipServic
 		return
 	}
 
-	rs, err := service.GetFriendsList(user.Users{Email: email.Mail})
+	etag, lastModified, notModified := checkNotModified(c, service, email.Mail)
+	if notModified {
+		return
+	}
+
+	rs, err := service.GetFriendsList(c.Request.Context(), user.Users{Email: email.Mail}, auth.Caller(c))
+
+	if err == friendship.ErrPrivacyRestricted {
+		c.JSON(http.StatusForbidden, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
 
 	if err != nil {
 		c.JSON(400, httpRes.HTTPError{Message: err.Error()})
 		return
 	}
 
+	setCacheHeaders(c, etag, lastModified)
 	c.JSON(200, toListFriendsStruct(rs))
 }
 
@@ -86,13 +121,24 @@ func GetMutualFriendsController(c *gin.Context, service friendship.FrienshipServ
 		return
 	}
 
-	rs, err := service.GetMutualFriendsList(friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
+	etag, lastModified, notModified := checkNotModified(c, service, firstUser, secondUser)
+	if notModified {
+		return
+	}
+
+	rs, err := service.GetMutualFriendsList(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser}, auth.Caller(c))
+
+	if err == friendship.ErrPrivacyRestricted {
+		c.JSON(http.StatusForbidden, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
 
 	if err != nil {
 		c.JSON(400, httpRes.HTTPError{Message: err.Error()})
 		return
 	}
 
+	setCacheHeaders(c, etag, lastModified)
 	c.JSON(200, toListFriendsStruct(rs))
 }
 
@@ -117,7 +163,16 @@ func SubscribeController(c *gin.Context, service friendship.FrienshipServices) {
 		return
 	}
 
-	rs := service.Subscribe(friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
+	if !auth.RequireCaller(c, firstUser) {
+		return
+	}
+
+	rs := service.Subscribe(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
+
+	if rs == friendship.ErrInvitationPending {
+		c.JSON(http.StatusAccepted, toInvitationStruct(rs))
+		return
+	}
 
 	if rs != nil {
 		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
@@ -148,7 +203,11 @@ func BlockController(c *gin.Context, service friendship.FrienshipServices) {
 		return
 	}
 
-	rs := service.Block(friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
+	if !auth.RequireCaller(c, firstUser) {
+		return
+	}
+
+	rs := service.Block(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: secondUser})
 
 	if rs != nil {
 		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
@@ -158,6 +217,105 @@ func BlockController(c *gin.Context, service friendship.FrienshipServices) {
 	c.JSON(201, httpRes.HTTPSuccess{Success: true})
 }
 
+func MuteController(c *gin.Context, service friendship.FrienshipServices) {
+	reqMute := RequestUpdate{}
+
+	if err := c.BindJSON(&reqMute); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if reqMute.Requestor == reqMute.Target {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Request Invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(reqMute.Requestor) == false || utils.ValidateEmail(reqMute.Target) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, reqMute.Requestor) {
+		return
+	}
+
+	rs := service.Mute(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: reqMute.Requestor, TargetEmail: reqMute.Target})
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(201, httpRes.HTTPSuccess{Success: true})
+}
+
+func UnmuteController(c *gin.Context, service friendship.FrienshipServices) {
+	reqUnmute := RequestUpdate{}
+
+	if err := c.BindJSON(&reqUnmute); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if reqUnmute.Requestor == reqUnmute.Target {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Request Invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(reqUnmute.Requestor) == false || utils.ValidateEmail(reqUnmute.Target) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, reqUnmute.Requestor) {
+		return
+	}
+
+	rs := service.Unmute(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: reqUnmute.Requestor, TargetEmail: reqUnmute.Target})
+
+	if rs != nil {
+		c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(200, httpRes.HTTPSuccess{Success: true})
+}
+
+func GetRelationshipController(c *gin.Context, service friendship.FrienshipServices) {
+	email := c.Param("email")
+	other := c.Param("other")
+
+	if email == other {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Request Invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(email) == false || utils.ValidateEmail(other) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	etag, lastModified, notModified := checkNotModified(c, service, email)
+	if notModified {
+		return
+	}
+
+	rs, err := service.GetRelationship(c.Request.Context(), friendship.FrienshipServiceInput{RequestEmail: email, TargetEmail: other}, auth.Caller(c))
+
+	if err == friendship.ErrPrivacyRestricted {
+		c.JSON(http.StatusForbidden, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(400, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	setCacheHeaders(c, etag, lastModified)
+	c.JSON(200, rs)
+}
+
 func GetUsersReceiveUpdateController(c *gin.Context, service friendship.FrienshipServices) {
 	reqRecvUpdate := RequestReceiveUpdate{}
 
@@ -171,10 +329,14 @@ func GetUsersReceiveUpdateController(c *gin.Context, service friendship.Frienshi
 		return
 	}
 
+	if !auth.RequireCaller(c, reqRecvUpdate.Sender) {
+		return
+	}
+
 	// rename
 	mentionedUsers := utils.ExtractMentionEmail(reqRecvUpdate.Text)
 
-	rs, err := service.GetUsersReceiveUpdate(reqRecvUpdate.Sender, mentionedUsers)
+	rs, err := service.GetUsersReceiveUpdate(c.Request.Context(), reqRecvUpdate.Sender, mentionedUsers, reqRecvUpdate.Circle)
 
 	if err != nil {
 		c.JSON(400, httpRes.HTTPError{Message: err.Error()})
@@ -184,6 +346,162 @@ func GetUsersReceiveUpdateController(c *gin.Context, service friendship.Frienshi
 	c.JSON(200, toUsersCanReceiveUpdate(removeDuplicates(rs)))
 }
 
+// ImportContactsController accept a JSON list or a CSV of emails,
+// action for CSV request is set by query parameter
+func ImportContactsController(c *gin.Context, service friendship.FrienshipServices) {
+	owner := c.Param("email")
+
+	if utils.ValidateEmail(owner) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Email Invalid Format"})
+		return
+	}
+
+	if !auth.RequireCaller(c, owner) {
+		return
+	}
+
+	reqImport := RequestImportContacts{}
+
+	if c.ContentType() == "text/csv" {
+		records, err := readCSVContacts(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "CSV Error, cause body request invalid"})
+			return
+		}
+		reqImport.Contacts = records
+		reqImport.Action = c.Query("action")
+	} else if err := c.BindJSON(&reqImport); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if len(reqImport.Contacts) > maxImportContacts {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Too Many Contacts"})
+		return
+	}
+
+	contacts := []string{}
+	invalid := []string{}
+	for _, contact := range removeDuplicates(reqImport.Contacts) {
+		if utils.ValidateEmail(contact) == false || contact == owner {
+			invalid = append(invalid, contact)
+			continue
+		}
+		contacts = append(contacts, contact)
+	}
+
+	rs, err := service.ImportContacts(c.Request.Context(), owner, contacts, reqImport.Action)
+
+	if err != nil {
+		c.JSON(400, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(200, toImportContactsStruct(rs, invalid))
+}
+
+// BatchController execute an ordered list of operations, maxSize is the maximum number of operations
+func BatchController(c *gin.Context, service friendship.FrienshipServices, maxSize int) {
+	reqBatch := RequestBatch{}
+
+	if err := c.BindJSON(&reqBatch); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if len(reqBatch.Operations) == 0 {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Request Invalid"})
+		return
+	}
+
+	if len(reqBatch.Operations) > maxSize {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: fmt.Sprintf("Batch Size Exceeds Limit Of %d", maxSize)})
+		return
+	}
+
+	// Each operation is run on behalf of its requestor, which must be the caller
+	caller := auth.Caller(c)
+	if caller == "" {
+		c.JSON(http.StatusUnauthorized, httpRes.HTTPError{Message: "Authentication Required"})
+		return
+	}
+
+	operations := []friendship.BatchOperation{}
+	for i, op := range reqBatch.Operations {
+		if isBatchOperation(op.Operation) == false || op.Requestor == op.Target {
+			c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: fmt.Sprintf("Operation %d Invalid", i)})
+			return
+		}
+
+		if utils.ValidateEmail(op.Requestor) == false || utils.ValidateEmail(op.Target) == false {
+			c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: fmt.Sprintf("Operation %d Email Invalid Format", i)})
+			return
+		}
+
+		if op.Requestor != caller {
+			c.JSON(http.StatusForbidden, httpRes.HTTPError{Message: fmt.Sprintf("Operation %d Permission Denied", i)})
+			return
+		}
+
+		operations = append(operations, friendship.BatchOperation{
+			Operation: op.Operation,
+			Input:     friendship.FrienshipServiceInput{RequestEmail: op.Requestor, TargetEmail: op.Target},
+		})
+	}
+
+	rs, err := service.ExecuteBatch(c.Request.Context(), operations, reqBatch.Atomic)
+
+	if err == friendship.ErrBatchRolledBack {
+		c.JSON(400, ResponeBatch{Success: false, Results: rs})
+		return
+	}
+
+	if err != nil {
+		c.JSON(500, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(200, ResponeBatch{Success: true, Results: rs})
+}
+
+func isBatchOperation(operation string) bool {
+	switch operation {
+	case friendship.OperationMakeFriend, friendship.OperationSubscribe, friendship.OperationBlock, friendship.OperationUnfriend:
+		return true
+	}
+	return false
+}
+
+func readCSVContacts(c *gin.Context) ([]string, error) {
+	reader := csv.NewReader(c.Request.Body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	contacts := []string{}
+	for _, record := range records {
+		for _, field := range record {
+			if field = strings.TrimSpace(field); field != "" {
+				contacts = append(contacts, field)
+			}
+		}
+	}
+	return contacts, nil
+}
+
+func toImportContactsStruct(list []friendship.ContactStatus, invalid []string) ResponeImportContacts {
+	importRespone := ResponeImportContacts{}
+	importRespone.Success = true
+	importRespone.Contacts = append([]friendship.ContactStatus{}, list...)
+	importRespone.Invalid = append([]string{}, invalid...)
+	importRespone.Count = uint(len(list))
+	return importRespone
+}
+
 func toListFriendsStruct(list []string) ResponeListFriends {
 	listFriendsRespone := ResponeListFriends{}
 	listFriendsRespone.Count = uint(len(list))
@@ -199,6 +517,10 @@ func toUsersCanReceiveUpdate(list []string) ResponeReceiveUpdate {
 	return listUsersRecvUpdate
 }
 
+func toInvitationStruct(err error) ResponeInvitation {
+	return ResponeInvitation{Success: true, Invited: true, Message: err.Error()}
+}
+
 func removeDuplicates(elements []string) []string {
 	// Use map to record duplicates as we find them.
 	encountered := map[string]bool{}
@@ -217,3 +539,58 @@ func removeDuplicates(elements []string) []string {
 	// Return the new slice.
 	return result
 }
+
+// checkNotModified compute the ETag of the respone from the relationship versions of emails, in the table and in
+// the graph index which may answer the lists, the route and the caller, whose access may differ. It writes 304 when the ETag matches the If-None-Match header of the request.
+// The ETag is empty when the versions can not be read, the respone is then served without it
+func checkNotModified(c *gin.Context, service friendship.FrienshipServices, emails ...string) (string, time.Time, bool) {
+	versions, err := service.GetRelationshipVersions(c.Request.Context(), emails)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", c.FullPath(), c.Request.URL.Path, auth.Caller(c))
+	lastModified := time.Time{}
+	for _, version := range versions {
+		fmt.Fprintf(hash, "%s:%d:%d\n", version.Email, version.Version, version.IndexVersion)
+		if version.UpdatedAt.After(lastModified) {
+			lastModified = version.UpdatedAt
+		}
+	}
+	// The ETag is weak because the order of the friends is not guaranteed
+	etag := fmt.Sprintf(`W/"%s"`, hex.EncodeToString(hash.Sum(nil))[:32])
+
+	if matchETag(c.GetHeader("If-None-Match"), etag) {
+		setCacheHeaders(c, etag, lastModified)
+		c.Status(http.StatusNotModified)
+		return etag, lastModified, true
+	}
+	return etag, lastModified, false
+}
+
+// setCacheHeaders set the validators of a successful respone, it must be revalidated on each use
+func setCacheHeaders(c *gin.Context, etag string, lastModified time.Time) {
+	if etag == "" {
+		return
+	}
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Vary", "Authorization")
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// matchETag return true when the If-None-Match header contains etag, weak and strong ETags are compared the same way
+func matchETag(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -7,14 +7,18 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"friend_connection_rest_api/controller/auth"
 	"friend_connection_rest_api/services/friendship"
 	"friend_connection_rest_api/services/user"
 	"friend_connection_rest_api/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestMakeFriendController(t *testing.T) {
@@ -87,17 +91,20 @@ func TestMakeFriendController(t *testing.T) {
 			if tc.input.Friends != nil {
 				if tc.scenario != "Make Friend Fail" {
 					if len(tc.input.Friends) == 2 {
-						frienshipMock.On("MakeFriend", friendship.FrienshipServiceInput{RequestEmail: tc.input.Friends[0], TargetEmail: tc.input.Friends[1]}).Return(nil)
+						frienshipMock.On("MakeFriend", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.input.Friends[0], TargetEmail: tc.input.Friends[1]}).Return(nil)
 					} else {
-						frienshipMock.On("MakeFriend", friendship.FrienshipServiceInput{RequestEmail: tc.input.Friends[0]}).Return(nil)
+						frienshipMock.On("MakeFriend", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.input.Friends[0]}).Return(nil)
 					}
 				} else {
-					frienshipMock.On("MakeFriend", friendship.FrienshipServiceInput{RequestEmail: tc.input.Friends[0], TargetEmail: tc.input.Friends[1]}).Return(errors.New("Any Error"))
+					frienshipMock.On("MakeFriend", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.input.Friends[0], TargetEmail: tc.input.Friends[1]}).Return(errors.New("Any Error"))
 				}
 			}
 
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
+			if len(tc.input.Friends) > 0 {
+				auth.SetCaller(c, tc.input.Friends[0])
+			}
 
 			values := map[string][]string{"friends": tc.input.Friends}
 			jsonValue, _ := json.Marshal(values)
@@ -164,7 +171,8 @@ func TestGetFriendsList(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			mockFriendship := new(friendship.FrienshipMockService)
-			mockFriendship.On("GetFriendsList", tc.input).Return(tc.mockRespone, tc.mockError)
+			mockFriendship.On("GetRelationshipVersions", mock.Anything, mock.Anything).Return([]friendship.RelationshipVersion{}, nil)
+			mockFriendship.On("GetFriendsList", mock.Anything, tc.input, "").Return(tc.mockRespone, tc.mockError)
 
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
@@ -266,11 +274,12 @@ func TestGetMutualFriendsController(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On("GetRelationshipVersions", mock.Anything, mock.Anything).Return([]friendship.RelationshipVersion{}, nil)
 			if tc.requestInput.Friends != nil {
 				if tc.scenario == "Not enough parameters" {
-					mockFriendship.On("GetMutualFriendsList", friendship.FrienshipServiceInput{RequestEmail: tc.requestInput.Friends[0]}).Return(tc.mockRespone, tc.mockError)
+					mockFriendship.On("GetMutualFriendsList", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.requestInput.Friends[0]}, "").Return(tc.mockRespone, tc.mockError)
 				} else {
-					mockFriendship.On("GetMutualFriendsList", friendship.FrienshipServiceInput{RequestEmail: tc.requestInput.Friends[0], TargetEmail: tc.requestInput.Friends[1]}).Return(tc.mockRespone, tc.mockError)
+					mockFriendship.On("GetMutualFriendsList", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.requestInput.Friends[0], TargetEmail: tc.requestInput.Friends[1]}, "").Return(tc.mockRespone, tc.mockError)
 				}
 			}
 
@@ -347,10 +356,11 @@ func TestSubscribeController(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			mockFriendship := new(friendship.FrienshipMockService)
-			mockFriendship.On("Subscribe", friendship.FrienshipServiceInput{RequestEmail: tc.inputRequest.Requestor, TargetEmail: tc.inputRequest.Target}).Return(tc.mockError)
+			mockFriendship.On("Subscribe", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.inputRequest.Requestor, TargetEmail: tc.inputRequest.Target}).Return(tc.mockError)
 
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.inputRequest.Requestor)
 
 			jsonVal, _ := json.Marshal(tc.inputRequest)
 			c.Request, _ = http.NewRequest("POST", "/subscribe", bytes.NewBuffer(jsonVal))
@@ -422,10 +432,11 @@ func TestBlockController(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			mockFriendship := new(friendship.FrienshipMockService)
-			mockFriendship.On("Block", friendship.FrienshipServiceInput{RequestEmail: tc.inputRequest.Requestor, TargetEmail: tc.inputRequest.Target}).Return(tc.mockError)
+			mockFriendship.On("Block", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.inputRequest.Requestor, TargetEmail: tc.inputRequest.Target}).Return(tc.mockError)
 
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.inputRequest.Requestor)
 
 			jsonVal, _ := json.Marshal(tc.inputRequest)
 			c.Request, _ = http.NewRequest("POST", "/block", bytes.NewBuffer(jsonVal))
@@ -473,6 +484,18 @@ func TestGetUsersReceiveUpdateController(t *testing.T) {
 			},
 			expectedSuccessBody: `{"success":true,"recipients":["gema@yahoo.com","muhammadfaurel.augistta@gmail.com","faurellorentermcastilla@gmail.com"]}`,
 		},
+		{
+			scenario: "Receive In Circle Success",
+			inputRequest: &RequestReceiveUpdate{
+				Sender: "arel@gmail.com",
+				Text:   "Hello family!",
+				Circle: "family",
+			},
+			mockRespone: []string{
+				"gema@yahoo.com",
+			},
+			expectedSuccessBody: `{"success":true,"recipients":["gema@yahoo.com"]}`,
+		},
 		{
 			scenario: "Receive Fail",
 			inputRequest: &RequestReceiveUpdate{
@@ -501,11 +524,14 @@ func TestGetUsersReceiveUpdateController(t *testing.T) {
 			mockFriendship := new(friendship.FrienshipMockService)
 			if tc.inputRequest != nil {
 				mentioned := utils.ExtractMentionEmail(tc.inputRequest.Text)
-				mockFriendship.On("GetUsersReceiveUpdate", tc.inputRequest.Sender, mentioned).Return(tc.mockRespone, tc.mockError)
+				mockFriendship.On("GetUsersReceiveUpdate", mock.Anything, tc.inputRequest.Sender, mentioned, tc.inputRequest.Circle).Return(tc.mockRespone, tc.mockError)
 			}
 
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
+			if tc.inputRequest != nil {
+				auth.SetCaller(c, tc.inputRequest.Sender)
+			}
 
 			jsonVal, _ := json.Marshal(tc.inputRequest)
 			c.Request, _ = http.NewRequest("POST", "/get-list-users-receive-update", bytes.NewBuffer(jsonVal))
@@ -519,7 +545,7 @@ func TestGetUsersReceiveUpdateController(t *testing.T) {
 			body, _ := ioutil.ReadAll(w.Result().Body)
 			actualResult := string(body)
 
-			if tc.scenario == "Recvice Success" {
+			if tc.scenario == "Receive Success" || tc.scenario == "Receive In Circle Success" {
 				assert.Equal(t, 200, w.Result().StatusCode)
 				assert.Equal(t, tc.expectedSuccessBody, actualResult)
 			} else {
@@ -529,3 +555,655 @@ func TestGetUsersReceiveUpdateController(t *testing.T) {
 		})
 	}
 }
+
+func TestInvitationController(t *testing.T) {
+	testCase := []struct {
+		scenario     string
+		method       string
+		url          string
+		inputRequest interface{}
+		controller   func(c *gin.Context, service friendship.FrienshipServices)
+	}{
+		{
+			scenario:     "Make Friend With Not Registered User",
+			method:       "MakeFriend",
+			url:          "/add-friends",
+			inputRequest: RequestFriend{Friends: []string{"requestor@gmail.com", "target@gmail.com"}},
+			controller:   MakeFriendController,
+		},
+		{
+			scenario:     "Subscribe Not Registered User",
+			method:       "Subscribe",
+			url:          "/subscribe",
+			inputRequest: RequestUpdate{Requestor: "requestor@gmail.com", Target: "target@gmail.com"},
+			controller:   SubscribeController,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On(tc.method, mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "requestor@gmail.com", TargetEmail: "target@gmail.com"}).Return(friendship.ErrInvitationPending)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, "requestor@gmail.com")
+
+			jsonVal, _ := json.Marshal(tc.inputRequest)
+			c.Request, _ = http.NewRequest("POST", tc.url, bytes.NewBuffer(jsonVal))
+
+			// When
+			tc.controller(c, mockFriendship)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+			assert.Equal(t, `{"success":true,"invited":true,"message":"User Not Exist, Invitation Was Recorded"}`, string(body))
+		})
+	}
+}
+
+func TestImportContactsController(t *testing.T) {
+	// Given
+	tooManyContacts := []string{}
+	for i := 0; i <= maxImportContacts; i++ {
+		tooManyContacts = append(tooManyContacts, "contact@gmail.com")
+	}
+
+	testCase := []struct {
+		scenario            string
+		owner               string
+		contentType         string
+		body                string
+		mockContacts        []string
+		mockAction          string
+		mockRespone         []friendship.ContactStatus
+		mockError           error
+		expectedStatus      int
+		expectedSuccessBody string
+		expectedErrorBody   string
+	}{
+		{
+			scenario:     "Import JSON Success",
+			owner:        "owner@gmail.com",
+			contentType:  "application/json",
+			body:         `{"contacts":["friend@gmail.com","abc","friend@gmail.com"],"action":"make_friend"}`,
+			mockContacts: []string{"friend@gmail.com"},
+			mockAction:   friendship.ContactActionMakeFriend,
+			mockRespone: []friendship.ContactStatus{
+				{Email: "friend@gmail.com", Registered: true, Status: friendship.ContactConnected},
+			},
+			expectedStatus:      http.StatusOK,
+			expectedSuccessBody: `{"success":true,"contacts":[{"email":"friend@gmail.com","registered":true,"friend":false,"blocked":false,"status":"connected"}],"invalid":["abc"],"count":1}`,
+		},
+		{
+			scenario:     "Import CSV Success",
+			owner:        "owner@gmail.com",
+			contentType:  "text/csv",
+			body:         "friend@gmail.com, other@gmail.com\nowner@gmail.com\n",
+			mockContacts: []string{"friend@gmail.com", "other@gmail.com"},
+			mockRespone: []friendship.ContactStatus{
+				{Email: "friend@gmail.com", Registered: true, Friend: true},
+				{Email: "other@gmail.com"},
+			},
+			expectedStatus:      http.StatusOK,
+			expectedSuccessBody: `{"success":true,"contacts":[{"email":"friend@gmail.com","registered":true,"friend":true,"blocked":false},{"email":"other@gmail.com","registered":false,"friend":false,"blocked":false}],"invalid":["owner@gmail.com"],"count":2}`,
+		},
+		{
+			scenario:          "Import Fail",
+			owner:             "owner@gmail.com",
+			contentType:       "application/json",
+			body:              `{"contacts":["friend@gmail.com"]}`,
+			mockContacts:      []string{"friend@gmail.com"},
+			mockError:         errors.New("Any error"),
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"Any error"}`,
+		},
+		{
+			scenario:          "Invalid Owner Email",
+			owner:             "owner",
+			contentType:       "application/json",
+			body:              `{"contacts":["friend@gmail.com"]}`,
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"Email Invalid Format"}`,
+		},
+		{
+			scenario:          "Too Many Contacts",
+			owner:             "owner@gmail.com",
+			contentType:       "text/csv",
+			body:              strings.Join(tooManyContacts, "\n"),
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"Too Many Contacts"}`,
+		},
+		{
+			scenario:          "Empty request body",
+			owner:             "owner@gmail.com",
+			contentType:       "application/json",
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"BindJson Error, cause body request invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On("ImportContacts", mock.Anything, tc.owner, tc.mockContacts, tc.mockAction).Return(tc.mockRespone, tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.owner)
+
+			c.Request, _ = http.NewRequest("POST", "/users/"+tc.owner+"/contacts/import", strings.NewReader(tc.body))
+			c.Request.Header.Set("Content-Type", tc.contentType)
+			c.Params = gin.Params{{Key: "email", Value: tc.owner}}
+
+			// When
+			ImportContactsController(c, mockFriendship)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			actualResult := string(body)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedSuccessBody, actualResult)
+			} else {
+				assert.Equal(t, tc.expectedErrorBody, actualResult)
+			}
+		})
+	}
+}
+
+func TestBatchController(t *testing.T) {
+	// Given
+	const maxSize int = 2
+	operations := []friendship.BatchOperation{
+		{Operation: friendship.OperationMakeFriend, Input: friendship.FrienshipServiceInput{RequestEmail: "a@gmail.com", TargetEmail: "b@gmail.com"}},
+		{Operation: friendship.OperationBlock, Input: friendship.FrienshipServiceInput{RequestEmail: "a@gmail.com", TargetEmail: "c@gmail.com"}},
+	}
+
+	testCase := []struct {
+		scenario       string
+		caller         string
+		body           string
+		mockAtomic     bool
+		mockRespone    []friendship.BatchResult
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario: "Batch Success",
+			caller:   "a@gmail.com",
+			body:     `{"operations":[{"operation":"make_friend","requestor":"a@gmail.com","target":"b@gmail.com"},{"operation":"block","requestor":"a@gmail.com","target":"c@gmail.com"}]}`,
+			mockRespone: []friendship.BatchResult{
+				{Operation: friendship.OperationMakeFriend, Requestor: "a@gmail.com", Target: "b@gmail.com", Success: true},
+				{Operation: friendship.OperationBlock, Requestor: "a@gmail.com", Target: "c@gmail.com", Error: "Any error"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true,"results":[{"operation":"make_friend","requestor":"a@gmail.com","target":"b@gmail.com","success":true},{"operation":"block","requestor":"a@gmail.com","target":"c@gmail.com","success":false,"error":"Any error"}]}`,
+		},
+		{
+			scenario:   "Atomic Batch Rolled Back",
+			caller:     "a@gmail.com",
+			body:       `{"atomic":true,"operations":[{"operation":"make_friend","requestor":"a@gmail.com","target":"b@gmail.com"},{"operation":"block","requestor":"a@gmail.com","target":"c@gmail.com"}]}`,
+			mockAtomic: true,
+			mockRespone: []friendship.BatchResult{
+				{Operation: friendship.OperationMakeFriend, Requestor: "a@gmail.com", Target: "b@gmail.com", Error: "Rolled Back"},
+				{Operation: friendship.OperationBlock, Requestor: "a@gmail.com", Target: "c@gmail.com", Error: "Any error"},
+			},
+			mockError:      friendship.ErrBatchRolledBack,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"success":false,"results":[{"operation":"make_friend","requestor":"a@gmail.com","target":"b@gmail.com","success":false,"error":"Rolled Back"},{"operation":"block","requestor":"a@gmail.com","target":"c@gmail.com","success":false,"error":"Any error"}]}`,
+		},
+		{
+			scenario:       "Batch Size Exceeds Limit",
+			caller:         "a@gmail.com",
+			body:           `{"operations":[{"operation":"block","requestor":"a@gmail.com","target":"b@gmail.com"},{"operation":"block","requestor":"a@gmail.com","target":"c@gmail.com"},{"operation":"block","requestor":"a@gmail.com","target":"d@gmail.com"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Batch Size Exceeds Limit Of 2"}`,
+		},
+		{
+			scenario:       "Unknown Operation",
+			caller:         "a@gmail.com",
+			body:           `{"operations":[{"operation":"follow","requestor":"a@gmail.com","target":"b@gmail.com"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Operation 0 Invalid"}`,
+		},
+		{
+			scenario:       "Invalid Email",
+			caller:         "a@gmail.com",
+			body:           `{"operations":[{"operation":"block","requestor":"a@gmail.com","target":"b@gmail.com"},{"operation":"block","requestor":"a","target":"b@gmail.com"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Operation 1 Email Invalid Format"}`,
+		},
+		{
+			scenario:       "Without Authentication",
+			body:           `{"operations":[{"operation":"block","requestor":"a@gmail.com","target":"b@gmail.com"}]}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Authentication Required"}`,
+		},
+		{
+			scenario:       "Operation On Behalf Of Other User",
+			caller:         "a@gmail.com",
+			body:           `{"operations":[{"operation":"block","requestor":"a@gmail.com","target":"b@gmail.com"},{"operation":"make_friend","requestor":"b@gmail.com","target":"c@gmail.com"}]}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Operation 1 Permission Denied"}`,
+		},
+		{
+			scenario:       "Empty Operations",
+			caller:         "a@gmail.com",
+			body:           `{"operations":[]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Request Invalid"}`,
+		},
+		{
+			scenario:       "Missing Target",
+			caller:         "a@gmail.com",
+			body:           `{"operations":[{"operation":"block","requestor":"a@gmail.com"}]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"BindJson Error, cause body request invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On("ExecuteBatch", mock.Anything, operations, tc.mockAtomic).Return(tc.mockRespone, tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.caller)
+
+			c.Request, _ = http.NewRequest("POST", "/batch", strings.NewReader(tc.body))
+
+			// When
+			BatchController(c, mockFriendship, maxSize)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestMuteController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		inputRequest   RequestUpdate
+		controller     func(c *gin.Context, service friendship.FrienshipServices)
+		method         string
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Mute Success",
+			inputRequest:   RequestUpdate{Requestor: "requestor@gmail.com", Target: "target@gmail.com"},
+			controller:     MuteController,
+			method:         "Mute",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"success":true}`,
+		},
+		{
+			scenario:       "Mute Fail",
+			inputRequest:   RequestUpdate{Requestor: "requestor@gmail.com", Target: "target@gmail.com"},
+			controller:     MuteController,
+			method:         "Mute",
+			mockError:      errors.New("Any error"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Any error"}`,
+		},
+		{
+			scenario:       "Unmute Success",
+			inputRequest:   RequestUpdate{Requestor: "requestor@gmail.com", Target: "target@gmail.com"},
+			controller:     UnmuteController,
+			method:         "Unmute",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true}`,
+		},
+		{
+			scenario:       "Unmute Fail",
+			inputRequest:   RequestUpdate{Requestor: "requestor@gmail.com", Target: "target@gmail.com"},
+			controller:     UnmuteController,
+			method:         "Unmute",
+			mockError:      errors.New("Mute Not Exist"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Mute Not Exist"}`,
+		},
+		{
+			scenario:       "Invalid Mail",
+			inputRequest:   RequestUpdate{Requestor: "requestor", Target: "target"},
+			controller:     MuteController,
+			method:         "Mute",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Email Invalid Format"}`,
+		},
+		{
+			scenario:       "Same user",
+			inputRequest:   RequestUpdate{Requestor: "requestor@gmail.com", Target: "requestor@gmail.com"},
+			controller:     UnmuteController,
+			method:         "Unmute",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Request Invalid"}`,
+		},
+		{
+			scenario:       "Not enough parameters",
+			inputRequest:   RequestUpdate{Requestor: "requestor@gmail.com"},
+			controller:     MuteController,
+			method:         "Mute",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"BindJson Error, cause body request invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On(tc.method, mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.inputRequest.Requestor, TargetEmail: tc.inputRequest.Target}).Return(tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.inputRequest.Requestor)
+
+			jsonVal, _ := json.Marshal(tc.inputRequest)
+			c.Request, _ = http.NewRequest("POST", "/mute", bytes.NewBuffer(jsonVal))
+
+			// When
+			tc.controller(c, mockFriendship)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestPrivacyController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		caller         string
+		requestBody    interface{}
+		controller     func(c *gin.Context, service friendship.FrienshipServices)
+		setupMock      func(m *friendship.FrienshipMockService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:    "Friend List Restricted",
+			caller:      "viewer@gmail.com",
+			requestBody: RequestListFriends{Mail: "owner@gmail.com"},
+			controller:  GetFriendsListController,
+			setupMock: func(m *friendship.FrienshipMockService) {
+				m.On("GetFriendsList", mock.Anything, user.Users{Email: "owner@gmail.com"}, "viewer@gmail.com").Return([]string(nil), friendship.ErrPrivacyRestricted)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Restricted By Privacy Settings"}`,
+		},
+		{
+			scenario:    "Mutual Friends Restricted",
+			caller:      "viewer@gmail.com",
+			requestBody: RequestFriend{Friends: []string{"owner@gmail.com", "other@gmail.com"}},
+			controller:  GetMutualFriendsController,
+			setupMock: func(m *friendship.FrienshipMockService) {
+				m.On("GetMutualFriendsList", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "owner@gmail.com", TargetEmail: "other@gmail.com"}, "viewer@gmail.com").Return([]string(nil), friendship.ErrPrivacyRestricted)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Restricted By Privacy Settings"}`,
+		},
+		{
+			scenario:    "Friend Request Restricted",
+			caller:      "requestor@gmail.com",
+			requestBody: RequestFriend{Friends: []string{"requestor@gmail.com", "owner@gmail.com"}},
+			controller:  MakeFriendController,
+			setupMock: func(m *friendship.FrienshipMockService) {
+				m.On("MakeFriend", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "requestor@gmail.com", TargetEmail: "owner@gmail.com"}).Return(friendship.ErrPrivacyRestricted)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Restricted By Privacy Settings"}`,
+		},
+		{
+			scenario:       "Friend Request On Behalf Of Other User",
+			caller:         "other@gmail.com",
+			requestBody:    RequestFriend{Friends: []string{"requestor@gmail.com", "owner@gmail.com"}},
+			controller:     MakeFriendController,
+			setupMock:      func(m *friendship.FrienshipMockService) {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Permission Denied"}`,
+		},
+		{
+			scenario:       "Friend Request Without Authentication",
+			requestBody:    RequestFriend{Friends: []string{"requestor@gmail.com", "owner@gmail.com"}},
+			controller:     MakeFriendController,
+			setupMock:      func(m *friendship.FrienshipMockService) {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Authentication Required"}`,
+		},
+		{
+			scenario:       "Block On Behalf Of Other User",
+			caller:         "other@gmail.com",
+			requestBody:    RequestUpdate{Requestor: "requestor@gmail.com", Target: "owner@gmail.com"},
+			controller:     BlockController,
+			setupMock:      func(m *friendship.FrienshipMockService) {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Permission Denied"}`,
+		},
+		{
+			scenario:       "Subscribe Without Authentication",
+			requestBody:    RequestUpdate{Requestor: "requestor@gmail.com", Target: "owner@gmail.com"},
+			controller:     SubscribeController,
+			setupMock:      func(m *friendship.FrienshipMockService) {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Authentication Required"}`,
+		},
+		{
+			scenario:       "Send Update Without Authentication",
+			requestBody:    RequestReceiveUpdate{Sender: "sender@gmail.com", Text: "Hello"},
+			controller:     GetUsersReceiveUpdateController,
+			setupMock:      func(m *friendship.FrienshipMockService) {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Authentication Required"}`,
+		},
+		{
+			scenario:       "Send Update On Behalf Of Other User",
+			caller:         "other@gmail.com",
+			requestBody:    RequestReceiveUpdate{Sender: "sender@gmail.com", Text: "Hello"},
+			controller:     GetUsersReceiveUpdateController,
+			setupMock:      func(m *friendship.FrienshipMockService) {},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Permission Denied"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On("GetRelationshipVersions", mock.Anything, mock.Anything).Return([]friendship.RelationshipVersion{}, nil)
+			tc.setupMock(mockFriendship)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.caller)
+			jsonValue, _ := json.Marshal(tc.requestBody)
+			c.Request, _ = http.NewRequest("POST", "/", bytes.NewBuffer(jsonValue))
+
+			// When
+			tc.controller(c, mockFriendship)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestGetRelationshipController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		email          string
+		other          string
+		caller         string
+		mockRespone    friendship.Relationship
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Get Relationship Success",
+			email:          "first@gmail.com",
+			other:          "second@gmail.com",
+			caller:         "first@gmail.com",
+			mockRespone:    friendship.Relationship{User: "first@gmail.com", Other: "second@gmail.com", OtherFollowsUser: true, UserBlocksOther: true},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"user":"first@gmail.com","other":"second@gmail.com","are_friends":false,"user_follows_other":false,"other_follows_user":true,"user_blocks_other":true,"other_blocks_user":false,"pending_request":false}`,
+		},
+		{
+			scenario:       "Get Relationship Fail",
+			email:          "first@gmail.com",
+			other:          "second@gmail.com",
+			caller:         "second@gmail.com",
+			mockError:      errors.New("User Not Exist"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"User Not Exist"}`,
+		},
+		{
+			scenario:       "Caller Not In Relationship",
+			email:          "first@gmail.com",
+			other:          "second@gmail.com",
+			caller:         "third@gmail.com",
+			mockError:      friendship.ErrPrivacyRestricted,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Restricted By Privacy Settings"}`,
+		},
+		{
+			scenario:       "Invalid Mail",
+			email:          "first",
+			other:          "second@gmail.com",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Email Invalid Format"}`,
+		},
+		{
+			scenario:       "Same user",
+			email:          "first@gmail.com",
+			other:          "first@gmail.com",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Request Invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+			mockFriendship.On("GetRelationshipVersions", mock.Anything, mock.Anything).Return([]friendship.RelationshipVersion{}, nil)
+			mockFriendship.On("GetRelationship", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: tc.email, TargetEmail: tc.other}, tc.caller).Return(tc.mockRespone, tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.caller)
+			c.Params = gin.Params{{Key: "email", Value: tc.email}, {Key: "other", Value: tc.other}}
+			c.Request, _ = http.NewRequest("GET", "/users/"+tc.email+"/relationship/"+tc.other, nil)
+
+			// When
+			GetRelationshipController(c, mockFriendship)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestConditionalRequest(t *testing.T) {
+	updatedAt := time.Date(2021, 10, 1, 8, 0, 0, 0, time.UTC)
+	versions := []friendship.RelationshipVersion{{Email: "first@gmail.com", Version: 3, UpdatedAt: updatedAt}}
+	input := friendship.FrienshipServiceInput{RequestEmail: "first@gmail.com", TargetEmail: "second@gmail.com"}
+
+	get := func(ifNoneMatch string, caller string, versionError error) *httptest.ResponseRecorder {
+		mockFriendship := new(friendship.FrienshipMockService)
+		mockFriendship.On("GetRelationshipVersions", mock.Anything, []string{"first@gmail.com"}).Return(versions, versionError)
+		mockFriendship.On("GetRelationship", mock.Anything, input, mock.Anything).Return(friendship.Relationship{User: "first@gmail.com", Other: "second@gmail.com"}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		auth.SetCaller(c, caller)
+		c.Params = gin.Params{{Key: "email", Value: "first@gmail.com"}, {Key: "other", Value: "second@gmail.com"}}
+		c.Request, _ = http.NewRequest("GET", "/users/first@gmail.com/relationship/second@gmail.com", nil)
+		if ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		GetRelationshipController(c, mockFriendship)
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	first := get("", "", nil)
+	etag := first.Header().Get("ETag")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+	assert.Equal(t, "Fri, 01 Oct 2021 08:00:00 GMT", first.Header().Get("Last-Modified"))
+	assert.Equal(t, "no-cache", first.Header().Get("Cache-Control"))
+
+	testCase := []struct {
+		scenario       string
+		ifNoneMatch    string
+		caller         string
+		versionError   error
+		expectedStatus int
+		expectedETag   bool
+	}{
+		{
+			scenario:       "Not Modified",
+			ifNoneMatch:    etag,
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   true,
+		},
+		{
+			scenario:       "One Of ETags Matches",
+			ifNoneMatch:    `"other", ` + strings.TrimPrefix(etag, "W/"),
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   true,
+		},
+		{
+			scenario:       "Modified",
+			ifNoneMatch:    `W/"other"`,
+			expectedStatus: http.StatusOK,
+			expectedETag:   true,
+		},
+		{
+			scenario:       "Other Caller",
+			ifNoneMatch:    etag,
+			caller:         "first@gmail.com",
+			expectedStatus: http.StatusOK,
+			expectedETag:   true,
+		},
+		{
+			scenario:       "Version Not Available",
+			ifNoneMatch:    etag,
+			versionError:   errors.New("Any Error"),
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			w := get(tc.ifNoneMatch, tc.caller, tc.versionError)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedETag, w.Header().Get("ETag") != "")
+			if tc.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+
+	// A change of the graph index answering the lists is a new ETag
+	versions[0].IndexVersion = 1
+	assert.Equal(t, http.StatusOK, get(etag, "", nil).Code)
+}
@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"friend_connection_rest_api/services/friendship"
+)
+
+// RequestGraphQL is a GraphQL query sent to /graphql
+type RequestGraphQL struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// userNode is the source of the fields of an user
+type userNode struct {
+	Email string `json:"email"`
+}
+
+// requestKey is the context key of the request state
+type requestKey struct{}
+
+// request is the state of one GraphQL query, loaders are shared by all its fields
+type request struct {
+	caller  string
+	service friendship.FrienshipServices
+	mutex   sync.Mutex
+	loaders map[string]*Loader
+}
+
+func withRequest(ctx context.Context, r *request) context.Context {
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+func fromContext(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+// loader return the loader of name, it is created with batch on first use
+func (r *request) loader(name string, batch batchFunc) *Loader {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l, ok := r.loaders[name]
+	if !ok {
+		l = NewLoader(batch)
+		r.loaders[name] = l
+	}
+	return l
+}
+
+// friendsLoader read the friend lists visible to the caller
+func (r *request) friendsLoader() *Loader {
+	return r.loader("friends", func(ctx context.Context, keys []string) ([]result, error) {
+		rs, err := r.service.GetFriendsLists(ctx, keys, r.caller)
+		if err != nil {
+			return nil, err
+		}
+		return toResults(rs), nil
+	})
+}
+
+// mutualFriendsLoader read the mutual friends of email with other users visible to the caller
+func (r *request) mutualFriendsLoader(email string) *Loader {
+	return r.loader("mutual_friends:"+email, func(ctx context.Context, keys []string) ([]result, error) {
+		rs, err := r.service.GetMutualFriendsLists(ctx, email, keys, r.caller)
+		if err != nil {
+			return nil, err
+		}
+		return toResults(rs), nil
+	})
+}
+
+// relationshipLoader read the relationships of email with other users, the caller must be one of the pair
+func (r *request) relationshipLoader(email string) *Loader {
+	return r.loader("relationship:"+email, func(ctx context.Context, keys []string) ([]result, error) {
+		rs, err := r.service.GetRelationships(ctx, email, keys, r.caller)
+		if err != nil {
+			return nil, err
+		}
+		listResults := make([]result, 0, len(rs))
+		for _, relationship := range rs {
+			listResults = append(listResults, result{value: relationship, err: relationship.Err})
+		}
+		return listResults, nil
+	})
+}
+
+func toResults(rs []friendship.FriendsList) []result {
+	listResults := make([]result, 0, len(rs))
+	for _, list := range rs {
+		listResults = append(listResults, result{value: list.Friends, err: list.Err})
+	}
+	return listResults
+}
@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"net/http"
+
+	"friend_connection_rest_api/controller/auth"
+	httpRes "friend_connection_rest_api/controller/common_respone"
+
+	"github.com/gin-gonic/gin"
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// GraphQLController execute a GraphQL query as the authenticated caller. A query which can not be parsed, is invalid
+// or exceeds the depth or complexity limits is rejected with 400, errors of the fields are returned with the data
+func GraphQLController(c *gin.Context, schema *Schema) {
+	var req RequestGraphQL
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	validation := gql.ValidateDocument(&schema.schema, doc, nil)
+	if !validation.IsValid {
+		c.JSON(http.StatusBadRequest, gql.Result{Errors: validation.Errors})
+		return
+	}
+
+	if err := checkLimits(doc, req.OperationName, req.Variables, schema.maxDepth, schema.maxComplexity); err != nil {
+		c.JSON(http.StatusBadRequest, gql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	// Loaders live for the request only, so a field never reads the writes of another request from them
+	ctx := withRequest(c.Request.Context(), &request{
+		caller:  auth.Caller(c),
+		service: schema.service,
+		loaders: map[string]*Loader{},
+	})
+
+	rs := gql.Execute(gql.ExecuteParams{
+		Schema:        schema.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	c.JSON(http.StatusOK, rs)
+}
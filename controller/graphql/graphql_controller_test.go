@@ -0,0 +1,310 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"friend_connection_rest_api/controller/auth"
+	"friend_connection_rest_api/services/friendship"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type responeGraphQL struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path"`
+	} `json:"errors"`
+}
+
+func executeQuery(t *testing.T, service friendship.FrienshipServices, caller string, req RequestGraphQL) (int, responeGraphQL) {
+	schema, err := NewSchema(service, 5, 500)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	auth.SetCaller(c, caller)
+	jsonValue, _ := json.Marshal(req)
+	c.Request, _ = http.NewRequest("POST", "/graphql", bytes.NewBuffer(jsonValue))
+
+	GraphQLController(c, schema)
+
+	respone := responeGraphQL{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &respone))
+	return w.Code, respone
+}
+
+func TestGraphQLNestedQuery(t *testing.T) {
+	mockFriendship := new(friendship.FrienshipMockService)
+	mockFriendship.On("GetFriendsLists", mock.Anything, []string{"gema@gmail.com"}, "gema@gmail.com").
+		Return([]friendship.FriendsList{{Email: "gema@gmail.com", Friends: []string{"arel@gmail.com", "andy@gmail.com"}}}, nil)
+	mockFriendship.On("GetMutualFriendsLists", mock.Anything, "gema@gmail.com", []string{"arel@gmail.com", "andy@gmail.com"}, "gema@gmail.com").
+		Return([]friendship.FriendsList{
+			{Email: "arel@gmail.com", Friends: []string{"andy@gmail.com"}},
+			{Email: "andy@gmail.com", Err: friendship.ErrPrivacyRestricted},
+		}, nil)
+	mockFriendship.On("GetRelationships", mock.Anything, "gema@gmail.com", []string{"arel@gmail.com", "andy@gmail.com"}, "gema@gmail.com").
+		Return([]friendship.Relationship{
+			{User: "gema@gmail.com", Other: "arel@gmail.com", AreFriends: true, UserFollowsOther: true},
+			{User: "gema@gmail.com", Other: "andy@gmail.com", AreFriends: true},
+		}, nil)
+
+	status, respone := executeQuery(t, mockFriendship, "gema@gmail.com", RequestGraphQL{Query: `{
+		me {
+			email
+			friendCount
+			friends {
+				email
+				mutualFriends(first: 5) { email }
+				relationship { userFollowsOther connectedSince }
+			}
+		}
+	}`})
+
+	// Each level is read with one call of the service
+	assert.Equal(t, http.StatusOK, status)
+	mockFriendship.AssertNumberOfCalls(t, "GetFriendsLists", 1)
+	mockFriendship.AssertNumberOfCalls(t, "GetMutualFriendsLists", 1)
+	mockFriendship.AssertNumberOfCalls(t, "GetRelationships", 1)
+
+	me := respone.Data["me"].(map[string]interface{})
+	assert.Equal(t, "gema@gmail.com", me["email"])
+	assert.Equal(t, float64(2), me["friendCount"])
+
+	friends := me["friends"].([]interface{})
+	assert.Equal(t, 2, len(friends))
+	arel := friends[0].(map[string]interface{})
+	assert.Equal(t, "arel@gmail.com", arel["email"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"email": "andy@gmail.com"}}, arel["mutualFriends"])
+	assert.Equal(t, map[string]interface{}{"userFollowsOther": true, "connectedSince": nil}, arel["relationship"])
+
+	// List hidden by privacy settings is null with an error, the rest of the data is returned
+	andy := friends[1].(map[string]interface{})
+	assert.Nil(t, andy["mutualFriends"])
+	assert.Equal(t, map[string]interface{}{"userFollowsOther": false, "connectedSince": nil}, andy["relationship"])
+	assert.Equal(t, 1, len(respone.Errors))
+	assert.Equal(t, friendship.ErrPrivacyRestricted.Error(), respone.Errors[0].Message)
+	assert.Equal(t, []interface{}{"me", "friends", float64(1), "mutualFriends"}, respone.Errors[0].Path)
+}
+
+func TestGraphQLFriendsOfFriends(t *testing.T) {
+	mockFriendship := new(friendship.FrienshipMockService)
+	mockFriendship.On("GetFriendsLists", mock.Anything, []string{"gema@gmail.com"}, "").
+		Return([]friendship.FriendsList{{Email: "gema@gmail.com", Friends: []string{"arel@gmail.com", "andy@gmail.com", "john@gmail.com"}}}, nil)
+	mockFriendship.On("GetFriendsLists", mock.Anything, []string{"arel@gmail.com", "andy@gmail.com"}, "").
+		Return([]friendship.FriendsList{
+			{Email: "arel@gmail.com", Friends: []string{"gema@gmail.com"}},
+			{Email: "andy@gmail.com", Friends: []string{"gema@gmail.com", "arel@gmail.com"}},
+		}, nil)
+
+	status, respone := executeQuery(t, mockFriendship, "", RequestGraphQL{
+		Query:     `query Friends($email: String!, $first: Int) { user(email: $email) { friends(first: $first) { email friendCount } } }`,
+		Variables: map[string]interface{}{"email": "gema@gmail.com", "first": 2},
+	})
+
+	// First limits the list, friend lists of the friends are read together
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, len(respone.Errors))
+	mockFriendship.AssertNumberOfCalls(t, "GetFriendsLists", 2)
+	assert.Equal(t, map[string]interface{}{"friends": []interface{}{
+		map[string]interface{}{"email": "arel@gmail.com", "friendCount": float64(1)},
+		map[string]interface{}{"email": "andy@gmail.com", "friendCount": float64(2)},
+	}}, respone.Data["user"])
+}
+
+func TestGraphQLFieldErrors(t *testing.T) {
+	mockFriendship := new(friendship.FrienshipMockService)
+	mockFriendship.On("GetUsersReceiveUpdate", mock.Anything, "gema@gmail.com", []string{"andy@gmail.com"}, "").
+		Return([]string{"arel@gmail.com", "andy@gmail.com", "arel@gmail.com"}, nil)
+	mockFriendship.On("GetRelationships", mock.Anything, "gema@gmail.com", []string{"arel@gmail.com"}, "gema@gmail.com").
+		Return([]friendship.Relationship{}, errors.New("User Not Exist"))
+	mockFriendship.On("GetRelationships", mock.Anything, "gema@gmail.com", []string{"arel@gmail.com"}, "andy@gmail.com").
+		Return([]friendship.Relationship{{User: "gema@gmail.com", Other: "arel@gmail.com", Err: friendship.ErrPrivacyRestricted}}, nil)
+
+	testCase := []struct {
+		scenario       string
+		caller         string
+		query          string
+		expectedData   interface{}
+		expectedErrors []string
+	}{
+		{
+			scenario:     "Anonymous me",
+			query:        `{ me { email } }`,
+			expectedData: map[string]interface{}{"me": nil},
+		},
+		{
+			scenario:       "Mutual friends without caller",
+			query:          `{ user(email: "arel@gmail.com") { mutualFriends { email } } }`,
+			expectedData:   map[string]interface{}{"user": map[string]interface{}{"mutualFriends": nil}},
+			expectedErrors: []string{"Authentication Required"},
+		},
+		{
+			scenario:       "Email invalid",
+			query:          `{ user(email: "abc") { email } }`,
+			expectedData:   map[string]interface{}{"user": nil},
+			expectedErrors: []string{"Email Invalid Format"},
+		},
+		{
+			scenario:       "First out of range",
+			caller:         "gema@gmail.com",
+			query:          `{ me { friends(first: 1000) { email } } }`,
+			expectedData:   map[string]interface{}{"me": map[string]interface{}{"friends": nil}},
+			expectedErrors: []string{"First Must Be Between 0 And 100"},
+		},
+		{
+			scenario:       "Batch error",
+			caller:         "gema@gmail.com",
+			query:          `{ relationship(email: "gema@gmail.com", other: "arel@gmail.com") { areFriends } }`,
+			expectedData:   map[string]interface{}{"relationship": nil},
+			expectedErrors: []string{"User Not Exist"},
+		},
+		{
+			scenario:       "Relationship of other users",
+			caller:         "andy@gmail.com",
+			query:          `{ relationship(email: "gema@gmail.com", other: "arel@gmail.com") { areFriends } }`,
+			expectedData:   map[string]interface{}{"relationship": nil},
+			expectedErrors: []string{"Restricted By Privacy Settings"},
+		},
+		{
+			scenario:     "Update recipients",
+			caller:       "gema@gmail.com",
+			query:        `{ updateRecipients(sender: "gema@gmail.com", text: "Hello @andy@gmail.com") }`,
+			expectedData: map[string]interface{}{"updateRecipients": []interface{}{"arel@gmail.com", "andy@gmail.com"}},
+		},
+		{
+			scenario:       "Update of another user",
+			caller:         "arel@gmail.com",
+			query:          `{ updateRecipients(sender: "gema@gmail.com", text: "Hello") }`,
+			expectedData:   map[string]interface{}{"updateRecipients": nil},
+			expectedErrors: []string{"Permission Denied"},
+		},
+		{
+			scenario:       "Update without caller",
+			query:          `{ updateRecipients(sender: "gema@gmail.com", text: "Hello") }`,
+			expectedData:   map[string]interface{}{"updateRecipients": nil},
+			expectedErrors: []string{"Authentication Required"},
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			status, respone := executeQuery(t, mockFriendship, tc.caller, RequestGraphQL{Query: tc.query})
+
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, tc.expectedData, map[string]interface{}(respone.Data))
+			messages := []string{}
+			for _, err := range respone.Errors {
+				messages = append(messages, err.Message)
+			}
+			assert.ElementsMatch(t, tc.expectedErrors, messages)
+		})
+	}
+}
+
+func TestGraphQLRejectedQuery(t *testing.T) {
+	testCase := []struct {
+		scenario      string
+		request       RequestGraphQL
+		expectedError string
+	}{
+		{
+			scenario:      "Syntax error",
+			request:       RequestGraphQL{Query: `{ me { email }`},
+			expectedError: "Syntax Error GraphQL request (1:15) Expected Name, found EOF\n\n1: { me { email }\n                 ^\n",
+		},
+		{
+			scenario:      "Unknown field",
+			request:       RequestGraphQL{Query: `{ me { password } }`},
+			expectedError: `Cannot query field "password" on type "User".`,
+		},
+		{
+			scenario:      "Too deep",
+			request:       RequestGraphQL{Query: `{ me { friends(first: 1) { friends(first: 1) { friends(first: 1) { friends(first: 1) { email } } } } } }`},
+			expectedError: "Query Depth Exceeds Limit Of 5",
+		},
+		{
+			scenario:      "Too deep with fragment",
+			request:       RequestGraphQL{Query: `{ me { ...Friends } } fragment Friends on User { friends(first: 1) { friends(first: 1) { friends(first: 1) { friends(first: 1) { email } } } } }`},
+			expectedError: "Query Depth Exceeds Limit Of 5",
+		},
+		{
+			scenario:      "Too deep introspection",
+			request:       RequestGraphQL{Query: `{ __schema { types { fields { type { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { name } } } } } } } } } } } } } } } }`},
+			expectedError: "Query Depth Exceeds Limit Of 15",
+		},
+		{
+			scenario:      "Too complex introspection",
+			request:       RequestGraphQL{Query: `{ __schema { types { ...Type } } } fragment Type on __Type { ` + strings.Repeat("name ", 500) + `}`},
+			expectedError: "Query Complexity Exceeds Limit Of 500",
+		},
+		{
+			scenario:      "Too complex",
+			request:       RequestGraphQL{Query: `{ me { friends(first: 30) { friends { email } } } }`},
+			expectedError: "Query Complexity Exceeds Limit Of 500",
+		},
+		{
+			scenario: "Too complex with variable",
+			request: RequestGraphQL{
+				Query:     `query Friends($first: Int) { me { friends(first: $first) { friends(first: $first) { email } } } }`,
+				Variables: map[string]interface{}{"first": 100},
+			},
+			expectedError: "Query Complexity Exceeds Limit Of 500",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockFriendship := new(friendship.FrienshipMockService)
+
+			status, respone := executeQuery(t, mockFriendship, "gema@gmail.com", tc.request)
+
+			// Rejected query is not executed
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Equal(t, 1, len(respone.Errors))
+			assert.Equal(t, tc.expectedError, respone.Errors[0].Message)
+			mockFriendship.AssertNotCalled(t, "GetFriendsLists", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestLoader(t *testing.T) {
+	listBatches := [][]string{}
+	loader := NewLoader(func(ctx context.Context, keys []string) ([]result, error) {
+		listBatches = append(listBatches, keys)
+		listResults := []result{}
+		for _, key := range keys {
+			listResults = append(listResults, result{value: key + "!"})
+		}
+		return listResults, nil
+	})
+	ctx := context.Background()
+
+	// Keys loaded before the first thunk is called are read together, once each
+	first := loader.Load(ctx, "a")
+	second := loader.Load(ctx, "b")
+	again := loader.Load(ctx, "a")
+	value, err := second()
+	assert.NoError(t, err)
+	assert.Equal(t, "b!", value)
+	value, _ = first()
+	assert.Equal(t, "a!", value)
+	value, _ = again()
+	assert.Equal(t, "a!", value)
+
+	// Key already read is not read again
+	value, _ = loader.Load(ctx, "a")()
+	assert.Equal(t, "a!", value)
+	value, _ = loader.Load(ctx, "c")()
+	assert.Equal(t, "c!", value)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, listBatches)
+}
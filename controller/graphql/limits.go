@@ -0,0 +1,135 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listFields are the fields returning a list of users, their selection is resolved for each user
+var listFields = map[string]bool{"friends": true, "mutualFriends": true}
+
+// maxIntrospectionDepth is the depth limit of introspection fields and their selection, it is above maxDepth
+// because type references of the introspection query used by the tools are nested by ofType
+const maxIntrospectionDepth = 15
+
+// limits measure the depth and the complexity of an operation, fragments are inlined where they are spread
+type limits struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	maxDepth  int
+}
+
+// checkLimits return an error when the operation is deeper than maxDepth fields or more complex than maxComplexity.
+// Each field costs 1 and the selection of a list field costs its cost for each user of the list, i.e. its first argument.
+// Introspection fields cost like other fields, their depth is limited by maxIntrospectionDepth.
+// The document must be valid, so fragments have no cycle
+func checkLimits(doc *ast.Document, operationName string, variables map[string]interface{}, maxDepth int, maxComplexity int) error {
+	l := limits{fragments: map[string]*ast.FragmentDefinition{}, variables: variables, maxDepth: maxDepth}
+
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			l.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return fmt.Errorf("Unknown Operation %q", operationName)
+	}
+
+	complexity, err := l.measure(operation.SelectionSet, 0, false, maxComplexity)
+	if err != nil {
+		return err
+	}
+	if complexity > maxComplexity {
+		return fmt.Errorf("Query Complexity Exceeds Limit Of %d", maxComplexity)
+	}
+	return nil
+}
+
+// measure return the complexity of a selection set at depth, it stops as soon as the complexity exceeds budget.
+// introspection is true in the selection of an introspection field
+func (l *limits) measure(selectionSet *ast.SelectionSet, depth int, introspection bool, budget int) (int, error) {
+	if selectionSet == nil {
+		return 0, nil
+	}
+
+	complexity := 0
+	for _, selection := range selectionSet.Selections {
+		var cost int
+		var err error
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			introspection := introspection || strings.HasPrefix(selection.Name.Value, "__")
+			maxDepth := l.maxDepth
+			if introspection {
+				maxDepth = maxIntrospectionDepth
+			}
+			if depth+1 > maxDepth {
+				return 0, fmt.Errorf("Query Depth Exceeds Limit Of %d", maxDepth)
+			}
+			size := l.listSize(selection)
+			cost, err = l.measure(selection.SelectionSet, depth+1, introspection, budget/size+1)
+			cost = 1 + size*cost
+		case *ast.InlineFragment:
+			cost, err = l.measure(selection.SelectionSet, depth, introspection, budget)
+		case *ast.FragmentSpread:
+			if fragment, ok := l.fragments[selection.Name.Value]; ok {
+				cost, err = l.measure(fragment.SelectionSet, depth, introspection, budget)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		complexity += cost
+		if complexity > budget {
+			return complexity, nil
+		}
+	}
+	return complexity, nil
+}
+
+// listSize return the number of users a field resolves its selection for, 1 when it is not a list of users
+func (l *limits) listSize(field *ast.Field) int {
+	if !listFields[field.Name.Value] {
+		return 1
+	}
+
+	size := defaultFirst
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				size = n
+			}
+		case *ast.Variable:
+			switch n := l.variables[value.Name.Value].(type) {
+			case float64:
+				size = int(n)
+			case int:
+				size = n
+			}
+		}
+	}
+
+	// First out of range is rejected by the resolver
+	if size < 1 {
+		return 1
+	}
+	if size > maxFirst {
+		return maxFirst
+	}
+	return size
+}
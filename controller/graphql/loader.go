@@ -0,0 +1,78 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// result is the value of a key read by a batch, err is the error of this key only
+type result struct {
+	value interface{}
+	err   error
+}
+
+// batchFunc read the values of keys with one call, results are in the order of keys.
+// An error is returned for all keys when the batch fails
+type batchFunc func(ctx context.Context, keys []string) ([]result, error)
+
+// Loader batch the keys loaded while resolving one level of a query and read them with one call,
+// each key is read once by request
+type Loader struct {
+	mutex   sync.Mutex
+	batch   batchFunc
+	pending []string
+	results map[string]result
+}
+
+// NewLoader initializes loader reading keys with batch
+func NewLoader(batch batchFunc) *Loader {
+	return &Loader{
+		batch:   batch,
+		results: map[string]result{},
+	}
+}
+
+// Load queue key in the current batch and return a thunk resolving its value. The batch is read when the first thunk
+// is called, the executor calls them once all the fields of a level are resolved, so their keys are read together
+func (l *Loader) Load(ctx context.Context, key string) func() (interface{}, error) {
+	l.mutex.Lock()
+	if _, ok := l.results[key]; !ok && !contains(l.pending, key) {
+		l.pending = append(l.pending, key)
+	}
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		if _, ok := l.results[key]; !ok {
+			l.dispatch(ctx)
+		}
+		rs := l.results[key]
+		return rs.value, rs.err
+	}
+}
+
+// dispatch read the pending keys, it must be called with the lock held
+func (l *Loader) dispatch(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+
+	listResults, err := l.batch(ctx, keys)
+	for i, key := range keys {
+		if err != nil {
+			l.results[key] = result{err: err}
+			continue
+		}
+		l.results[key] = listResults[i]
+	}
+}
+
+func contains(list []string, element string) bool {
+	for _, e := range list {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
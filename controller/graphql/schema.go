@@ -0,0 +1,338 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"friend_connection_rest_api/services/friendship"
+	"friend_connection_rest_api/utils"
+
+	gql "github.com/graphql-go/graphql"
+)
+
+const (
+	// defaultFirst is the number of users returned by a list field without first argument
+	defaultFirst = 20
+	// maxFirst is the maximum first argument of a list field
+	maxFirst = 100
+)
+
+// Schema is the GraphQL schema over users, friendships, relationships and updates with the limits of a query
+type Schema struct {
+	schema        gql.Schema
+	service       friendship.FrienshipServices
+	maxDepth      int
+	maxComplexity int
+}
+
+// NewSchema initializes the schema resolving fields with service, a query deeper than maxDepth fields
+// or more complex than maxComplexity is rejected before it is executed
+func NewSchema(service friendship.FrienshipServices, maxDepth int, maxComplexity int) (*Schema, error) {
+	relationshipType := gql.NewObject(gql.ObjectConfig{
+		Name:        "Relationship",
+		Description: "Connection between user and other seen from user, times are RFC 3339",
+		Fields: gql.Fields{
+			"user":                {Type: gql.NewNonNull(gql.String)},
+			"other":               {Type: gql.NewNonNull(gql.String)},
+			"areFriends":          {Type: gql.NewNonNull(gql.Boolean)},
+			"userFollowsOther":    {Type: gql.NewNonNull(gql.Boolean)},
+			"otherFollowsUser":    {Type: gql.NewNonNull(gql.Boolean)},
+			"userBlocksOther":     {Type: gql.NewNonNull(gql.Boolean)},
+			"otherBlocksUser":     {Type: gql.NewNonNull(gql.Boolean)},
+			"pendingRequest":      {Type: gql.NewNonNull(gql.Boolean)},
+			"pendingRequestFrom":  {Type: gql.String},
+			"connectedSince":      {Type: gql.String},
+			"updatedAt":           {Type: gql.String},
+			"pendingRequestSince": {Type: gql.String},
+		},
+	})
+
+	firstArgument := &gql.ArgumentConfig{Type: gql.Int, DefaultValue: defaultFirst, Description: fmt.Sprintf("Number of users, at most %d", maxFirst)}
+	withArgument := &gql.ArgumentConfig{Type: gql.String, Description: "Other user, the authenticated caller when it is not set"}
+
+	var userType *gql.Object
+	userType = gql.NewObject(gql.ObjectConfig{
+		Name: "User",
+		Fields: gql.FieldsThunk(func() gql.Fields {
+			return gql.Fields{
+				"email": {Type: gql.NewNonNull(gql.String)},
+				"friends": {
+					Type:        gql.NewList(gql.NewNonNull(userType)),
+					Description: "Friends of the user, null when the caller is not allowed to see them",
+					Args:        gql.FieldConfigArgument{"first": firstArgument},
+					Resolve:     resolveFriends,
+				},
+				"friendCount": {
+					Type:    gql.Int,
+					Resolve: resolveFriendCount,
+				},
+				"mutualFriends": {
+					Type:        gql.NewList(gql.NewNonNull(userType)),
+					Description: "Friends of both the user and the other user",
+					Args:        gql.FieldConfigArgument{"with": withArgument, "first": firstArgument},
+					Resolve:     resolveMutualFriends,
+				},
+				"relationship": {
+					Type:        relationshipType,
+					Description: "Relationship of the other user with the user, seen from the other user",
+					Args:        gql.FieldConfigArgument{"with": withArgument},
+					Resolve:     resolveUserRelationship,
+				},
+			}
+		}),
+	})
+
+	queryType := gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"me": {
+				Type:        userType,
+				Description: "Authenticated caller, null for anonymous request",
+				Resolve:     resolveMe,
+			},
+			"user": {
+				Type:    userType,
+				Args:    gql.FieldConfigArgument{"email": {Type: gql.NewNonNull(gql.String)}},
+				Resolve: resolveUser,
+			},
+			"relationship": {
+				Type: relationshipType,
+				Args: gql.FieldConfigArgument{
+					"email": {Type: gql.NewNonNull(gql.String)},
+					"other": {Type: gql.NewNonNull(gql.String)},
+				},
+				Resolve: resolveRelationship,
+			},
+			"updateRecipients": {
+				Type:        gql.NewList(gql.NewNonNull(gql.String)),
+				Description: "Users receiving an update of sender, like /get-list-users-receive-update",
+				Args: gql.FieldConfigArgument{
+					"sender": {Type: gql.NewNonNull(gql.String)},
+					"text":   {Type: gql.NewNonNull(gql.String)},
+					"circle": {Type: gql.String},
+				},
+				Resolve: resolveUpdateRecipients,
+			},
+		},
+	})
+
+	schema, err := gql.NewSchema(gql.SchemaConfig{Query: queryType})
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{schema: schema, service: service, maxDepth: maxDepth, maxComplexity: maxComplexity}, nil
+}
+
+func resolveMe(p gql.ResolveParams) (interface{}, error) {
+	caller := fromContext(p.Context).caller
+	if caller == "" {
+		return nil, nil
+	}
+	return userNode{Email: caller}, nil
+}
+
+func resolveUser(p gql.ResolveParams) (interface{}, error) {
+	email, _ := p.Args["email"].(string)
+	if utils.ValidateEmail(email) == false {
+		return nil, errors.New("Email Invalid Format")
+	}
+	return userNode{Email: email}, nil
+}
+
+func resolveRelationship(p gql.ResolveParams) (interface{}, error) {
+	email, _ := p.Args["email"].(string)
+	other, _ := p.Args["other"].(string)
+	if err := validatePair(email, other); err != nil {
+		return nil, err
+	}
+	return loadRelationship(p, email, other), nil
+}
+
+func resolveUpdateRecipients(p gql.ResolveParams) (interface{}, error) {
+	r := fromContext(p.Context)
+	sender, _ := p.Args["sender"].(string)
+	text, _ := p.Args["text"].(string)
+	circle, _ := p.Args["circle"].(string)
+
+	if utils.ValidateEmail(sender) == false {
+		return nil, errors.New("Email Invalid Format")
+	}
+
+	if r.caller == "" {
+		return nil, errors.New("Authentication Required")
+	}
+	if r.caller != sender {
+		return nil, errors.New("Permission Denied")
+	}
+
+	rs, err := r.service.GetUsersReceiveUpdate(p.Context, sender, utils.ExtractMentionEmail(text), circle)
+	if err != nil {
+		return nil, err
+	}
+	return removeDuplicates(rs), nil
+}
+
+func resolveFriends(p gql.ResolveParams) (interface{}, error) {
+	first, err := firstArg(p)
+	if err != nil {
+		return nil, err
+	}
+
+	email := p.Source.(userNode).Email
+	thunk := fromContext(p.Context).friendsLoader().Load(p.Context, email)
+	return func() (interface{}, error) {
+		friends, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return toUserNodes(friends.([]string), first), nil
+	}, nil
+}
+
+func resolveFriendCount(p gql.ResolveParams) (interface{}, error) {
+	email := p.Source.(userNode).Email
+	thunk := fromContext(p.Context).friendsLoader().Load(p.Context, email)
+	return func() (interface{}, error) {
+		friends, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return len(friends.([]string)), nil
+	}, nil
+}
+
+func resolveMutualFriends(p gql.ResolveParams) (interface{}, error) {
+	first, err := firstArg(p)
+	if err != nil {
+		return nil, err
+	}
+
+	email := p.Source.(userNode).Email
+	with, err := withArg(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePair(with, email); err != nil {
+		return nil, err
+	}
+
+	thunk := fromContext(p.Context).mutualFriendsLoader(with).Load(p.Context, email)
+	return func() (interface{}, error) {
+		friends, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return toUserNodes(friends.([]string), first), nil
+	}, nil
+}
+
+func resolveUserRelationship(p gql.ResolveParams) (interface{}, error) {
+	email := p.Source.(userNode).Email
+	with, err := withArg(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePair(with, email); err != nil {
+		return nil, err
+	}
+	return loadRelationship(p, with, email), nil
+}
+
+// loadRelationship return a thunk resolving the relationship of email with other
+func loadRelationship(p gql.ResolveParams, email string, other string) func() (interface{}, error) {
+	thunk := fromContext(p.Context).relationshipLoader(email).Load(p.Context, other)
+	return func() (interface{}, error) {
+		relationship, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+		return toRelationshipMap(relationship.(friendship.Relationship)), nil
+	}
+}
+
+// firstArg return the first argument of a list field
+func firstArg(p gql.ResolveParams) (int, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 0 || first > maxFirst {
+		return 0, fmt.Errorf("First Must Be Between 0 And %d", maxFirst)
+	}
+	return first, nil
+}
+
+// withArg return the with argument of a field, it is the caller when it is not set
+func withArg(p gql.ResolveParams) (string, error) {
+	with, _ := p.Args["with"].(string)
+	if with == "" {
+		with = fromContext(p.Context).caller
+	}
+	if with == "" {
+		return "", errors.New("Authentication Required")
+	}
+	return with, nil
+}
+
+// validatePair check the two emails of a field are valid and distinct
+func validatePair(first string, second string) error {
+	if first == second {
+		return errors.New("Request Invalid")
+	}
+	if utils.ValidateEmail(first) == false || utils.ValidateEmail(second) == false {
+		return errors.New("Email Invalid Format")
+	}
+	return nil
+}
+
+func toUserNodes(emails []string, first int) []userNode {
+	if len(emails) > first {
+		emails = emails[:first]
+	}
+	nodes := make([]userNode, 0, len(emails))
+	for _, email := range emails {
+		nodes = append(nodes, userNode{Email: email})
+	}
+	return nodes
+}
+
+func toRelationshipMap(rs friendship.Relationship) map[string]interface{} {
+	return map[string]interface{}{
+		"user":                rs.User,
+		"other":               rs.Other,
+		"areFriends":          rs.AreFriends,
+		"userFollowsOther":    rs.UserFollowsOther,
+		"otherFollowsUser":    rs.OtherFollowsUser,
+		"userBlocksOther":     rs.UserBlocksOther,
+		"otherBlocksUser":     rs.OtherBlocksUser,
+		"pendingRequest":      rs.PendingRequest,
+		"pendingRequestFrom":  optionalString(rs.PendingRequestFrom),
+		"connectedSince":      formatTime(rs.ConnectedSince),
+		"updatedAt":           formatTime(rs.UpdatedAt),
+		"pendingRequestSince": formatTime(rs.PendingRequestSince),
+	}
+}
+
+func optionalString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func formatTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func removeDuplicates(elements []string) []string {
+	encountered := map[string]bool{}
+	result := []string{}
+	for _, element := range elements {
+		if !encountered[element] {
+			encountered[element] = true
+			result = append(result, element)
+		}
+	}
+	return result
+}
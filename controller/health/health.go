@@ -0,0 +1,5 @@
+package health
+
+type ResponeHealth struct {
+	Status string `json:"status" example:"ok"`
+}
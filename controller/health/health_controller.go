@@ -0,0 +1,38 @@
+package health
+
+import (
+	"net/http"
+
+	httpRes "friend_connection_rest_api/controller/common_respone"
+	"friend_connection_rest_api/services/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LivenessController godoc
+// @Summary Liveness probe
+// @Description Process is alive, it does not depend on the database
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} ResponeHealth
+// @Router /healthz [get]
+func LivenessController(c *gin.Context) {
+	c.JSON(http.StatusOK, ResponeHealth{Status: "ok"})
+}
+
+// ReadinessController godoc
+// @Summary Readiness probe
+// @Description Database is reachable and migrations are applied
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} ResponeHealth
+// @Failure 503 {object} httpRes.HTTPError
+// @Router /readyz [get]
+func ReadinessController(c *gin.Context, service health.HealthServices) {
+	if err := service.CheckReady(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponeHealth{Status: "ready"})
+}
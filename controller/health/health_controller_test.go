@@ -0,0 +1,71 @@
+package health
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"friend_connection_rest_api/services/health"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLivenessController(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/healthz", nil)
+
+	LivenessController(c)
+
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"status":"ok"}`, string(body))
+}
+
+func TestReadinessController(t *testing.T) {
+	testCase := []struct {
+		scenario       string
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Ready",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ready"}`,
+		},
+		{
+			scenario:       "Database Unreachable",
+			mockError:      errors.New("dial tcp: connection refused"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"error":"dial tcp: connection refused"}`,
+		},
+		{
+			scenario:       "Migration Not Applied",
+			mockError:      errors.New("Migration Not Applied"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"error":"Migration Not Applied"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockHealth := new(health.HealthMockService)
+			mockHealth.On("CheckReady", mock.Anything).Return(tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/readyz", nil)
+
+			ReadinessController(c, mockHealth)
+
+			body, _ := ioutil.ReadAll(w.Body)
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"friend_connection_rest_api/controller/auth"
+	httpRes "friend_connection_rest_api/controller/common_respone"
+	idempotencyService "friend_connection_rest_api/services/idempotency"
+	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderIdempotencyKey is the header a client send the same value in when it retries a request
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderReplayed is set on a respone replayed from a previous request
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+// maxKeyLength is the maximum length of an idempotency key
+const maxKeyLength = 255
+
+// storeTimeout is the timeout of storing a respone or releasing a key, the request context is not used
+// because it is cancelled when the request timed out after the writes of the service were committed
+const storeTimeout = 5 * time.Second
+
+// Idempotency replay the respone of the first request sent with the same Idempotency-Key header, keys are scoped by
+// route and caller. The key is rejected when it is sent with another body or while its first request is processed.
+// A request failing with 5xx releases its key so it can be retried
+func Idempotency(service idempotencyService.IdempotencyServices) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Idempotency Key Invalid"})
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, httpRes.HTTPError{Message: err.Error()})
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		scopedKey := c.FullPath() + "|" + auth.Caller(c) + "|" + key
+		fingerprint := utils.HashToken(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n" + string(body))
+
+		record, err := service.Begin(c.Request.Context(), scopedKey, fingerprint)
+		if err != nil {
+			switch {
+			case errors.Is(err, idempotencyService.ErrKeyReused):
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, httpRes.HTTPError{Message: err.Error()})
+			case errors.Is(err, idempotencyService.ErrKeyInProgress):
+				c.AbortWithStatusJSON(http.StatusConflict, httpRes.HTTPError{Message: err.Error()})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, httpRes.HTTPError{Message: err.Error()})
+			}
+			return
+		}
+
+		if record != nil {
+			c.Header(HeaderReplayed, "true")
+			c.Data(record.Status, gin.MIMEJSON+"; charset=utf-8", record.Body)
+			c.Abort()
+			return
+		}
+
+		writer := &recordWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		// Key is released when the handler panics
+		defer func() {
+			if !completed {
+				release(c, service, scopedKey)
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if !c.Writer.Written() || status >= http.StatusInternalServerError {
+			release(c, service, scopedKey)
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			defer cancel()
+			if err := service.Complete(ctx, scopedKey, status, writer.body.Bytes()); err != nil {
+				logger.FromContext(c.Request.Context()).Warn("store idempotent respone failed", "error", err)
+				release(c, service, scopedKey)
+			}
+		}
+		completed = true
+	}
+}
+
+func release(c *gin.Context, service idempotencyService.IdempotencyServices, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	if err := service.Release(ctx, key); err != nil {
+		logger.FromContext(c.Request.Context()).Warn("release idempotency key failed", "error", err)
+	}
+}
+
+// recordWriter keep a copy of the respone body so it can be replayed
+type recordWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,147 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	idempotencyService "friend_connection_rest_api/services/idempotency"
+	"friend_connection_rest_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdempotency(t *testing.T) {
+	body := `{"friends":["abc@gmail.com","xyz@gmail.com"]}`
+	scopedKey := "/add-friends||key-1"
+	fingerprint := utils.HashToken("POST /add-friends\n" + body)
+
+	testCase := []struct {
+		scenario         string
+		key              string
+		handlerStatus    int
+		mockRecord       *idempotencyService.IdempotencyKey
+		mockError        error
+		expectedStatus   int
+		expectedBody     string
+		expectedCalls    int
+		expectedComplete bool
+		expectedRelease  bool
+		expectedReplayed bool
+	}{
+		{
+			scenario:       "Without Key",
+			handlerStatus:  http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true}`,
+			expectedCalls:  1,
+		},
+		{
+			scenario:         "First Request",
+			key:              "key-1",
+			handlerStatus:    http.StatusOK,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"success":true}`,
+			expectedCalls:    1,
+			expectedComplete: true,
+		},
+		{
+			scenario:         "Client Error Is Stored",
+			key:              "key-1",
+			handlerStatus:    http.StatusBadRequest,
+			expectedStatus:   http.StatusBadRequest,
+			expectedBody:     `{"success":true}`,
+			expectedCalls:    1,
+			expectedComplete: true,
+		},
+		{
+			scenario:        "Server Error Releases Key",
+			key:             "key-1",
+			handlerStatus:   http.StatusInternalServerError,
+			expectedStatus:  http.StatusInternalServerError,
+			expectedBody:    `{"success":true}`,
+			expectedCalls:   1,
+			expectedRelease: true,
+		},
+		{
+			scenario:         "Replay",
+			key:              "key-1",
+			mockRecord:       &idempotencyService.IdempotencyKey{Status: http.StatusOK, Body: []byte(`{"success":true}`)},
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"success":true}`,
+			expectedReplayed: true,
+		},
+		{
+			scenario:       "Key Reused",
+			key:            "key-1",
+			mockError:      idempotencyService.ErrKeyReused,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"error":"Idempotency Key Was Used With Another Request"}`,
+		},
+		{
+			scenario:       "Key In Progress",
+			key:            "key-1",
+			mockError:      idempotencyService.ErrKeyInProgress,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Request With This Idempotency Key Is In Progress"}`,
+		},
+		{
+			scenario:       "Store Error",
+			key:            "key-1",
+			mockError:      errors.New("Connection Refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Connection Refused"}`,
+		},
+		{
+			scenario:       "Key Too Long",
+			key:            strings.Repeat("k", maxKeyLength+1),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Idempotency Key Invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockService := new(idempotencyService.IdempotencyMockService)
+			mockService.On("Begin", mock.Anything, scopedKey, fingerprint).Return(tc.mockRecord, tc.mockError)
+			mockService.On("Complete", mock.Anything, scopedKey, tc.handlerStatus, []byte(`{"success":true}`)).Return(nil)
+			mockService.On("Release", mock.Anything, scopedKey).Return(nil)
+
+			calls := 0
+			r := gin.New()
+			r.POST("/add-friends", Idempotency(mockService), func(c *gin.Context) {
+				calls++
+				// Handler still reads the body
+				data, _ := c.GetRawData()
+				assert.Equal(t, body, string(data))
+				c.JSON(tc.handlerStatus, gin.H{"success": true})
+			})
+
+			req, _ := http.NewRequest(http.MethodPost, "/add-friends", strings.NewReader(body))
+			if tc.key != "" {
+				req.Header.Set(HeaderIdempotencyKey, tc.key)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedBody, rr.Body.String())
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Equal(t, tc.expectedReplayed, rr.Header().Get(HeaderReplayed) == "true")
+			if tc.expectedComplete {
+				mockService.AssertCalled(t, "Complete", mock.Anything, scopedKey, tc.handlerStatus, []byte(`{"success":true}`))
+			} else {
+				mockService.AssertNotCalled(t, "Complete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			if tc.expectedRelease {
+				mockService.AssertCalled(t, "Release", mock.Anything, scopedKey)
+			} else {
+				mockService.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"regexp"
+	"time"
+
+	"friend_connection_rest_api/controller/auth"
+	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID is the header a request ID is read from and returned in
+const HeaderRequestID = "X-Request-ID"
+
+// requestIDKey is the context key of the request ID
+const requestIDKey = "request_id"
+
+// validRequestID accept the request ID of a client or a proxy when it can not break the log line
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+// RequestLogger propagate the X-Request-ID header of the request or assign a new one, the logger of the request
+// context has the request ID so service and query logs can be correlated. One line is logged by request
+func RequestLogger(base *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(HeaderRequestID)
+		if !validRequestID.MatchString(requestID) {
+			requestID, _ = utils.GenerateToken(16)
+		}
+		c.Set(requestIDKey, requestID)
+		c.Header(HeaderRequestID, requestID)
+
+		requestLogger := base.With("request_id", requestID)
+		// Log lines of a traced request can be found from the trace
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+			requestLogger = requestLogger.With("trace_id", spanContext.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), requestLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		keyvals := []interface{}{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if caller := auth.Caller(c); caller != "" {
+			keyvals = append(keyvals, "caller", caller)
+		}
+		if len(c.Errors) > 0 {
+			keyvals = append(keyvals, "errors", c.Errors.String())
+		}
+
+		level := logger.LevelInfo
+		if status >= 500 {
+			level = logger.LevelError
+		}
+		requestLogger.Log(level, "request", keyvals...)
+	}
+}
+
+// RequestID return the ID of the request, it is empty when the middleware is not used
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
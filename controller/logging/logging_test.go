@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"friend_connection_rest_api/utils/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogger(t *testing.T) {
+	testCase := []struct {
+		scenario          string
+		header            string
+		expectedRequestID string
+	}{
+		{
+			scenario:          "Propagate Request ID",
+			header:            "abc-123",
+			expectedRequestID: "abc-123",
+		},
+		{
+			scenario: "Assign Request ID",
+		},
+		{
+			scenario: "Replace Invalid Request ID",
+			header:   "abc\"}\n{",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			var out bytes.Buffer
+			r := gin.New()
+			r.Use(RequestLogger(logger.New(&out, logger.LevelInfo, true)))
+			r.GET("/users/:email/circles", func(c *gin.Context) {
+				logger.FromContext(c.Request.Context()).Info("service log", "owner", c.Param("email"))
+				c.Status(http.StatusOK)
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/users/abc@gmail.com/circles", nil)
+			if tc.header != "" {
+				req.Header.Set(HeaderRequestID, tc.header)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			requestID := rr.Header().Get(HeaderRequestID)
+			if tc.expectedRequestID != "" {
+				assert.Equal(t, tc.expectedRequestID, requestID)
+			} else {
+				assert.Equal(t, 32, len(requestID))
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			assert.Equal(t, 2, len(lines))
+
+			serviceEntry := map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &serviceEntry))
+			assert.Equal(t, "service log", serviceEntry["msg"])
+			assert.Equal(t, requestID, serviceEntry["request_id"])
+			assert.Equal(t, logger.RedactEmails("abc@gmail.com"), serviceEntry["owner"])
+
+			requestEntry := map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal([]byte(lines[1]), &requestEntry))
+			assert.Equal(t, "request", requestEntry["msg"])
+			assert.Equal(t, "INFO", requestEntry["level"])
+			assert.Equal(t, requestID, requestEntry["request_id"])
+			assert.Equal(t, "/users/:email/circles", requestEntry["route"])
+			assert.Equal(t, float64(http.StatusOK), requestEntry["status"])
+			assert.False(t, strings.Contains(lines[1], "abc@gmail.com"))
+		})
+	}
+}
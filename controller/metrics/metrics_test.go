@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"friend_connection_rest_api/services/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/users/:email/circles", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/metrics", Handler())
+
+	testCase := []struct {
+		scenario string
+		path     string
+		route    string
+		status   string
+	}{
+		{
+			scenario: "Route Pattern",
+			path:     "/users/abc@gmail.com/circles",
+			route:    "/users/:email/circles",
+			status:   "200",
+		},
+		{
+			scenario: "Unmatched Route",
+			path:     "/not-found",
+			route:    unmatchedRoute,
+			status:   "404",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(tc.route, "GET", tc.status)
+			before := testutil.ToFloat64(counter)
+
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	body, _ := ioutil.ReadAll(rr.Body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.Contains(string(body), `friend_connection_http_requests_total{method="GET",route="/users/:email/circles",status="200"} 1`))
+	assert.True(t, strings.Contains(string(body), "friend_connection_friendships_created_total 0"))
+}
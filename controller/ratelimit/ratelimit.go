@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"friend_connection_rest_api/controller/auth"
+	httpRes "friend_connection_rest_api/controller/common_respone"
+	"friend_connection_rest_api/services/metrics"
+	"friend_connection_rest_api/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderAPIKey is the header identifying a client sharing its IP with other clients, e.g. a partner backend
+const HeaderAPIKey = "X-API-Key"
+
+// KeyFunc return the key of the client of a request, each client has its own buckets
+type KeyFunc func(c *gin.Context) string
+
+// ClientKey identify the client by its authenticated email, then by one of apiKeys, then by its IP.
+// An unknown API key is ignored so a client can not get a new bucket by changing its key
+func ClientKey(apiKeys []string) KeyFunc {
+	return func(c *gin.Context) string {
+		if caller := auth.Caller(c); caller != "" {
+			return "user:" + caller
+		}
+		if key := c.GetHeader(HeaderAPIKey); key != "" {
+			for _, apiKey := range apiKeys {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+					return "key:" + apiKey
+				}
+			}
+		}
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// RateLimit take a token from the bucket of the client for the route of the request, the routes map override
+// the default limit by route path and each of them has its own bucket. The request is rejected with 429 when the
+// bucket is empty. RateLimit-* headers are returned on each limited request.
+// The request is served when the store fails, so an unavailable store does not take the API down
+func RateLimit(store Store, key KeyFunc, defaultLimit Limit, routes map[string]Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		limit, ok := routes[route]
+		if !ok {
+			limit = defaultLimit
+		}
+
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		bucketKey := key(c)
+		if ok {
+			bucketKey = route + "|" + bucketKey
+		}
+
+		rs, err := store.Take(c.Request.Context(), bucketKey, limit)
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Warn("rate limit store failed", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(rs.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(rs.Reset)))
+
+		if !rs.Allowed {
+			metrics.RateLimited.WithLabelValues(route).Inc()
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(rs.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, httpRes.HTTPError{Message: "Too Many Requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ceilSeconds return the duration in whole seconds, rounded up so a client retrying after it gets a token
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
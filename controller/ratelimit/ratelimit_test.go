@@ -0,0 +1,197 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"friend_connection_rest_api/controller/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCase := []struct {
+		value         string
+		expectedLimit Limit
+		expectedError bool
+	}{
+		{value: "20/1m", expectedLimit: Limit{Requests: 20, Period: time.Minute}},
+		{value: "0", expectedLimit: Limit{}},
+		{value: "", expectedError: true},
+		{value: "20", expectedError: true},
+		{value: "x/1m", expectedError: true},
+		{value: "20/0s", expectedError: true},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.value, func(t *testing.T) {
+			limit, err := ParseLimit(tc.value)
+			assert.Equal(t, tc.expectedError, err != nil)
+			assert.Equal(t, tc.expectedLimit, limit)
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+
+	rs, _ := store.Take(context.Background(), "a", limit)
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, Reset: 5 * time.Second}, rs)
+
+	rs, _ = store.Take(context.Background(), "a", limit)
+	assert.Equal(t, Result{Allowed: true, Remaining: 0, Reset: 10 * time.Second}, rs)
+
+	// Bucket is empty, a token is refilled every 5 seconds
+	now = now.Add(2 * time.Second)
+	rs, _ = store.Take(context.Background(), "a", limit)
+	assert.False(t, rs.Allowed)
+	assert.Equal(t, 3*time.Second, rs.RetryAfter)
+
+	// Other clients have their own bucket
+	rs, _ = store.Take(context.Background(), "b", limit)
+	assert.True(t, rs.Allowed)
+
+	now = now.Add(3 * time.Second)
+	rs, _ = store.Take(context.Background(), "a", limit)
+	assert.True(t, rs.Allowed)
+
+	// Full buckets are removed
+	now = now.Add(time.Hour)
+	store.Take(context.Background(), "c", limit)
+	assert.Len(t, store.buckets, 1)
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("Store Unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	testCase := []struct {
+		scenario       string
+		store          Store
+		requests       []string
+		path           string
+		email          string
+		apiKey         string
+		expectedStatus int
+		expectedHeader map[string]string
+	}{
+		{
+			scenario:       "Allowed",
+			store:          NewMemoryStore(),
+			path:           "/list-users",
+			expectedStatus: http.StatusOK,
+			expectedHeader: map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "30"},
+		},
+		{
+			scenario:       "Too Many Requests",
+			store:          NewMemoryStore(),
+			requests:       []string{"/list-users", "/list-users"},
+			path:           "/list-users",
+			expectedStatus: http.StatusTooManyRequests,
+			expectedHeader: map[string]string{"RateLimit-Remaining": "0", "Retry-After": "30"},
+		},
+		{
+			scenario:       "Route Limit",
+			store:          NewMemoryStore(),
+			path:           "/add-friends",
+			expectedStatus: http.StatusTooManyRequests,
+			expectedHeader: map[string]string{"RateLimit-Limit": "1", "Retry-After": "60"},
+			requests:       []string{"/add-friends"},
+		},
+		{
+			scenario:       "Route Has Its Own Bucket",
+			store:          NewMemoryStore(),
+			requests:       []string{"/list-users", "/list-users"},
+			path:           "/add-friends",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			scenario:       "User Has Its Own Bucket",
+			store:          NewMemoryStore(),
+			requests:       []string{"/list-users", "/list-users"},
+			path:           "/list-users",
+			email:          "abc@gmail.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			scenario:       "API Key Has Its Own Bucket",
+			store:          NewMemoryStore(),
+			requests:       []string{"/list-users", "/list-users"},
+			path:           "/list-users",
+			apiKey:         "partner",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			scenario:       "Unknown API Key Is Ignored",
+			store:          NewMemoryStore(),
+			requests:       []string{"/list-users", "/list-users"},
+			path:           "/list-users",
+			apiKey:         "unknown",
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			scenario:       "Unlimited Route",
+			store:          NewMemoryStore(),
+			requests:       []string{"/healthz", "/healthz"},
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			scenario:       "Store Failure",
+			store:          failingStore{},
+			path:           "/list-users",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if email := c.GetHeader("X-Test-Email"); email != "" {
+					auth.SetCaller(c, email)
+				}
+			})
+			r.Use(RateLimit(tc.store, ClientKey([]string{"partner"}), Limit{Requests: 2, Period: time.Minute}, map[string]Limit{
+				"/add-friends": {Requests: 1, Period: time.Minute},
+				"/healthz":     {},
+			}))
+			for _, path := range []string{"/list-users", "/add-friends", "/healthz"} {
+				r.GET(path, func(c *gin.Context) {
+					c.Status(http.StatusOK)
+				})
+			}
+
+			for _, path := range tc.requests {
+				req, _ := http.NewRequest(http.MethodGet, path, nil)
+				r.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.email != "" {
+				req.Header.Set("X-Test-Email", tc.email)
+			}
+			if tc.apiKey != "" {
+				req.Header.Set(HeaderAPIKey, tc.apiKey)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			for key, value := range tc.expectedHeader {
+				assert.Equal(t, value, rr.Header().Get(key), key)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket of Requests tokens refilled over Period, a limit without requests is disabled
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled return true when requests are limited
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// rate return the number of tokens refilled by second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit parse a limit written as "requests/period", e.g. "20/1m", "0" disables the limit
+func ParseLimit(value string) (Limit, error) {
+	if value == "0" {
+		return Limit{}, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, errors.New("Rate Limit Invalid")
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return Limit{}, errors.New("Rate Limit Invalid")
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, errors.New("Rate Limit Invalid")
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// Result is the state of a bucket after a request took a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, it is zero when the request is allowed
+	RetryAfter time.Duration
+}
+
+// Store keep the token buckets, a shared store is needed when the API runs on several instances
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time the bucket is full again, the bucket can be forgotten after it
+	full time.Time
+}
+
+// MemoryStore keep the token buckets in memory, buckets which are full again are removed every sweep interval
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// sweepInterval is the minimum time between two removals of full buckets
+const sweepInterval = time.Minute
+
+// NewMemoryStore initializes an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	rs := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		rs.Allowed = true
+	} else {
+		rs.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	rs.Remaining = int(b.tokens)
+	rs.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(rs.Reset)
+	return rs, nil
+}
+
+// sweep remove the buckets which are full again
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(math.Round(value * float64(time.Second)))
+}
+
+// GetEnvLimit return the limit of an environment variable, e.g. "20/1m", or the default limit when it is not set or invalid
+func GetEnvLimit(key string, defaultLimit Limit) Limit {
+	limit, err := ParseLimit(os.Getenv(key))
+	if err != nil {
+		return defaultLimit
+	}
+	return limit
+}
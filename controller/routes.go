@@ -1,43 +1,170 @@
 package controller
 
 import (
+	"context"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	adminController "friend_connection_rest_api/controller/admin"
+	"friend_connection_rest_api/controller/auth"
+	circleController "friend_connection_rest_api/controller/circle"
 	friendshipController "friend_connection_rest_api/controller/friendship"
+	graphqlController "friend_connection_rest_api/controller/graphql"
+	healthController "friend_connection_rest_api/controller/health"
+	idempotencyController "friend_connection_rest_api/controller/idempotency"
+	"friend_connection_rest_api/controller/logging"
+	metricsController "friend_connection_rest_api/controller/metrics"
+	"friend_connection_rest_api/controller/ratelimit"
+	"friend_connection_rest_api/controller/rpc"
+	"friend_connection_rest_api/controller/timeout"
+	tracingController "friend_connection_rest_api/controller/tracing"
 	userController "friend_connection_rest_api/controller/user"
 	migration "friend_connection_rest_api/migrations"
+	"friend_connection_rest_api/services/cache"
 	friendshipService "friend_connection_rest_api/services/friendship"
+	healthService "friend_connection_rest_api/services/health"
+	idempotencyService "friend_connection_rest_api/services/idempotency"
+	"friend_connection_rest_api/services/mailer"
+	"friend_connection_rest_api/services/metrics"
 	userService "friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
+	"friend_connection_rest_api/utils/tracing"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"google.golang.org/grpc"
 
 	//_ "github.com/swaggo/gin-swagger/example/basic/docs"
 	"gorm.io/gorm"
 )
 
-//Setup Manager, Migration and Routes
-func Setup(db *gorm.DB) http.Handler {
-	friendshipService := friendshipService.NewFriendshipManager(db)
-	userService := userService.NewUserManager(db)
-	migration.InitMigration(db)
+// Setup Manager, Migration, Routes and the gRPC server sharing the same services,
+// background work is stopped when ctx is cancelled
+func Setup(ctx context.Context, db *gorm.DB) (http.Handler, *grpc.Server) {
+	circleService := friendshipService.NewCircleManager(db)
+	healthService := healthService.NewHealthManager(db)
+	idempotencyService := idempotencyService.NewIdempotencyManager(db, utils.GetEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour))
+	friendshipManager := friendshipService.NewFriendshipManager(db)
+	// Friend lists, followers and existing users are kept in memory, CACHE_SIZE=0 disables the cache
+	if cacheSize := utils.GetEnvInt("CACHE_SIZE", 10000); cacheSize > 0 {
+		friendshipManager.WithCache(cache.NewLRU(cacheSize), utils.GetEnvDuration("CACHE_TTL", 5*time.Minute))
+	}
+	// Queries listed in GRAPH_INDEX_QUERIES are answered by the in-memory graph index instead of SQL
+	var graphIndex *friendshipService.GraphIndex
+	if graphQueries := friendshipService.ParseGraphQueries(os.Getenv("GRAPH_INDEX_QUERIES")); len(graphQueries) > 0 {
+		graphIndex = friendshipService.NewGraphIndex()
+		friendshipManager.WithGraphIndex(graphIndex, graphQueries)
+	}
+	// Repairs invalidate the cached lists and update the graph index of the repaired pairs
+	consistencyService := friendshipService.NewConsistencyManager(db).WithFriendshipManager(friendshipManager)
+	// Verification email is written to MAILER_FILE, or to the log when it is not set
+	userManager := userService.NewUserManager(db).
+		WithMailer(mailer.NewFileMailer(os.Getenv("MAILER_FILE"))).
+		WithVerifyHook(friendshipManager.AcceptInvitations)
+	// Each service call is recorded as a span of the request trace
+	friendshipService := friendshipService.NewFriendshipTracing(friendshipManager)
+	userService := userService.NewUserTracing(userManager)
+	if err := migration.InitMigration(db); err != nil {
+		logger.Default().Error("migration failed", "error", err)
+		os.Exit(1)
+	}
+
+	// Graph index is loaded from the migrated table, then checked against it every GRAPH_INDEX_CHECK_INTERVAL
+	if graphIndex != nil {
+		go graphIndex.Verify(ctx, db, utils.GetEnvDuration("GRAPH_INDEX_CHECK_INTERVAL", 10*time.Minute))
+	}
+
+	// Query timings and pool stats are exposed on /metrics
+	if err := metrics.RegisterDB(db, "friend-mgmt"); err != nil {
+		logger.Default().Warn("database metrics are disabled", "error", err)
+	}
+
+	// Each query is recorded as a span of the request trace
+	if err := tracing.RegisterDB(db); err != nil {
+		logger.Default().Warn("database tracing is disabled", "error", err)
+	}
+
+	// Maximum number of operations in one batch request
+	batchMaxSize := utils.GetEnvInt("BATCH_MAX_SIZE", 100)
 	gin.SetMode(gin.TestMode)
 
-	r := gin.Default()
+	// Trace context is read from the traceparent header,
+	// one JSON line is logged by request with its X-Request-ID, panics are recovered into 500
+	r := gin.New()
+	r.Use(tracingController.Tracing(), logging.RequestLogger(logger.Default()), gin.Recovery(), metricsController.Metrics())
 
 	//url := ginSwagger.URL("http://localhost:3000/docs/swagger.json")
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Request context is cancelled after REQUEST_TIMEOUT, long running routes have their own timeout
+	requestTimeout := utils.GetEnvDuration("REQUEST_TIMEOUT", 10*time.Second)
+	r.Use(timeout.Timeout(requestTimeout, map[string]time.Duration{
+		"/batch":                        utils.GetEnvDuration("BATCH_TIMEOUT", 30*time.Second),
+		"/users/:email/contacts/import": utils.GetEnvDuration("IMPORT_TIMEOUT", 30*time.Second),
+		"/admin/consistency":            utils.GetEnvDuration("ADMIN_TIMEOUT", 5*time.Minute),
+	}))
+
+	// Probes and metrics are served without authentication
+	r.GET("/healthz", healthController.LivenessController)
+
+	r.GET("/metrics", metricsController.Handler())
+
+	r.GET("/readyz", func(c *gin.Context) {
+		healthController.ReadinessController(c, healthService)
+	})
+
+	// Caller is resolved from the bearer access token, anonymous request is still served
+	r.Use(auth.Authenticate(userService))
+
+	// Clients are limited by email, API key or IP, friend requests and updates have a stricter limit
+	rateLimitStore := ratelimit.NewMemoryStore()
+	rateLimit := ratelimit.GetEnvLimit("RATE_LIMIT", ratelimit.Limit{Requests: 120, Period: time.Minute})
+	friendLimit := ratelimit.GetEnvLimit("RATE_LIMIT_FRIEND", ratelimit.Limit{Requests: 20, Period: time.Minute})
+	updateLimit := ratelimit.GetEnvLimit("RATE_LIMIT_UPDATE", ratelimit.Limit{Requests: 30, Period: time.Minute})
+	r.Use(ratelimit.RateLimit(
+		rateLimitStore,
+		ratelimit.ClientKey(strings.FieldsFunc(os.Getenv("RATE_LIMIT_API_KEYS"), func(r rune) bool { return r == ',' })),
+		rateLimit,
+		map[string]ratelimit.Limit{
+			"/add-friends":                   friendLimit,
+			"/subscribe":                     friendLimit,
+			"/users/:email/contacts/import":  friendLimit,
+			"/batch":                         friendLimit,
+			"/get-list-users-receive-update": updateLimit,
+		}))
+
+	// Retried requests with the same Idempotency-Key header get the respone of the first one
+	idempotent := idempotencyController.Idempotency(idempotencyService)
+
 	r.GET("/list-users", func(c *gin.Context) {
 		userController.GetListUsersController(c, userService)
 	})
 
-	r.POST("/create-user", func(c *gin.Context) {
+	r.POST("/create-user", idempotent, func(c *gin.Context) {
 		userController.CreateNewUserController(c, userService)
 	})
 
-	r.POST("/add-friends", func(c *gin.Context) {
+	r.POST("/verify", func(c *gin.Context) {
+		userController.VerifyUserController(c, userService)
+	})
+
+	r.POST("/login", func(c *gin.Context) {
+		userController.LoginController(c, userService)
+	})
+
+	r.GET("/users/:email/privacy", func(c *gin.Context) {
+		userController.GetPrivacySettingController(c, userService)
+	})
+
+	r.PUT("/users/:email/privacy", func(c *gin.Context) {
+		userController.UpdatePrivacySettingController(c, userService)
+	})
+
+	r.POST("/add-friends", idempotent, func(c *gin.Context) {
 		friendshipController.MakeFriendController(c, friendshipService)
 	})
 
@@ -49,16 +176,92 @@ func Setup(db *gorm.DB) http.Handler {
 		friendshipController.GetMutualFriendsController(c, friendshipService)
 	})
 
-	r.POST("/subscribe", func(c *gin.Context) {
+	r.POST("/subscribe", idempotent, func(c *gin.Context) {
 		friendshipController.SubscribeController(c, friendshipService)
 	})
 
-	r.POST("/block", func(c *gin.Context) {
+	r.POST("/block", idempotent, func(c *gin.Context) {
 		friendshipController.BlockController(c, friendshipService)
 	})
 
-	r.POST("/get-list-users-receive-update", func(c *gin.Context) {
+	r.POST("/mute", func(c *gin.Context) {
+		friendshipController.MuteController(c, friendshipService)
+	})
+
+	r.POST("/unmute", func(c *gin.Context) {
+		friendshipController.UnmuteController(c, friendshipService)
+	})
+
+	r.POST("/users/:email/contacts/import", func(c *gin.Context) {
+		friendshipController.ImportContactsController(c, friendshipService)
+	})
+
+	r.GET("/users/:email/relationship/:other", func(c *gin.Context) {
+		friendshipController.GetRelationshipController(c, friendshipService)
+	})
+
+	r.POST("/users/:email/circles", func(c *gin.Context) {
+		circleController.CreateCircleController(c, circleService)
+	})
+
+	r.GET("/users/:email/circles", func(c *gin.Context) {
+		circleController.GetCirclesController(c, circleService)
+	})
+
+	r.PUT("/users/:email/circles/:name", func(c *gin.Context) {
+		circleController.RenameCircleController(c, circleService)
+	})
+
+	r.DELETE("/users/:email/circles/:name", func(c *gin.Context) {
+		circleController.DeleteCircleController(c, circleService)
+	})
+
+	r.POST("/users/:email/circles/:name/members", func(c *gin.Context) {
+		circleController.AddCircleMembersController(c, circleService)
+	})
+
+	r.DELETE("/users/:email/circles/:name/members/:member", func(c *gin.Context) {
+		circleController.RemoveCircleMemberController(c, circleService)
+	})
+
+	r.POST("/batch", func(c *gin.Context) {
+		friendshipController.BatchController(c, friendshipService, batchMaxSize)
+	})
+
+	r.POST("/get-list-users-receive-update", idempotent, func(c *gin.Context) {
 		friendshipController.GetUsersReceiveUpdateController(c, friendshipService)
 	})
-	return r
+
+	// Nested social graph queries, deep or large queries are rejected before they are executed
+	schema, err := graphqlController.NewSchema(friendshipService,
+		utils.GetEnvInt("GRAPHQL_MAX_DEPTH", 6), utils.GetEnvInt("GRAPHQL_MAX_COMPLEXITY", 1000))
+	if err != nil {
+		logger.Default().Warn("graphql endpoint is disabled", "error", err)
+	} else {
+		r.POST("/graphql", func(c *gin.Context) {
+			graphqlController.GraphQLController(c, schema)
+		})
+	}
+
+	// Admin endpoints are enabled by setting ADMIN_TOKEN
+	admin := r.Group("/admin", adminController.RequireAdminToken(os.Getenv("ADMIN_TOKEN")))
+
+	admin.POST("/consistency", func(c *gin.Context) {
+		adminController.CheckConsistencyController(c, consistencyService)
+	})
+
+	// Users, friendships, subscriptions, blocks and updates are also served by gRPC,
+	// calls have the timeout, the rate limit buckets and the idempotency keys of the REST API
+	return r, rpc.NewServer(userService, friendshipService, rpc.Config{
+		Timeout:        requestTimeout,
+		RateLimitStore: rateLimitStore,
+		RateLimit:      rateLimit,
+		RateLimits: map[string]ratelimit.Limit{
+			"/friendconnection.v1.Friendships/AddFriend":   friendLimit,
+			"/friendconnection.v1.Subscriptions/Subscribe": friendLimit,
+			"/friendconnection.v1.Updates/ListRecipients":  updateLimit,
+			"/friendconnection.v1.Updates/Deliver":         updateLimit,
+		},
+		Idempotency: idempotencyService,
+	})
 }
@@ -0,0 +1,229 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"friend_connection_rest_api/proto/friendpb"
+	"friend_connection_rest_api/services/friendship"
+	"friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type friendshipServer struct {
+	friendpb.UnimplementedFriendshipsServer
+	service friendship.FrienshipServices
+}
+
+func (s *friendshipServer) AddFriend(ctx context.Context, req *friendpb.FriendPairRequest) (*friendpb.AddFriendResponse, error) {
+	if err := validatePair(req.Email, req.Other); err != nil {
+		return nil, err
+	}
+
+	if err := requireCaller(ctx, req.Email); err != nil {
+		return nil, err
+	}
+
+	return toAddFriendResponse(s.service.MakeFriend(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Email, TargetEmail: req.Other}))
+}
+
+func (s *friendshipServer) ListFriends(ctx context.Context, req *friendpb.ListFriendsRequest) (*friendpb.FriendsResponse, error) {
+	if utils.ValidateEmail(req.Email) == false {
+		return nil, status.Error(codes.InvalidArgument, "Email Invalid Format")
+	}
+
+	rs, err := s.service.GetFriendsList(ctx, user.Users{Email: req.Email}, Caller(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.FriendsResponse{Friends: rs, Count: uint32(len(rs))}, nil
+}
+
+func (s *friendshipServer) ListMutualFriends(ctx context.Context, req *friendpb.FriendPairRequest) (*friendpb.FriendsResponse, error) {
+	if err := validatePair(req.Email, req.Other); err != nil {
+		return nil, err
+	}
+
+	rs, err := s.service.GetMutualFriendsList(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Email, TargetEmail: req.Other}, Caller(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.FriendsResponse{Friends: rs, Count: uint32(len(rs))}, nil
+}
+
+func (s *friendshipServer) GetRelationship(ctx context.Context, req *friendpb.FriendPairRequest) (*friendpb.Relationship, error) {
+	if err := validatePair(req.Email, req.Other); err != nil {
+		return nil, err
+	}
+
+	rs, err := s.service.GetRelationship(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Email, TargetEmail: req.Other}, Caller(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toRelationship(rs), nil
+}
+
+type subscriptionServer struct {
+	friendpb.UnimplementedSubscriptionsServer
+	service friendship.FrienshipServices
+}
+
+func (s *subscriptionServer) Subscribe(ctx context.Context, req *friendpb.UpdateRequest) (*friendpb.AddFriendResponse, error) {
+	if err := validatePair(req.Requestor, req.Target); err != nil {
+		return nil, err
+	}
+
+	if err := requireCaller(ctx, req.Requestor); err != nil {
+		return nil, err
+	}
+
+	return toAddFriendResponse(s.service.Subscribe(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Requestor, TargetEmail: req.Target}))
+}
+
+func (s *subscriptionServer) Mute(ctx context.Context, req *friendpb.UpdateRequest) (*friendpb.Success, error) {
+	if err := validatePair(req.Requestor, req.Target); err != nil {
+		return nil, err
+	}
+
+	if err := requireCaller(ctx, req.Requestor); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.Mute(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Requestor, TargetEmail: req.Target}); err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.Success{Success: true}, nil
+}
+
+func (s *subscriptionServer) Unmute(ctx context.Context, req *friendpb.UpdateRequest) (*friendpb.Success, error) {
+	if err := validatePair(req.Requestor, req.Target); err != nil {
+		return nil, err
+	}
+
+	if err := requireCaller(ctx, req.Requestor); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.Unmute(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Requestor, TargetEmail: req.Target}); err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.Success{Success: true}, nil
+}
+
+type blockServer struct {
+	friendpb.UnimplementedBlocksServer
+	service friendship.FrienshipServices
+}
+
+func (s *blockServer) Block(ctx context.Context, req *friendpb.UpdateRequest) (*friendpb.Success, error) {
+	if err := validatePair(req.Requestor, req.Target); err != nil {
+		return nil, err
+	}
+
+	if err := requireCaller(ctx, req.Requestor); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.Block(ctx, friendship.FrienshipServiceInput{RequestEmail: req.Requestor, TargetEmail: req.Target}); err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.Success{Success: true}, nil
+}
+
+type updateServer struct {
+	friendpb.UnimplementedUpdatesServer
+	service friendship.FrienshipServices
+}
+
+func (s *updateServer) ListRecipients(ctx context.Context, req *friendpb.ReceiveUpdateRequest) (*friendpb.RecipientsResponse, error) {
+	rs, err := s.recipients(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &friendpb.RecipientsResponse{Recipients: rs}, nil
+}
+
+// Deliver send the recipients of the update one by one, it stops when the client cancels the stream
+func (s *updateServer) Deliver(req *friendpb.ReceiveUpdateRequest, stream friendpb.Updates_DeliverServer) error {
+	rs, err := s.recipients(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range rs {
+		if err := stream.Context().Err(); err != nil {
+			return toStatus(err)
+		}
+		if err := stream.Send(&friendpb.Delivery{Recipient: recipient}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipients return the users receiving the update of the sender, the users mentioned in the text included
+func (s *updateServer) recipients(ctx context.Context, req *friendpb.ReceiveUpdateRequest) ([]string, error) {
+	if utils.ValidateEmail(req.Sender) == false {
+		return nil, status.Error(codes.InvalidArgument, "Email Invalid Format")
+	}
+
+	if err := requireCaller(ctx, req.Sender); err != nil {
+		return nil, err
+	}
+
+	rs, err := s.service.GetUsersReceiveUpdate(ctx, req.Sender, utils.ExtractMentionEmail(req.Text), req.Circle)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return removeDuplicates(rs), nil
+}
+
+// toAddFriendResponse map the result of a friend request or a subscription, an invitation is a success
+func toAddFriendResponse(err error) (*friendpb.AddFriendResponse, error) {
+	if err == friendship.ErrInvitationPending {
+		return &friendpb.AddFriendResponse{Success: true, Invited: true, Message: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.AddFriendResponse{Success: true}, nil
+}
+
+func toRelationship(rs friendship.Relationship) *friendpb.Relationship {
+	return &friendpb.Relationship{
+		User:                rs.User,
+		Other:               rs.Other,
+		AreFriends:          rs.AreFriends,
+		UserFollowsOther:    rs.UserFollowsOther,
+		OtherFollowsUser:    rs.OtherFollowsUser,
+		UserBlocksOther:     rs.UserBlocksOther,
+		OtherBlocksUser:     rs.OtherBlocksUser,
+		PendingRequest:      rs.PendingRequest,
+		PendingRequestFrom:  rs.PendingRequestFrom,
+		ConnectedSince:      formatTime(rs.ConnectedSince),
+		UpdatedAt:           formatTime(rs.UpdatedAt),
+		PendingRequestSince: formatTime(rs.PendingRequestSince),
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func removeDuplicates(elements []string) []string {
+	encountered := map[string]bool{}
+	result := []string{}
+	for _, element := range elements {
+		if !encountered[element] {
+			encountered[element] = true
+			result = append(result, element)
+		}
+	}
+	return result
+}
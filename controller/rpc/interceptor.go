@@ -0,0 +1,267 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"friend_connection_rest_api/controller/ratelimit"
+	"friend_connection_rest_api/proto/friendpb"
+	idempotencyService "friend_connection_rest_api/services/idempotency"
+	"friend_connection_rest_api/services/metrics"
+	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MetadataIdempotencyKey is the metadata a client send the same value in when it retries a call
+	MetadataIdempotencyKey = "idempotency-key"
+	// MetadataReplayed is set in the header of a reply replayed from a previous call
+	MetadataReplayed = "idempotent-replayed"
+)
+
+// maxKeyLength is the maximum length of an idempotency key
+const maxKeyLength = 255
+
+// storeTimeout is the timeout of storing a reply or releasing a key, the call context is not used
+// because it is cancelled when the call timed out after the writes of the service were committed
+const storeTimeout = 5 * time.Second
+
+// storedStatus is the status of a stored reply, a key without status is still processed
+const storedStatus = 200
+
+// idempotentReplies return an empty reply of each method accepting an idempotency key,
+// they are the methods whose REST route accepts the Idempotency-Key header
+var idempotentReplies = map[string]func() proto.Message{
+	"/friendconnection.v1.Users/CreateUser":        func() proto.Message { return &friendpb.Success{} },
+	"/friendconnection.v1.Friendships/AddFriend":   func() proto.Message { return &friendpb.AddFriendResponse{} },
+	"/friendconnection.v1.Subscriptions/Subscribe": func() proto.Message { return &friendpb.AddFriendResponse{} },
+	"/friendconnection.v1.Blocks/Block":            func() proto.Message { return &friendpb.Success{} },
+	"/friendconnection.v1.Updates/ListRecipients":  func() proto.Message { return &friendpb.RecipientsResponse{} },
+}
+
+// Config is the limits of the calls, the zero value disables all of them
+type Config struct {
+	// Timeout is the deadline of a call, Timeouts override it by full method, zero means no deadline
+	Timeout  time.Duration
+	Timeouts map[string]time.Duration
+	// RateLimitStore keep the buckets of the callers, calls are not limited when it is nil.
+	// RateLimits override RateLimit by full method and each of them has its own bucket
+	RateLimitStore ratelimit.Store
+	RateLimit      ratelimit.Limit
+	RateLimits     map[string]ratelimit.Limit
+	// Idempotency store the replies of calls sent with the idempotency-key metadata, it is disabled when nil
+	Idempotency idempotencyService.IdempotencyServices
+}
+
+// observeCall record the count and the latency of a call by method and status code
+func observeCall(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	metrics.RPCRequests.WithLabelValues(method, code).Inc()
+	metrics.RPCRequestDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
+
+func unaryMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeCall(info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamMetrics(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	observeCall(info.FullMethod, start, err)
+	return err
+}
+
+// clientKey identify the client by its authenticated email, then by the IP of the peer
+func clientKey(ctx context.Context) string {
+	if caller := Caller(ctx); caller != "" {
+		return "user:" + caller
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "ip:"
+}
+
+// takeToken take a token from the bucket of the caller for method, the call is rejected with ResourceExhausted
+// when the bucket is empty. The call is served when the store fails, so an unavailable store does not take the API down
+func takeToken(ctx context.Context, config Config, method string) error {
+	if config.RateLimitStore == nil {
+		return nil
+	}
+
+	limit, ok := config.RateLimits[method]
+	if !ok {
+		limit = config.RateLimit
+	}
+	if !limit.Enabled() {
+		return nil
+	}
+
+	bucketKey := clientKey(ctx)
+	if ok {
+		bucketKey = method + "|" + bucketKey
+	}
+
+	rs, err := config.RateLimitStore.Take(ctx, bucketKey, limit)
+	if err != nil {
+		logger.FromContext(ctx).Warn("rate limit store failed", "error", err)
+		return nil
+	}
+
+	if !rs.Allowed {
+		metrics.RateLimited.WithLabelValues(method).Inc()
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(rs.RetryAfter.Seconds())))))
+		return status.Error(codes.ResourceExhausted, "Too Many Requests")
+	}
+	return nil
+}
+
+func unaryRateLimit(config Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := takeToken(ctx, config, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamRateLimit(config Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := takeToken(stream.Context(), config, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// withTimeout set the deadline of the call context, the returned cancel has to be called when the call ends
+func withTimeout(ctx context.Context, config Config, method string) (context.Context, context.CancelFunc) {
+	timeout, ok := config.Timeouts[method]
+	if !ok {
+		timeout = config.Timeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func unaryTimeout(config Config) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := withTimeout(ctx, config, info.FullMethod)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+func streamTimeout(config Config) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withTimeout(stream.Context(), config, info.FullMethod)
+		defer cancel()
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// unaryIdempotency replay the reply of the first call sent with the same idempotency-key metadata, keys are scoped by
+// method and caller. The key is rejected when it is sent with another request or while its first call is processed.
+// Only replies are stored, a failed call releases its key so it can be retried
+func unaryIdempotency(service idempotencyService.IdempotencyServices) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newReply, ok := idempotentReplies[info.FullMethod]
+		if service == nil || !ok {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(MetadataIdempotencyKey)
+		if len(values) == 0 || values[0] == "" {
+			return handler(ctx, req)
+		}
+
+		key := values[0]
+		if len(key) > maxKeyLength {
+			return nil, status.Error(codes.InvalidArgument, "Idempotency Key Invalid")
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		scopedKey := info.FullMethod + "|" + Caller(ctx) + "|" + key
+		fingerprint := utils.HashToken(info.FullMethod + "\n" + string(body))
+
+		record, err := service.Begin(ctx, scopedKey, fingerprint)
+		switch {
+		case errors.Is(err, idempotencyService.ErrKeyReused):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, idempotencyService.ErrKeyInProgress):
+			return nil, status.Error(codes.Aborted, err.Error())
+		case err != nil:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if record != nil {
+			reply := newReply()
+			if err := proto.Unmarshal(record.Body, reply); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			grpc.SetHeader(ctx, metadata.Pairs(MetadataReplayed, "true"))
+			return reply, nil
+		}
+
+		completed := false
+		// Key is released when the handler panics
+		defer func() {
+			if !completed {
+				releaseKey(ctx, service, scopedKey)
+			}
+		}()
+
+		resp, err := handler(ctx, req)
+		completed = true
+		if err != nil {
+			releaseKey(ctx, service, scopedKey)
+			return resp, err
+		}
+
+		stored, marshalErr := proto.Marshal(resp.(proto.Message))
+		storeCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		if marshalErr == nil {
+			marshalErr = service.Complete(storeCtx, scopedKey, storedStatus, stored)
+		}
+		if marshalErr != nil {
+			logger.FromContext(ctx).Warn("store idempotent reply failed", "error", marshalErr)
+			releaseKey(ctx, service, scopedKey)
+		}
+		return resp, nil
+	}
+}
+
+func releaseKey(ctx context.Context, service idempotencyService.IdempotencyServices, key string) {
+	releaseCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	if err := service.Release(releaseCtx, key); err != nil {
+		logger.FromContext(ctx).Warn("release idempotency key failed", "error", err)
+	}
+}
@@ -0,0 +1,374 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"friend_connection_rest_api/controller/ratelimit"
+	"friend_connection_rest_api/proto/friendpb"
+	"friend_connection_rest_api/services/friendship"
+	"friend_connection_rest_api/services/idempotency"
+	"friend_connection_rest_api/services/metrics"
+	"friend_connection_rest_api/services/user"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestConn serve the services on an in-memory listener and return a client connection to it
+func newTestConn(t *testing.T, users user.UserService, friendships friendship.FrienshipServices, config Config) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(users, friendships, config)
+	go server.Serve(listener)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithInsecure())
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return conn
+}
+
+// withToken set the bearer access token of the call
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// newAuthMock return an user service authenticating gema-token as gema@gmail.com
+func newAuthMock() *user.UserMockService {
+	userService := new(user.UserMockService)
+	userService.On("Authenticate", mock.Anything, "gema-token").Return("gema@gmail.com", nil)
+	return userService
+}
+
+func TestUsers(t *testing.T) {
+	userService := newAuthMock()
+	userService.On("CreateNewUser", mock.Anything, user.Users{Email: "gema@gmail.com"}).Return(nil)
+	userService.On("CreateNewUser", mock.Anything, user.Users{Email: "arel@gmail.com"}).Return(errors.New("Email Already Exist"))
+	userService.On("GetListUser", mock.Anything, "gema@gmail.com").Return([]string{"arel@gmail.com"}, nil)
+	userService.On("GetListUser", mock.Anything, "").Return([]string{}, nil)
+	userService.On("VerifyUser", mock.Anything, "gema@gmail.com", "token").Return("access-token", nil)
+
+	client := friendpb.NewUsersClient(newTestConn(t, userService, new(friendship.FrienshipMockService), Config{}))
+	ctx := context.Background()
+
+	success, err := client.CreateUser(ctx, &friendpb.CreateUserRequest{Email: "gema@gmail.com"})
+	assert.NoError(t, err)
+	assert.True(t, success.Success)
+
+	// Service error is the message of the status
+	_, err = client.CreateUser(ctx, &friendpb.CreateUserRequest{Email: "arel@gmail.com"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "Email Already Exist", status.Convert(err).Message())
+
+	_, err = client.CreateUser(ctx, &friendpb.CreateUserRequest{Email: "abc"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "Invalid Email", status.Convert(err).Message())
+
+	listUsers, err := client.ListUsers(withToken("gema-token"), &friendpb.ListUsersRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"arel@gmail.com"}, listUsers.Users)
+	assert.Equal(t, uint32(1), listUsers.Count)
+
+	// Anonymous call has no viewer
+	listUsers, err = client.ListUsers(ctx, &friendpb.ListUsersRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), listUsers.Count)
+
+	verify, err := client.VerifyUser(ctx, &friendpb.VerifyUserRequest{Email: "gema@gmail.com", Token: "token"})
+	assert.NoError(t, err)
+	assert.Equal(t, "access-token", verify.AccessToken)
+}
+
+func TestAuthentication(t *testing.T) {
+	userService := new(user.UserMockService)
+	userService.On("Authenticate", mock.Anything, "gema-token").Return("gema@gmail.com", nil)
+	userService.On("Authenticate", mock.Anything, "expired").Return("", errors.New("Invalid Access Token"))
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("GetFriendsList", mock.Anything, user.Users{Email: "arel@gmail.com"}, "gema@gmail.com").Return([]string{"gema@gmail.com"}, nil)
+	friendshipService.On("GetFriendsList", mock.Anything, user.Users{Email: "arel@gmail.com"}, "").Return([]string{}, friendship.ErrPrivacyRestricted)
+
+	client := friendpb.NewFriendshipsClient(newTestConn(t, userService, friendshipService, Config{}))
+
+	// Caller is the viewer of the list
+	friends, err := client.ListFriends(withToken("gema-token"), &friendpb.ListFriendsRequest{Email: "arel@gmail.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gema@gmail.com"}, friends.Friends)
+
+	// Anonymous call is served, the list is then restricted by privacy settings
+	_, err = client.ListFriends(context.Background(), &friendpb.ListFriendsRequest{Email: "arel@gmail.com"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.ListFriends(withToken("expired"), &friendpb.ListFriendsRequest{Email: "arel@gmail.com"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic abc")
+	_, err = client.ListFriends(ctx, &friendpb.ListFriendsRequest{Email: "arel@gmail.com"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Caller can not act on behalf of another user
+	_, err = client.AddFriend(withToken("gema-token"), &friendpb.FriendPairRequest{Email: "arel@gmail.com", Other: "gema@gmail.com"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	friendshipService.AssertNotCalled(t, "MakeFriend", mock.Anything, mock.Anything)
+}
+
+func TestFriendships(t *testing.T) {
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("MakeFriend", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "arel@gmail.com"}).Return(nil)
+	friendshipService.On("MakeFriend", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "new@gmail.com"}).Return(friendship.ErrInvitationPending)
+	friendshipService.On("GetMutualFriendsList", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "arel@gmail.com"}, "gema@gmail.com").Return([]string{"andy@gmail.com"}, nil)
+	friendshipService.On("GetRelationship", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "arel@gmail.com"}, "gema@gmail.com").Return(friendship.Relationship{User: "gema@gmail.com", Other: "arel@gmail.com", AreFriends: true}, nil)
+	friendshipService.On("GetRelationship", mock.Anything, friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "arel@gmail.com"}, "").Return(friendship.Relationship{}, friendship.ErrPrivacyRestricted)
+	client := friendpb.NewFriendshipsClient(newTestConn(t, newAuthMock(), friendshipService, Config{}))
+	ctx := withToken("gema-token")
+
+	added, err := client.AddFriend(ctx, &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "arel@gmail.com"})
+	assert.NoError(t, err)
+	assert.True(t, added.Success)
+	assert.False(t, added.Invited)
+
+	// Invitation is a success
+	invited, err := client.AddFriend(ctx, &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "new@gmail.com"})
+	assert.NoError(t, err)
+	assert.True(t, invited.Invited)
+	assert.Equal(t, friendship.ErrInvitationPending.Error(), invited.Message)
+
+	_, err = client.AddFriend(ctx, &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "gema@gmail.com"})
+	assert.Equal(t, "Request Invalid", status.Convert(err).Message())
+
+	_, err = client.AddFriend(ctx, &friendpb.FriendPairRequest{Email: "abc", Other: "xyz"})
+	assert.Equal(t, "Email Invalid Format", status.Convert(err).Message())
+
+	mutual, err := client.ListMutualFriends(ctx, &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "arel@gmail.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"andy@gmail.com"}, mutual.Friends)
+	assert.Equal(t, uint32(1), mutual.Count)
+
+	relationship, err := client.GetRelationship(ctx, &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "arel@gmail.com"})
+	assert.NoError(t, err)
+	assert.True(t, relationship.AreFriends)
+	assert.Equal(t, "", relationship.ConnectedSince)
+
+	// Relationship is only read by one of the pair
+	_, err = client.GetRelationship(context.Background(), &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "arel@gmail.com"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestSubscriptionsAndBlocks(t *testing.T) {
+	input := friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "arel@gmail.com"}
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("Subscribe", mock.Anything, input).Return(nil)
+	friendshipService.On("Mute", mock.Anything, input).Return(nil)
+	friendshipService.On("Unmute", mock.Anything, input).Return(errors.New("Not Muted"))
+	friendshipService.On("Block", mock.Anything, input).Return(nil)
+
+	conn := newTestConn(t, newAuthMock(), friendshipService, Config{})
+	subscriptions := friendpb.NewSubscriptionsClient(conn)
+	blocks := friendpb.NewBlocksClient(conn)
+	ctx := withToken("gema-token")
+	req := &friendpb.UpdateRequest{Requestor: "gema@gmail.com", Target: "arel@gmail.com"}
+
+	subscribed, err := subscriptions.Subscribe(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, subscribed.Success)
+
+	_, err = subscriptions.Mute(ctx, req)
+	assert.NoError(t, err)
+
+	_, err = subscriptions.Unmute(ctx, req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "Not Muted", status.Convert(err).Message())
+
+	blocked, err := blocks.Block(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, blocked.Success)
+
+	_, err = blocks.Block(ctx, &friendpb.UpdateRequest{Requestor: "gema@gmail.com", Target: "gema@gmail.com"})
+	assert.Equal(t, "Request Invalid", status.Convert(err).Message())
+
+	// Each write requires the requestor as caller
+	anonymous := context.Background()
+	_, err = subscriptions.Subscribe(anonymous, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = subscriptions.Mute(anonymous, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = subscriptions.Unmute(anonymous, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = blocks.Block(anonymous, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = blocks.Block(ctx, &friendpb.UpdateRequest{Requestor: "arel@gmail.com", Target: "gema@gmail.com"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	friendshipService.AssertNumberOfCalls(t, "Block", 1)
+}
+
+func TestUpdates(t *testing.T) {
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("GetUsersReceiveUpdate", mock.Anything, "gema@gmail.com", []string{"andy@gmail.com"}, "").
+		Return([]string{"arel@gmail.com", "andy@gmail.com", "arel@gmail.com"}, nil)
+	friendshipService.On("GetUsersReceiveUpdate", mock.Anything, "arel@gmail.com", []string{}, "family").
+		Return([]string{}, errors.New("Circle Not Exist"))
+
+	userService := newAuthMock()
+	userService.On("Authenticate", mock.Anything, "arel-token").Return("arel@gmail.com", nil)
+	client := friendpb.NewUpdatesClient(newTestConn(t, userService, friendshipService, Config{}))
+	ctx := withToken("gema-token")
+	req := &friendpb.ReceiveUpdateRequest{Sender: "gema@gmail.com", Text: "Hello @andy@gmail.com"}
+
+	listRecipients, err := client.ListRecipients(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"arel@gmail.com", "andy@gmail.com"}, listRecipients.Recipients)
+
+	// Recipients are streamed one by one without duplicate
+	stream, err := client.Deliver(ctx, req)
+	assert.NoError(t, err)
+	delivered := []string{}
+	for {
+		delivery, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		if err != nil {
+			break
+		}
+		delivered = append(delivered, delivery.Recipient)
+	}
+	assert.Equal(t, []string{"arel@gmail.com", "andy@gmail.com"}, delivered)
+
+	// Error is returned by the first receive
+	stream, err = client.Deliver(withToken("arel-token"), &friendpb.ReceiveUpdateRequest{Sender: "arel@gmail.com", Text: "Hello", Circle: "family"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "Circle Not Exist", status.Convert(err).Message())
+
+	stream, err = client.Deliver(ctx, &friendpb.ReceiveUpdateRequest{Sender: "abc", Text: "Hello"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, "Email Invalid Format", status.Convert(err).Message())
+}
+
+func TestRateLimit(t *testing.T) {
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("GetFriendsList", mock.Anything, user.Users{Email: "arel@gmail.com"}, mock.Anything).Return([]string{}, nil)
+
+	client := friendpb.NewFriendshipsClient(newTestConn(t, newAuthMock(), friendshipService, Config{
+		RateLimitStore: ratelimit.NewMemoryStore(),
+		RateLimit:      ratelimit.Limit{Requests: 1, Period: time.Minute},
+	}))
+	req := &friendpb.ListFriendsRequest{Email: "arel@gmail.com"}
+
+	_, err := client.ListFriends(context.Background(), req)
+	assert.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.ListFriends(context.Background(), req, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"60"}, header.Get("retry-after"))
+
+	// Authenticated caller has its own bucket
+	_, err = client.ListFriends(withToken("gema-token"), req)
+	assert.NoError(t, err)
+}
+
+func TestTimeout(t *testing.T) {
+	deadlines := map[string]time.Duration{}
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("GetFriendsList", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		deadline, ok := args.Get(0).(context.Context).Deadline()
+		if ok {
+			deadlines[args.Get(1).(user.Users).Email] = time.Until(deadline)
+		}
+	}).Return([]string{}, nil)
+
+	client := friendpb.NewFriendshipsClient(newTestConn(t, newAuthMock(), friendshipService, Config{
+		Timeout:  time.Minute,
+		Timeouts: map[string]time.Duration{"/friendconnection.v1.Friendships/ListFriends": 0},
+	}))
+	_, err := client.ListFriends(context.Background(), &friendpb.ListFriendsRequest{Email: "arel@gmail.com"})
+	assert.NoError(t, err)
+	assert.Empty(t, deadlines)
+
+	client = friendpb.NewFriendshipsClient(newTestConn(t, newAuthMock(), friendshipService, Config{Timeout: time.Minute}))
+	_, err = client.ListFriends(context.Background(), &friendpb.ListFriendsRequest{Email: "arel@gmail.com"})
+	assert.NoError(t, err)
+	assert.True(t, deadlines["arel@gmail.com"] > 0 && deadlines["arel@gmail.com"] <= time.Minute)
+}
+
+func TestIdempotency(t *testing.T) {
+	input := friendship.FrienshipServiceInput{RequestEmail: "gema@gmail.com", TargetEmail: "arel@gmail.com"}
+	friendshipService := new(friendship.FrienshipMockService)
+	friendshipService.On("MakeFriend", mock.Anything, input).Return(nil).Once()
+	friendshipService.On("MakeFriend", mock.Anything, input).Return(errors.New("Friendship was exist"))
+
+	key := "/friendconnection.v1.Friendships/AddFriend|gema@gmail.com|retry-1"
+	var stored []byte
+	idempotencyService := new(idempotency.IdempotencyMockService)
+	idempotencyService.On("Begin", mock.Anything, key, mock.Anything).Return(nil, nil).Once()
+	idempotencyService.On("Complete", mock.Anything, key, storedStatus, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(3).([]byte)
+	}).Return(nil)
+
+	client := friendpb.NewFriendshipsClient(newTestConn(t, newAuthMock(), friendshipService, Config{Idempotency: idempotencyService}))
+	ctx := metadata.AppendToOutgoingContext(withToken("gema-token"), MetadataIdempotencyKey, "retry-1")
+	req := &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "arel@gmail.com"}
+
+	added, err := client.AddFriend(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, added.Success)
+
+	// Retry get the reply of the first call without calling the service again
+	idempotencyService.On("Begin", mock.Anything, key, mock.Anything).Return(&idempotency.IdempotencyKey{Status: storedStatus, Body: stored}, nil).Once()
+	var header metadata.MD
+	replayed, err := client.AddFriend(ctx, req, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.True(t, replayed.Success)
+	assert.Equal(t, []string{"true"}, header.Get(MetadataReplayed))
+	friendshipService.AssertNumberOfCalls(t, "MakeFriend", 1)
+
+	// Key sent with another request is rejected
+	idempotencyService.On("Begin", mock.Anything, key, mock.Anything).Return(nil, idempotency.ErrKeyReused).Once()
+	_, err = client.AddFriend(ctx, &friendpb.FriendPairRequest{Email: "gema@gmail.com", Other: "andy@gmail.com"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// Failed call releases its key
+	idempotencyService.On("Begin", mock.Anything, "/friendconnection.v1.Friendships/AddFriend|gema@gmail.com|retry-2", mock.Anything).Return(nil, nil)
+	idempotencyService.On("Release", mock.Anything, "/friendconnection.v1.Friendships/AddFriend|gema@gmail.com|retry-2").Return(nil)
+	_, err = client.AddFriend(metadata.AppendToOutgoingContext(withToken("gema-token"), MetadataIdempotencyKey, "retry-2"), req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	idempotencyService.AssertCalled(t, "Release", mock.Anything, "/friendconnection.v1.Friendships/AddFriend|gema@gmail.com|retry-2")
+}
+
+func TestMetrics(t *testing.T) {
+	userService := newAuthMock()
+	userService.On("Login", mock.Anything, "gema@gmail.com").Return(nil)
+	client := friendpb.NewUsersClient(newTestConn(t, userService, new(friendship.FrienshipMockService), Config{}))
+
+	ok := metrics.RPCRequests.WithLabelValues("/friendconnection.v1.Users/Login", "OK")
+	invalid := metrics.RPCRequests.WithLabelValues("/friendconnection.v1.Users/Login", "InvalidArgument")
+	okBefore, invalidBefore := testutil.ToFloat64(ok), testutil.ToFloat64(invalid)
+
+	_, err := client.Login(context.Background(), &friendpb.LoginRequest{Email: "gema@gmail.com"})
+	assert.NoError(t, err)
+	_, err = client.Login(context.Background(), &friendpb.LoginRequest{Email: "abc"})
+	assert.Error(t, err)
+
+	assert.Equal(t, okBefore+1, testutil.ToFloat64(ok))
+	assert.Equal(t, invalidBefore+1, testutil.ToFloat64(invalid))
+}
@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"friend_connection_rest_api/proto/friendpb"
+	"friend_connection_rest_api/services/friendship"
+	userService "friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// callerKey is the context key of the authenticated email
+type callerKey struct{}
+
+// NewServer register the gRPC services on the same services as the REST API. Calls are authenticated
+// with the bearer access token of the authorization metadata, one line is logged and measured by call
+// and panics are recovered into Internal. Calls are then rate limited, given a deadline and replayed
+// by idempotency key as configured by config
+func NewServer(users userService.UserService, friendships friendship.FrienshipServices, config Config, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryRecovery, unaryLogger, unaryMetrics, unaryAuthenticate(users),
+			unaryRateLimit(config), unaryTimeout(config), unaryIdempotency(config.Idempotency)),
+		grpc.ChainStreamInterceptor(streamRecovery, streamLogger, streamMetrics, streamAuthenticate(users),
+			streamRateLimit(config), streamTimeout(config)),
+	)
+	server := grpc.NewServer(opts...)
+
+	friendpb.RegisterUsersServer(server, &userServer{service: users})
+	friendpb.RegisterFriendshipsServer(server, &friendshipServer{service: friendships})
+	friendpb.RegisterSubscriptionsServer(server, &subscriptionServer{service: friendships})
+	friendpb.RegisterBlocksServer(server, &blockServer{service: friendships})
+	friendpb.RegisterUpdatesServer(server, &updateServer{service: friendships})
+	return server
+}
+
+// Caller return the authenticated email, it is empty for anonymous call
+func Caller(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// authenticate resolve the bearer access token of the authorization metadata to the email of the caller,
+// calls without authorization are served as anonymous
+func authenticate(ctx context.Context, service userService.UserService) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ctx, nil
+	}
+
+	if !strings.HasPrefix(values[0], "Bearer ") {
+		return ctx, status.Error(codes.Unauthenticated, "Invalid Authorization Header")
+	}
+
+	email, err := service.Authenticate(ctx, strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer ")))
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, callerKey{}, email), nil
+}
+
+func unaryAuthenticate(service userService.UserService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, service)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuthenticate(service userService.UserService) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), service)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// contextStream replace the context of a stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequestLogger set the logger of the call context, so service and query logs have the request ID
+func withRequestLogger(ctx context.Context) (context.Context, *logger.Logger) {
+	requestID, _ := utils.GenerateToken(16)
+	requestLogger := logger.Default().With("request_id", requestID)
+	return logger.WithContext(ctx, requestLogger), requestLogger
+}
+
+// logCall log one line by call, with the status code instead of the HTTP status
+func logCall(requestLogger *logger.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := logger.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = logger.LevelError
+	}
+	requestLogger.Log(level, "rpc", "method", method, "code", code.String(), "latency_ms", time.Since(start).Milliseconds())
+}
+
+func unaryLogger(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, requestLogger := withRequestLogger(ctx)
+	resp, err := handler(ctx, req)
+	logCall(requestLogger, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamLogger(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, requestLogger := withRequestLogger(stream.Context())
+	err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	logCall(requestLogger, info.FullMethod, start, err)
+	return err
+}
+
+func unaryRecovery(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.FromContext(ctx).Error("rpc panic recovered", "method", info.FullMethod, "panic", r)
+			err = status.Error(codes.Internal, "Internal Error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func streamRecovery(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.FromContext(stream.Context()).Error("rpc panic recovered", "method", info.FullMethod, "panic", r)
+			err = status.Error(codes.Internal, "Internal Error")
+		}
+	}()
+	return handler(srv, stream)
+}
+
+// toStatus map an error of the services to the status of the REST respone,
+// 400 is InvalidArgument and 403 is PermissionDenied
+func toStatus(err error) error {
+	switch {
+	case err == friendship.ErrPrivacyRestricted:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case err == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case err == context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// requireCaller reject the call when it is not authenticated as email,
+// anonymous call is Unauthenticated and another caller is PermissionDenied
+func requireCaller(ctx context.Context, email string) error {
+	caller := Caller(ctx)
+	if caller == "" {
+		return status.Error(codes.Unauthenticated, "Authentication Required")
+	}
+
+	if caller != email {
+		return status.Error(codes.PermissionDenied, "Permission Denied")
+	}
+	return nil
+}
+
+// validatePair check the two emails of a request are valid and distinct
+func validatePair(first string, second string) error {
+	if first == second {
+		return status.Error(codes.InvalidArgument, "Request Invalid")
+	}
+	if utils.ValidateEmail(first) == false || utils.ValidateEmail(second) == false {
+		return status.Error(codes.InvalidArgument, "Email Invalid Format")
+	}
+	return nil
+}
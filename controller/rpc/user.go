@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+
+	"friend_connection_rest_api/proto/friendpb"
+	"friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type userServer struct {
+	friendpb.UnimplementedUsersServer
+	service user.UserService
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *friendpb.CreateUserRequest) (*friendpb.Success, error) {
+	if utils.ValidateEmail(req.Email) == false {
+		return nil, status.Error(codes.InvalidArgument, "Invalid Email")
+	}
+
+	if err := s.service.CreateNewUser(ctx, user.Users{Email: req.Email}); err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.Success{Success: true}, nil
+}
+
+// ListUsers list the users visible to the caller
+func (s *userServer) ListUsers(ctx context.Context, req *friendpb.ListUsersRequest) (*friendpb.ListUsersResponse, error) {
+	rs, err := s.service.GetListUser(ctx, Caller(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &friendpb.ListUsersResponse{Users: rs, Count: uint32(len(rs))}, nil
+}
+
+func (s *userServer) VerifyUser(ctx context.Context, req *friendpb.VerifyUserRequest) (*friendpb.VerifyUserResponse, error) {
+	if utils.ValidateEmail(req.Email) == false {
+		return nil, status.Error(codes.InvalidArgument, "Invalid Email")
+	}
+
+	accessToken, err := s.service.VerifyUser(ctx, req.Email, req.Token)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.VerifyUserResponse{Success: true, AccessToken: accessToken}, nil
+}
+
+func (s *userServer) Login(ctx context.Context, req *friendpb.LoginRequest) (*friendpb.Success, error) {
+	if utils.ValidateEmail(req.Email) == false {
+		return nil, status.Error(codes.InvalidArgument, "Invalid Email")
+	}
+
+	if err := s.service.Login(ctx, req.Email); err != nil {
+		return nil, toStatus(err)
+	}
+	return &friendpb.Success{Success: true}, nil
+}
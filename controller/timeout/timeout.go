@@ -0,0 +1,76 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	httpRes "friend_connection_rest_api/controller/common_respone"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout set the deadline of the request context, the routes map override the default timeout by route path.
+// A timeout lower or equal to zero means no deadline.
+// Service queries are cancelled when the deadline is exceeded and the error respone is replaced by 504
+func Timeout(defaultTimeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routes[c.FullPath()]
+		if !ok {
+			timeout = defaultTimeout
+		}
+
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Next()
+
+		if !c.Writer.Written() && ctx.Err() == context.DeadlineExceeded {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, httpRes.HTTPError{Message: "Request Timeout"})
+		}
+	}
+}
+
+// timeoutWriter replace an error respone written after the deadline was exceeded,
+// a successful respone is kept because the writes of the service were committed
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+	replaced bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && !w.Written() && w.ctx.Err() == context.DeadlineExceeded {
+		w.timedOut = true
+		code = http.StatusGatewayTimeout
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if !w.timedOut {
+		return w.ResponseWriter.Write(data)
+	}
+
+	if !w.replaced {
+		w.replaced = true
+		body, _ := json.Marshal(httpRes.HTTPError{Message: "Request Timeout"})
+		if _, err := w.ResponseWriter.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *timeoutWriter) WriteString(data string) (int, error) {
+	return w.Write([]byte(data))
+}
@@ -0,0 +1,96 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	httpRes "friend_connection_rest_api/controller/common_respone"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	testCase := []struct {
+		scenario       string
+		path           string
+		handlerStatus  int
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Served Before Deadline",
+			path:           "/fast",
+			handlerStatus:  http.StatusBadRequest,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"any error"}`,
+		},
+		{
+			scenario:       "Error After Deadline",
+			path:           "/slow",
+			handlerStatus:  http.StatusBadRequest,
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   `{"error":"Request Timeout"}`,
+		},
+		{
+			scenario:       "Success After Deadline",
+			path:           "/slow",
+			handlerStatus:  http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true}`,
+		},
+		{
+			scenario:       "Nothing Written After Deadline",
+			path:           "/slow",
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   `{"error":"Request Timeout"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			r := gin.New()
+			r.Use(Timeout(time.Minute, map[string]time.Duration{"/slow": time.Millisecond}))
+
+			handler := func(c *gin.Context) {
+				if c.FullPath() == "/slow" {
+					<-c.Request.Context().Done()
+				}
+				_, hasDeadline := c.Request.Context().Deadline()
+				assert.Equal(t, true, hasDeadline)
+
+				if tc.handlerStatus == http.StatusOK {
+					c.JSON(tc.handlerStatus, httpRes.HTTPSuccess{Success: true})
+				} else if tc.handlerStatus != 0 {
+					c.JSON(tc.handlerStatus, httpRes.HTTPError{Message: "any error"})
+				}
+			}
+			r.GET("/fast", handler)
+			r.GET("/slow", handler)
+
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestTimeoutDisabled(t *testing.T) {
+	r := gin.New()
+	r.Use(Timeout(0, nil))
+	r.GET("/", func(c *gin.Context) {
+		_, hasDeadline := c.Request.Context().Deadline()
+		assert.Equal(t, false, hasDeadline)
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"friend_connection_rest_api/utils/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Setup(exporter, true)
+
+	r := gin.New()
+	r.Use(Tracing())
+	r.GET("/users/:email/circles", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+
+	testCase := []struct {
+		scenario       string
+		path           string
+		traceparent    string
+		expectedName   string
+		expectedRoute  string
+		expectedStatus int
+		expectedCode   codes.Code
+		expectedParent string
+	}{
+		{
+			scenario:       "Route Pattern",
+			path:           "/users/abc@gmail.com/circles",
+			expectedName:   "GET /users/:email/circles",
+			expectedRoute:  "/users/:email/circles",
+			expectedStatus: http.StatusOK,
+			expectedCode:   codes.Unset,
+		},
+		{
+			scenario:       "Propagate Trace Context",
+			path:           "/users/abc@gmail.com/circles",
+			traceparent:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expectedName:   "GET /users/:email/circles",
+			expectedRoute:  "/users/:email/circles",
+			expectedStatus: http.StatusOK,
+			expectedCode:   codes.Unset,
+			expectedParent: "00f067aa0ba902b7",
+		},
+		{
+			scenario:       "Server Error",
+			path:           "/fail",
+			expectedName:   "GET /fail",
+			expectedRoute:  "/fail",
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   codes.Error,
+		},
+		{
+			scenario:       "Unmatched Route",
+			path:           "/not-found",
+			expectedName:   "HTTP GET",
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   codes.Unset,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			exporter.Reset()
+
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			spans := exporter.GetSpans()
+			if assert.Len(t, spans, 1) {
+				span := spans[0]
+				assert.Equal(t, tc.expectedName, span.Name)
+				assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+				assert.Equal(t, tc.expectedCode, span.Status.Code)
+				assert.Contains(t, span.Attributes, attribute.String("http.route", tc.expectedRoute))
+				assert.Contains(t, span.Attributes, attribute.Int("http.status_code", tc.expectedStatus))
+				if tc.expectedParent != "" {
+					assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+					assert.Equal(t, tc.expectedParent, span.Parent.SpanID().String())
+					assert.True(t, span.Parent.IsRemote())
+				} else {
+					assert.False(t, span.Parent.IsValid())
+				}
+			}
+		})
+	}
+}
@@ -9,8 +9,31 @@ type RequestCreateUser struct {
 	Email string `json:"email" binding:"required"`
 }
 
+type RequestVerifyUser struct {
+	Email string `json:"email" binding:"required"`
+	Token string `json:"token" binding:"required"`
+}
+
+type ResponeVerifyUser struct {
+	Success     bool   `json:"success" example:"true"`
+	AccessToken string `json:"access_token"`
+}
+
+type RequestLogin struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// PrivacySetting is used for both request and respone, visibility is one of everyone, friends, only_me
+// and friend_request is one of everyone, friends_of_friends, nobody
+type PrivacySetting struct {
+	FriendList              string `json:"friend_list" binding:"required" example:"friends"`
+	MutualFriends           string `json:"mutual_friends" binding:"required" example:"everyone"`
+	FriendRequest           string `json:"friend_request" binding:"required" example:"friends_of_friends"`
+	NotifyNonFriendMentions *bool  `json:"notify_non_friend_mentions" binding:"required" example:"true"`
+}
+
 type HTTPSuccess struct {
-	Success bool `json:"success" example:true`
+	Success bool `json:"success" example:"true"`
 }
 
 type HTTPError struct {
@@ -3,6 +3,7 @@ package user
 import (
 	"net/http"
 
+	"friend_connection_rest_api/controller/auth"
 	httpRes "friend_connection_rest_api/controller/common_respone"
 	"friend_connection_rest_api/services/user"
 	userService "friend_connection_rest_api/services/user"
@@ -35,7 +36,7 @@ func CreateNewUserController(c *gin.Context, service userService.UserService) {
 		return
 	}
 
-	rs := service.CreateNewUser(user.Users{Email: ur.Email})
+	rs := service.CreateNewUser(c.Request.Context(), user.Users{Email: ur.Email})
 
 	if rs == nil {
 		c.JSON(201, httpRes.HTTPSuccess{Success: true})
@@ -45,17 +46,188 @@ func CreateNewUserController(c *gin.Context, service userService.UserService) {
 	c.JSON(400, httpRes.HTTPError{Message: rs.Error()})
 }
 
+// VerifyUserController godoc
+// @Summary Verify email address of user
+// @Description Confirm email address with the token was sent on user creation or login, an access token is returned
+// @Tags User
+// @Consume json
+// @Param request body RequestVerifyUser true "RequestVerifyUser"
+// @Produce  json
+// @Success 200 {object} ResponeVerifyUser
+// @Failure 400 {object} httpRes.HTTPError
+// @Router /verify [post]
+func VerifyUserController(c *gin.Context, service userService.UserService) {
+	var req RequestVerifyUser
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(req.Email) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Invalid Email"})
+		return
+	}
+
+	accessToken, err := service.VerifyUser(c.Request.Context(), req.Email, req.Token)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ResponeVerifyUser{Success: true, AccessToken: accessToken})
+}
+
+// LoginController godoc
+// @Summary Login
+// @Description Send a new verification token to a registered user, it is exchanged for an access token on /verify
+// @Tags User
+// @Consume json
+// @Param request body RequestLogin true "RequestLogin"
+// @Produce  json
+// @Success 200 {object} httpRes.HTTPSuccess
+// @Failure 400 {object} httpRes.HTTPError
+// @Router /login [post]
+func LoginController(c *gin.Context, service userService.UserService) {
+	var req RequestLogin
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if utils.ValidateEmail(req.Email) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Invalid Email"})
+		return
+	}
+
+	rs := service.Login(c.Request.Context(), req.Email)
+
+	if rs != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: rs.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, httpRes.HTTPSuccess{Success: true})
+}
+
+// GetPrivacySettingController godoc
+// @Summary Get privacy setting
+// @Description Get privacy setting of the authenticated user
+// @Tags User
+// @Security BearerAuth
+// @Param email path string true "User email"
+// @Produce  json
+// @Success 200 {object} PrivacySetting
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/privacy [get]
+func GetPrivacySettingController(c *gin.Context, service userService.UserService) {
+	email := c.Param("email")
+
+	if !requireOwner(c, email) {
+		return
+	}
+
+	rs, err := service.GetPrivacySetting(c.Request.Context(), email)
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toPrivacySetting(rs))
+}
+
+// UpdatePrivacySettingController godoc
+// @Summary Update privacy setting
+// @Description Update privacy setting of the authenticated user
+// @Tags User
+// @Security BearerAuth
+// @Consume json
+// @Param email path string true "User email"
+// @Param request body PrivacySetting true "PrivacySetting"
+// @Produce  json
+// @Success 200 {object} PrivacySetting
+// @Failure 400 {object} httpRes.HTTPError
+// @Failure 401 {object} httpRes.HTTPError
+// @Failure 403 {object} httpRes.HTTPError
+// @Router /users/{email}/privacy [put]
+func UpdatePrivacySettingController(c *gin.Context, service userService.UserService) {
+	email := c.Param("email")
+
+	if !requireOwner(c, email) {
+		return
+	}
+
+	var req PrivacySetting
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "BindJson Error, cause body request invalid"})
+		return
+	}
+
+	if !validVisibility(req.FriendList) || !validVisibility(req.MutualFriends) || !validFriendRequest(req.FriendRequest) {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Invalid Privacy Setting"})
+		return
+	}
+
+	setting := user.PrivacySetting{
+		Email:                   email,
+		FriendList:              req.FriendList,
+		MutualFriends:           req.MutualFriends,
+		FriendRequest:           req.FriendRequest,
+		NotifyNonFriendMentions: *req.NotifyNonFriendMentions,
+	}
+
+	if err := service.UpdatePrivacySetting(c.Request.Context(), setting); err != nil {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toPrivacySetting(setting))
+}
+
+// requireOwner write the error respone when the caller is not the owner of the resource
+func requireOwner(c *gin.Context, email string) bool {
+	if utils.ValidateEmail(email) == false {
+		c.JSON(http.StatusBadRequest, httpRes.HTTPError{Message: "Invalid Email"})
+		return false
+	}
+
+	return auth.RequireCaller(c, email)
+}
+
+func validVisibility(value string) bool {
+	return value == user.VisibilityEveryone || value == user.VisibilityFriends || value == user.VisibilityOnlyMe
+}
+
+func validFriendRequest(value string) bool {
+	return value == user.FriendRequestEveryone || value == user.FriendRequestFriendsOfFriends || value == user.FriendRequestNobody
+}
+
+func toPrivacySetting(setting user.PrivacySetting) PrivacySetting {
+	notify := setting.NotifyNonFriendMentions
+	return PrivacySetting{
+		FriendList:              setting.FriendList,
+		MutualFriends:           setting.MutualFriends,
+		FriendRequest:           setting.FriendRequest,
+		NotifyNonFriendMentions: &notify,
+	}
+}
+
 // GetListUsersController godoc
 // @Summary List users
-// @Description Get list users
+// @Description Get list users, users have a block with the authenticated caller are hidden
 // @Tags User
+// @Security BearerAuth
 // @Produce  json
 // @Success 200 {object} ResponeListUser
 // @Failure 500 {object} httpRes.HTTPError
 // @Router /list-users [get]
 func GetListUsersController(c *gin.Context, service userService.UserService) {
+	viewer := auth.Caller(c)
 
-	rs, err := service.GetListUser()
+	rs, err := service.GetListUser(c.Request.Context(), viewer)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, HTTPError{Message: err.Error()})
@@ -9,10 +9,12 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"friend_connection_rest_api/controller/auth"
 	"friend_connection_rest_api/services/user"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestCreateNewUserController(t *testing.T) {
@@ -54,9 +56,9 @@ func TestCreateNewUserController(t *testing.T) {
 
 			if tc.inputRequest != nil {
 				if tc.scenario == "Create New User Fail" {
-					userMock.On("CreateNewUser", user.Users{Email: tc.inputRequest.Email}).Return(errors.New("Any error"))
+					userMock.On("CreateNewUser", mock.Anything, user.Users{Email: tc.inputRequest.Email}).Return(errors.New("Any error"))
 				} else {
-					userMock.On("CreateNewUser", user.Users{Email: tc.inputRequest.Email}).Return(nil)
+					userMock.On("CreateNewUser", mock.Anything, user.Users{Email: tc.inputRequest.Email}).Return(nil)
 				}
 			}
 
@@ -119,10 +121,11 @@ func TestGetListUsersController(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			mockUser := new(user.UserMockService)
-			mockUser.On("GetListUser").Return(tc.mockRespone, tc.mockError)
+			mockUser.On("GetListUser", mock.Anything, "").Return(tc.mockRespone, tc.mockError)
 
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest("GET", "/list-users", nil)
 
 			GetListUsersController(c, mockUser)
 
@@ -139,3 +142,253 @@ func TestGetListUsersController(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyUserController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario            string
+		inputRequest        *RequestVerifyUser
+		mockError           error
+		expectedStatus      int
+		expectedSuccessBody string
+		expectedErrorBody   string
+	}{
+		{
+			scenario:            "Verify User Success",
+			inputRequest:        &RequestVerifyUser{Email: "abc@gmail.com", Token: "token"},
+			expectedStatus:      http.StatusOK,
+			expectedSuccessBody: `{"success":true,"access_token":"access"}`,
+		},
+		{
+			scenario:          "Verify User Fail",
+			inputRequest:      &RequestVerifyUser{Email: "abc@gmail.com", Token: "token"},
+			mockError:         errors.New("Invalid Verification Token"),
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"Invalid Verification Token"}`,
+		},
+		{
+			scenario:          "Invalid User Email",
+			inputRequest:      &RequestVerifyUser{Email: "abc", Token: "token"},
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"Invalid Email"}`,
+		},
+		{
+			scenario:          "Empty request body",
+			expectedStatus:    http.StatusBadRequest,
+			expectedErrorBody: `{"error":"BindJson Error, cause body request invalid"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			userMock := new(user.UserMockService)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			if tc.inputRequest != nil {
+				accessToken := ""
+				if tc.mockError == nil {
+					accessToken = "access"
+				}
+				userMock.On("VerifyUser", mock.Anything, tc.inputRequest.Email, tc.inputRequest.Token).Return(accessToken, tc.mockError)
+				jsonValue, _ := json.Marshal(tc.inputRequest)
+				c.Request, _ = http.NewRequest("POST", "/verify", bytes.NewBuffer(jsonValue))
+			} else {
+				c.Request, _ = http.NewRequest("POST", "/verify", nil)
+			}
+
+			// When
+			VerifyUserController(c, userMock)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			actualResult := string(body)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedSuccessBody, actualResult)
+			} else {
+				assert.Equal(t, tc.expectedErrorBody, actualResult)
+			}
+		})
+	}
+}
+
+func TestGetListUsersControllerWithViewer(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		caller         string
+		url            string
+		mockRespone    []string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Get List User Hide Blocked Users",
+			caller:         "viewer@gmail.com",
+			url:            "/list-users",
+			mockRespone:    []string{"viewer@gmail.com", "friend@gmail.com"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"list_users":["viewer@gmail.com","friend@gmail.com"],"count":2}`,
+		},
+		{
+			scenario:       "Viewer Is Not Read From Query",
+			caller:         "viewer@gmail.com",
+			url:            "/list-users?email=other@gmail.com",
+			mockRespone:    []string{"viewer@gmail.com"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"list_users":["viewer@gmail.com"],"count":1}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			mockUser := new(user.UserMockService)
+			mockUser.On("GetListUser", mock.Anything, tc.caller).Return(tc.mockRespone, nil)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			auth.SetCaller(c, tc.caller)
+			c.Request, _ = http.NewRequest("GET", tc.url, nil)
+
+			GetListUsersController(c, mockUser)
+
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestLoginController(t *testing.T) {
+	// Given
+	testCase := []struct {
+		scenario       string
+		inputRequest   *RequestLogin
+		mockError      error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Login Success",
+			inputRequest:   &RequestLogin{Email: "abc@gmail.com"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"success":true}`,
+		},
+		{
+			scenario:       "Login Fail",
+			inputRequest:   &RequestLogin{Email: "abc@gmail.com"},
+			mockError:      errors.New("User Not Exist"),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"User Not Exist"}`,
+		},
+		{
+			scenario:       "Invalid User Email",
+			inputRequest:   &RequestLogin{Email: "abc"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid Email"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			userMock := new(user.UserMockService)
+			userMock.On("Login", mock.Anything, tc.inputRequest.Email).Return(tc.mockError)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			jsonValue, _ := json.Marshal(tc.inputRequest)
+			c.Request, _ = http.NewRequest("POST", "/login", bytes.NewBuffer(jsonValue))
+
+			// When
+			LoginController(c, userMock)
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
+func TestPrivacySettingController(t *testing.T) {
+	// Given
+	notify := false
+	validRequest := &PrivacySetting{FriendList: "friends", MutualFriends: "only_me", FriendRequest: "nobody", NotifyNonFriendMentions: &notify}
+	testCase := []struct {
+		scenario       string
+		method         string
+		caller         string
+		inputRequest   *PrivacySetting
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			scenario:       "Get Privacy Setting Success",
+			method:         "GET",
+			caller:         "abc@gmail.com",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"friend_list":"everyone","mutual_friends":"everyone","friend_request":"everyone","notify_non_friend_mentions":true}`,
+		},
+		{
+			scenario:       "Get Privacy Setting Anonymous",
+			method:         "GET",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Authentication Required"}`,
+		},
+		{
+			scenario:       "Get Privacy Setting Of Other User",
+			method:         "GET",
+			caller:         "other@gmail.com",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Permission Denied"}`,
+		},
+		{
+			scenario:       "Update Privacy Setting Success",
+			method:         "PUT",
+			caller:         "abc@gmail.com",
+			inputRequest:   validRequest,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"friend_list":"friends","mutual_friends":"only_me","friend_request":"nobody","notify_non_friend_mentions":false}`,
+		},
+		{
+			scenario:       "Update Privacy Setting Invalid Value",
+			method:         "PUT",
+			caller:         "abc@gmail.com",
+			inputRequest:   &PrivacySetting{FriendList: "public", MutualFriends: "only_me", FriendRequest: "nobody", NotifyNonFriendMentions: &notify},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid Privacy Setting"}`,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			userMock := new(user.UserMockService)
+			userMock.On("GetPrivacySetting", mock.Anything, "abc@gmail.com").Return(user.DefaultPrivacySetting("abc@gmail.com"), nil)
+			userMock.On("UpdatePrivacySetting", mock.Anything, user.PrivacySetting{Email: "abc@gmail.com", FriendList: "friends", MutualFriends: "only_me", FriendRequest: "nobody"}).Return(nil)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "email", Value: "abc@gmail.com"}}
+			if tc.caller != "" {
+				auth.SetCaller(c, tc.caller)
+			}
+			jsonValue, _ := json.Marshal(tc.inputRequest)
+			c.Request, _ = http.NewRequest(tc.method, "/users/abc@gmail.com/privacy", bytes.NewBuffer(jsonValue))
+
+			// When
+			if tc.method == "GET" {
+				GetPrivacySettingController(c, userMock)
+			} else {
+				UpdatePrivacySettingController(c, userMock)
+			}
+
+			// Then
+			body, _ := ioutil.ReadAll(w.Result().Body)
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
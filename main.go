@@ -1,20 +1,43 @@
 package main
 
 import (
-	"log"
+	"context"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	handlers "friend_connection_rest_api/controller"
 	"friend_connection_rest_api/docs"
 	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
+	"friend_connection_rest_api/utils/tracing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // @in header
 // @name Authorization
 func main() {
+	// Spans are exported with TRACING_EXPORTER, tracing is disabled when it is not set
+	exporter, err := tracing.NewExporter(context.Background(), os.Getenv("TRACING_EXPORTER"))
+	if err != nil {
+		logger.Default().Error("tracing exporter failed", "error", err)
+		os.Exit(1)
+	}
+	var provider *sdktrace.TracerProvider
+	if exporter != nil {
+		provider = tracing.Setup(exporter, false)
+	}
+
+	// Background work of the services is stopped on shutdown, before the database pool is closed
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
 	db := utils.CreateConnection()
-	r := handlers.Setup(db)
+	r, grpcServer := handlers.Setup(background, db)
 	docs.SwaggerInfo.Title = "Rest API for friend connection"
 	docs.SwaggerInfo.Description = "Restful api for friend connection api made by Go-Language and Gin framework"
 	docs.SwaggerInfo.Version = "2.0"
@@ -25,6 +48,73 @@ func main() {
 	if port == "" {
 		port = "3000"
 	}
-	log.Println("Server started on: http://localhost:" + port)
-	http.ListenAndServe(":"+port, r)
+	server := &http.Server{Addr: ":" + port, Handler: r}
+
+	go func() {
+		logger.Default().Info("server started", "address", "http://localhost:"+port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Default().Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// gRPC API is served on its own port
+	var grpcPort string = os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "50051"
+	}
+	listener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		logger.Default().Error("grpc listen failed", "error", err)
+		os.Exit(1)
+	}
+
+	go func() {
+		logger.Default().Info("grpc server started", "address", listener.Addr().String())
+		if err := grpcServer.Serve(listener); err != nil {
+			logger.Default().Error("grpc server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// In-flight requests are drained on SIGTERM or SIGINT before the database pool is closed
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	<-quit
+	logger.Default().Info("shutting down server")
+	stopBackground()
+
+	ctx, cancel := context.WithTimeout(context.Background(), utils.GetEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Default().Error("server forced to shutdown", "error", err)
+	}
+
+	// Calls and streams in flight are drained the same way, they are cancelled when the timeout is reached
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Default().Error("grpc server forced to shutdown", "error", ctx.Err())
+		grpcServer.Stop()
+	}
+
+	// Pending spans are flushed to the exporter
+	if provider != nil {
+		if err := provider.Shutdown(ctx); err != nil {
+			logger.Default().Error("tracing shutdown failed", "error", err)
+		}
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logger.Default().Error("close database failed", "error", err)
+		}
+	}
+	logger.Default().Info("server stopped")
 }
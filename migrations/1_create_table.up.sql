@@ -1,6 +1,36 @@
 CREATE TABLE users(
 	id SERIAL PRIMARY KEY,
-	email TEXT UNIQUE NOT NULL
+	email TEXT UNIQUE NOT NULL,
+	verified BOOL NOT NULL DEFAULT false
+);
+
+CREATE TABLE verification_tokens(
+	id SERIAL PRIMARY KEY,
+	email TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	FOREIGN KEY (email)
+      REFERENCES users (email)
+);
+
+CREATE TABLE access_tokens(
+	id SERIAL PRIMARY KEY,
+	email TEXT NOT NULL,
+	token_hash TEXT UNIQUE NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	FOREIGN KEY (email)
+      REFERENCES users (email)
+);
+
+CREATE TABLE privacy_settings(
+	id SERIAL PRIMARY KEY,
+	email TEXT UNIQUE NOT NULL,
+	friend_list TEXT NOT NULL DEFAULT 'everyone',
+	mutual_friends TEXT NOT NULL DEFAULT 'everyone',
+	friend_request TEXT NOT NULL DEFAULT 'everyone',
+	notify_non_friend_mentions BOOL NOT NULL DEFAULT true,
+	FOREIGN KEY (email)
+      REFERENCES users (email)
 );
 
 CREATE TABLE friendship(
@@ -8,10 +38,101 @@ CREATE TABLE friendship(
 	first_user TEXT NOT NULL,
 	second_user TEXT NOT NULL,
 	is_friend BOOL NULL DEFAULT false,
-	update_status INTEGER NULL DEFAULT 0,
+	first_follows_second BOOL NOT NULL DEFAULT false,
+	second_follows_first BOOL NOT NULL DEFAULT false,
+	first_blocks_second BOOL NOT NULL DEFAULT false,
+	second_blocks_first BOOL NOT NULL DEFAULT false,
 	PRIMARY KEY (id, first_user, second_user),
+	UNIQUE (first_user, second_user),
+	CONSTRAINT chk_friendship_pair CHECK (first_user < second_user),
   	FOREIGN KEY (first_user)
       REFERENCES users (email),
   	FOREIGN KEY (second_user)
       REFERENCES users (email)
-)
\ No newline at end of file
+);
+
+CREATE TABLE invitations(
+	id SERIAL PRIMARY KEY,
+	requestor TEXT NOT NULL,
+	email TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	UNIQUE (requestor, email, kind),
+	FOREIGN KEY (requestor)
+      REFERENCES users (email)
+);
+
+CREATE TABLE mutes(
+	id SERIAL PRIMARY KEY,
+	muter TEXT NOT NULL,
+	target TEXT NOT NULL,
+	UNIQUE (muter, target),
+	FOREIGN KEY (muter)
+      REFERENCES users (email),
+	FOREIGN KEY (target)
+      REFERENCES users (email)
+);
+
+CREATE TABLE circles(
+	id SERIAL PRIMARY KEY,
+	owner TEXT NOT NULL,
+	name TEXT NOT NULL,
+	UNIQUE (owner, name),
+	FOREIGN KEY (owner)
+      REFERENCES users (email)
+);
+
+CREATE TABLE circle_members(
+	id SERIAL PRIMARY KEY,
+	circle_id INTEGER NOT NULL,
+	email TEXT NOT NULL,
+	UNIQUE (circle_id, email),
+	FOREIGN KEY (circle_id)
+      REFERENCES circles (id),
+	FOREIGN KEY (email)
+      REFERENCES users (email)
+);
+
+CREATE TABLE idempotency_keys(
+	key_hash TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	body BYTEA NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+CREATE TABLE relationship_versions(
+	email TEXT PRIMARY KEY,
+	version BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION bump_relationship_versions() RETURNS trigger AS $$
+	DECLARE
+		emails text[] := '{}';
+	BEGIN
+		FOR i IN 0 .. TG_NARGS - 1 LOOP
+			IF TG_OP <> 'INSERT' THEN
+				emails := emails || (to_jsonb(OLD) ->> TG_ARGV[i]);
+			END IF;
+			IF TG_OP <> 'DELETE' THEN
+				emails := emails || (to_jsonb(NEW) ->> TG_ARGV[i]);
+			END IF;
+		END LOOP;
+		INSERT INTO relationship_versions (email, version, updated_at)
+			SELECT DISTINCT email, 1, now() FROM unnest(emails) AS email WHERE email IS NOT NULL ORDER BY email
+			ON CONFLICT (email) DO UPDATE SET version = relationship_versions.version + 1, updated_at = EXCLUDED.updated_at;
+		RETURN NULL;
+	END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_friendship_relationship_version AFTER INSERT OR UPDATE OR DELETE ON friendship
+	FOR EACH ROW EXECUTE PROCEDURE bump_relationship_versions('first_user', 'second_user');
+
+CREATE TRIGGER trg_invitations_relationship_version AFTER INSERT OR UPDATE OR DELETE ON invitations
+	FOR EACH ROW EXECUTE PROCEDURE bump_relationship_versions('requestor', 'email');
+
+CREATE TRIGGER trg_privacy_settings_relationship_version AFTER INSERT OR UPDATE OR DELETE ON privacy_settings
+	FOR EACH ROW EXECUTE PROCEDURE bump_relationship_versions('email');
\ No newline at end of file
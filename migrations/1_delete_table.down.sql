@@ -1,2 +1,12 @@
-DROP TABLE users;
-DROP TABLE friendship
\ No newline at end of file
+DROP TABLE relationship_versions;
+DROP TABLE idempotency_keys;
+DROP TABLE privacy_settings;
+DROP TABLE access_tokens;
+DROP TABLE circle_members;
+DROP TABLE circles;
+DROP TABLE mutes;
+DROP TABLE invitations;
+DROP TABLE verification_tokens;
+DROP TABLE friendship;
+DROP FUNCTION bump_relationship_versions;
+DROP TABLE users
\ No newline at end of file
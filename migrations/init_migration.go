@@ -1,19 +1,211 @@
 package migration
 
 import (
+	"errors"
+	"fmt"
+
 	"friend_connection_rest_api/services/friendship"
+	"friend_connection_rest_api/services/idempotency"
 	"friend_connection_rest_api/services/user"
 
 	"gorm.io/gorm"
 )
 
-func InitMigration(dbconn *gorm.DB) {
+// InitMigration create the missing tables and migrate the data of the previous schemas
+func InitMigration(dbconn *gorm.DB) error {
 
 	if oke := dbconn.Migrator().HasTable(&user.Users{}); !oke {
 		dbconn.AutoMigrate(&user.Users{})
 	}
 
+	// Users were created before email verification are considered verified
+	if oke := dbconn.Migrator().HasColumn(&user.Users{}, "verified"); !oke {
+		dbconn.Migrator().AddColumn(&user.Users{}, "Verified")
+		dbconn.Model(&user.Users{}).Where("1 = 1").Update("verified", true)
+	}
+
+	if oke := dbconn.Migrator().HasTable(&user.VerificationToken{}); !oke {
+		dbconn.AutoMigrate(&user.VerificationToken{})
+	}
+
+	if oke := dbconn.Migrator().HasTable(&user.AccessToken{}); !oke {
+		dbconn.AutoMigrate(&user.AccessToken{})
+	}
+
+	if oke := dbconn.Migrator().HasTable(&user.PrivacySetting{}); !oke {
+		dbconn.AutoMigrate(&user.PrivacySetting{})
+	}
+
 	if oke := dbconn.Migrator().HasTable(&friendship.Friendship{}); !oke {
 		dbconn.AutoMigrate(&friendship.Friendship{})
 	}
+
+	// update_status is replaced by explicit flags, the data is migrated in one transaction
+	// so a failed migration leave the column to be migrated again on the next start
+	if oke := dbconn.Migrator().HasColumn(&friendship.Friendship{}, "update_status"); oke {
+		if err := dbconn.Transaction(migrateUpdateStatus); err != nil {
+			return err
+		}
+	}
+
+	// Each pair is stored once in canonical order, the rows are rewritten in one transaction
+	// so a failed migration leave them to be migrated again on the next start
+	if oke := dbconn.Migrator().HasIndex(&friendship.Friendship{}, "idx_friendship_pair"); !oke {
+		if err := dbconn.Transaction(migrateCanonicalPairs); err != nil {
+			return err
+		}
+	}
+
+	if oke := dbconn.Migrator().HasTable(&friendship.Invitation{}); !oke {
+		dbconn.AutoMigrate(&friendship.Invitation{})
+	}
+
+	if oke := dbconn.Migrator().HasTable(&friendship.Mute{}); !oke {
+		dbconn.AutoMigrate(&friendship.Mute{})
+	}
+
+	if oke := dbconn.Migrator().HasTable(&friendship.Circle{}); !oke {
+		dbconn.AutoMigrate(&friendship.Circle{})
+	}
+
+	if oke := dbconn.Migrator().HasTable(&friendship.CircleMember{}); !oke {
+		dbconn.AutoMigrate(&friendship.CircleMember{})
+	}
+
+	if oke := dbconn.Migrator().HasTable(&idempotency.IdempotencyKey{}); !oke {
+		dbconn.AutoMigrate(&idempotency.IdempotencyKey{})
+	}
+
+	// Relationship versions are bumped by triggers so no write can miss them, the columns holding the emails
+	// are the arguments of the trigger. Rows are locked in email order so concurrent writers can not deadlock
+	if oke := dbconn.Migrator().HasTable(&friendship.RelationshipVersion{}); !oke {
+		dbconn.AutoMigrate(&friendship.RelationshipVersion{})
+		dbconn.Exec(`CREATE OR REPLACE FUNCTION bump_relationship_versions() RETURNS trigger AS $$
+			DECLARE
+				emails text[] := '{}';
+			BEGIN
+				FOR i IN 0 .. TG_NARGS - 1 LOOP
+					IF TG_OP <> 'INSERT' THEN
+						emails := emails || (to_jsonb(OLD) ->> TG_ARGV[i]);
+					END IF;
+					IF TG_OP <> 'DELETE' THEN
+						emails := emails || (to_jsonb(NEW) ->> TG_ARGV[i]);
+					END IF;
+				END LOOP;
+				INSERT INTO relationship_versions (email, version, updated_at)
+					SELECT DISTINCT email, 1, now() FROM unnest(emails) AS email WHERE email IS NOT NULL ORDER BY email
+					ON CONFLICT (email) DO UPDATE SET version = relationship_versions.version + 1, updated_at = EXCLUDED.updated_at;
+				RETURN NULL;
+			END
+			$$ LANGUAGE plpgsql`)
+		triggers := map[string]string{
+			"friendships":      "'first_user', 'second_user'",
+			"invitations":      "'requestor', 'email'",
+			"privacy_settings": "'email'",
+		}
+		for table, columns := range triggers {
+			dbconn.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS trg_%s_relationship_version ON %s`, table, table))
+			dbconn.Exec(fmt.Sprintf(`CREATE TRIGGER trg_%s_relationship_version AFTER INSERT OR UPDATE OR DELETE ON %s
+				FOR EACH ROW EXECUTE PROCEDURE bump_relationship_versions(%s)`, table, table, columns))
+		}
+	}
+	return nil
+}
+
+// migrateCanonicalPairs swap reversed rows and merge duplicated rows into the oldest one before the unique index
+// is created, blockers stop following and self edges are deleted
+func migrateCanonicalPairs(tx *gorm.DB) error {
+	statements := []string{
+		`DELETE FROM friendships WHERE deleted_at IS NOT NULL`,
+		`UPDATE friendships SET
+			first_user = second_user, second_user = first_user,
+			first_follows_second = second_follows_first, second_follows_first = first_follows_second,
+			first_blocks_second = second_blocks_first, second_blocks_first = first_blocks_second
+			WHERE first_user > second_user`,
+		`UPDATE friendships AS f SET
+			is_friend = d.is_friend,
+			first_follows_second = d.first_follows_second, second_follows_first = d.second_follows_first,
+			first_blocks_second = d.first_blocks_second, second_blocks_first = d.second_blocks_first
+			FROM (SELECT MIN(id) AS id, BOOL_OR(is_friend) AS is_friend,
+				BOOL_OR(first_follows_second) AS first_follows_second, BOOL_OR(second_follows_first) AS second_follows_first,
+				BOOL_OR(first_blocks_second) AS first_blocks_second, BOOL_OR(second_blocks_first) AS second_blocks_first
+				FROM friendships GROUP BY first_user, second_user HAVING COUNT(*) > 1) AS d
+			WHERE f.id = d.id`,
+		`DELETE FROM friendships AS f USING friendships AS k
+			WHERE f.first_user = k.first_user AND f.second_user = k.second_user AND f.id > k.id`,
+		`UPDATE friendships SET first_follows_second = false WHERE first_blocks_second = true`,
+		`UPDATE friendships SET second_follows_first = false WHERE second_blocks_first = true`,
+		`DELETE FROM friendships WHERE first_user = second_user`,
+	}
+	for _, stm := range statements {
+		if err := tx.Exec(stm).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Migrator().CreateIndex(&friendship.Friendship{}, "idx_friendship_pair"); err != nil {
+		return err
+	}
+	if oke := tx.Migrator().HasConstraint(&friendship.Friendship{}, "chk_friendship_pair"); oke {
+		return nil
+	}
+	return tx.Migrator().CreateConstraint(&friendship.Friendship{}, "chk_friendship_pair")
+}
+
+// migrateUpdateStatus replace update_status by the follow and block flags. Follows keep the rule updates were
+// delivered with, update_status only told who receive updates so 0 is a pair without follow, not a block.
+// The only block update_status recorded is -1, stored by Block for a new pair, its first user is the blocker
+func migrateUpdateStatus(tx *gorm.DB) error {
+	for _, field := range []string{"FirstFollowsSecond", "SecondFollowsFirst", "FirstBlocksSecond", "SecondBlocksFirst"} {
+		if oke := tx.Migrator().HasColumn(&friendship.Friendship{}, field); !oke {
+			if err := tx.Migrator().AddColumn(&friendship.Friendship{}, field); err != nil {
+				return err
+			}
+		}
+	}
+
+	statements := []string{
+		`UPDATE friendships SET
+			first_follows_second = (is_friend = true OR update_status IN (1, 3)),
+			second_follows_first = update_status IN (2, 3),
+			first_blocks_second = update_status = -1,
+			second_blocks_first = false`,
+		// Blocker does not follow the blocked user
+		`UPDATE friendships SET first_follows_second = false WHERE first_blocks_second = true`,
+	}
+
+	for _, stm := range statements {
+		if err := tx.Exec(stm).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Migrator().DropColumn(&friendship.Friendship{}, "update_status")
+}
+
+// CheckMigration return an error when the schema is not the one InitMigration produce
+func CheckMigration(dbconn *gorm.DB) error {
+	models := []interface{}{
+		&user.Users{}, &user.VerificationToken{}, &user.AccessToken{}, &user.PrivacySetting{},
+		&friendship.Friendship{}, &friendship.Invitation{}, &friendship.Mute{}, &friendship.Circle{}, &friendship.CircleMember{},
+		&idempotency.IdempotencyKey{}, &friendship.RelationshipVersion{},
+	}
+	for _, model := range models {
+		if oke := dbconn.Migrator().HasTable(model); !oke {
+			return errors.New("Migration Not Applied")
+		}
+	}
+
+	if oke := dbconn.Migrator().HasColumn(&user.Users{}, "verified"); !oke {
+		return errors.New("Migration Not Applied")
+	}
+
+	if oke := dbconn.Migrator().HasColumn(&friendship.Friendship{}, "update_status"); oke {
+		return errors.New("Migration Not Applied")
+	}
+
+	if oke := dbconn.Migrator().HasIndex(&friendship.Friendship{}, "idx_friendship_pair"); !oke {
+		return errors.New("Migration Not Applied")
+	}
+	return nil
 }
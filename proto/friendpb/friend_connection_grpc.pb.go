@@ -0,0 +1,797 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package friendpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// UsersClient is the client API for Users service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UsersClient interface {
+	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*Success, error)
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	VerifyUser(ctx context.Context, in *VerifyUserRequest, opts ...grpc.CallOption) (*VerifyUserResponse, error)
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Success, error)
+}
+
+type usersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUsersClient(cc grpc.ClientConnInterface) UsersClient {
+	return &usersClient{cc}
+}
+
+func (c *usersClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*Success, error) {
+	out := new(Success)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Users/CreateUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error) {
+	out := new(ListUsersResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Users/ListUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) VerifyUser(ctx context.Context, in *VerifyUserRequest, opts ...grpc.CallOption) (*VerifyUserResponse, error) {
+	out := new(VerifyUserResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Users/VerifyUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Success, error) {
+	out := new(Success)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Users/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UsersServer is the server API for Users service.
+// All implementations must embed UnimplementedUsersServer
+// for forward compatibility
+type UsersServer interface {
+	CreateUser(context.Context, *CreateUserRequest) (*Success, error)
+	ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	VerifyUser(context.Context, *VerifyUserRequest) (*VerifyUserResponse, error)
+	Login(context.Context, *LoginRequest) (*Success, error)
+	mustEmbedUnimplementedUsersServer()
+}
+
+// UnimplementedUsersServer must be embedded to have forward compatible implementations.
+type UnimplementedUsersServer struct {
+}
+
+func (UnimplementedUsersServer) CreateUser(context.Context, *CreateUserRequest) (*Success, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
+}
+func (UnimplementedUsersServer) ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedUsersServer) VerifyUser(context.Context, *VerifyUserRequest) (*VerifyUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyUser not implemented")
+}
+func (UnimplementedUsersServer) Login(context.Context, *LoginRequest) (*Success, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedUsersServer) mustEmbedUnimplementedUsersServer() {}
+
+// UnsafeUsersServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UsersServer will
+// result in compilation errors.
+type UnsafeUsersServer interface {
+	mustEmbedUnimplementedUsersServer()
+}
+
+func RegisterUsersServer(s grpc.ServiceRegistrar, srv UsersServer) {
+	s.RegisterService(&Users_ServiceDesc, srv)
+}
+
+func _Users_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Users/CreateUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Users/ListUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_VerifyUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).VerifyUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Users/VerifyUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).VerifyUser(ctx, req.(*VerifyUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Users/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Users_ServiceDesc is the grpc.ServiceDesc for Users service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Users_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "friendconnection.v1.Users",
+	HandlerType: (*UsersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateUser",
+			Handler:    _Users_CreateUser_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _Users_ListUsers_Handler,
+		},
+		{
+			MethodName: "VerifyUser",
+			Handler:    _Users_VerifyUser_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Users_Login_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "friend_connection.proto",
+}
+
+// FriendshipsClient is the client API for Friendships service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FriendshipsClient interface {
+	AddFriend(ctx context.Context, in *FriendPairRequest, opts ...grpc.CallOption) (*AddFriendResponse, error)
+	ListFriends(ctx context.Context, in *ListFriendsRequest, opts ...grpc.CallOption) (*FriendsResponse, error)
+	ListMutualFriends(ctx context.Context, in *FriendPairRequest, opts ...grpc.CallOption) (*FriendsResponse, error)
+	GetRelationship(ctx context.Context, in *FriendPairRequest, opts ...grpc.CallOption) (*Relationship, error)
+}
+
+type friendshipsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFriendshipsClient(cc grpc.ClientConnInterface) FriendshipsClient {
+	return &friendshipsClient{cc}
+}
+
+func (c *friendshipsClient) AddFriend(ctx context.Context, in *FriendPairRequest, opts ...grpc.CallOption) (*AddFriendResponse, error) {
+	out := new(AddFriendResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Friendships/AddFriend", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *friendshipsClient) ListFriends(ctx context.Context, in *ListFriendsRequest, opts ...grpc.CallOption) (*FriendsResponse, error) {
+	out := new(FriendsResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Friendships/ListFriends", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *friendshipsClient) ListMutualFriends(ctx context.Context, in *FriendPairRequest, opts ...grpc.CallOption) (*FriendsResponse, error) {
+	out := new(FriendsResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Friendships/ListMutualFriends", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *friendshipsClient) GetRelationship(ctx context.Context, in *FriendPairRequest, opts ...grpc.CallOption) (*Relationship, error) {
+	out := new(Relationship)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Friendships/GetRelationship", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FriendshipsServer is the server API for Friendships service.
+// All implementations must embed UnimplementedFriendshipsServer
+// for forward compatibility
+type FriendshipsServer interface {
+	AddFriend(context.Context, *FriendPairRequest) (*AddFriendResponse, error)
+	ListFriends(context.Context, *ListFriendsRequest) (*FriendsResponse, error)
+	ListMutualFriends(context.Context, *FriendPairRequest) (*FriendsResponse, error)
+	GetRelationship(context.Context, *FriendPairRequest) (*Relationship, error)
+	mustEmbedUnimplementedFriendshipsServer()
+}
+
+// UnimplementedFriendshipsServer must be embedded to have forward compatible implementations.
+type UnimplementedFriendshipsServer struct {
+}
+
+func (UnimplementedFriendshipsServer) AddFriend(context.Context, *FriendPairRequest) (*AddFriendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddFriend not implemented")
+}
+func (UnimplementedFriendshipsServer) ListFriends(context.Context, *ListFriendsRequest) (*FriendsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFriends not implemented")
+}
+func (UnimplementedFriendshipsServer) ListMutualFriends(context.Context, *FriendPairRequest) (*FriendsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMutualFriends not implemented")
+}
+func (UnimplementedFriendshipsServer) GetRelationship(context.Context, *FriendPairRequest) (*Relationship, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRelationship not implemented")
+}
+func (UnimplementedFriendshipsServer) mustEmbedUnimplementedFriendshipsServer() {}
+
+// UnsafeFriendshipsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FriendshipsServer will
+// result in compilation errors.
+type UnsafeFriendshipsServer interface {
+	mustEmbedUnimplementedFriendshipsServer()
+}
+
+func RegisterFriendshipsServer(s grpc.ServiceRegistrar, srv FriendshipsServer) {
+	s.RegisterService(&Friendships_ServiceDesc, srv)
+}
+
+func _Friendships_AddFriend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendPairRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FriendshipsServer).AddFriend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Friendships/AddFriend",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FriendshipsServer).AddFriend(ctx, req.(*FriendPairRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Friendships_ListFriends_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFriendsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FriendshipsServer).ListFriends(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Friendships/ListFriends",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FriendshipsServer).ListFriends(ctx, req.(*ListFriendsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Friendships_ListMutualFriends_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendPairRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FriendshipsServer).ListMutualFriends(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Friendships/ListMutualFriends",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FriendshipsServer).ListMutualFriends(ctx, req.(*FriendPairRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Friendships_GetRelationship_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FriendPairRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FriendshipsServer).GetRelationship(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Friendships/GetRelationship",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FriendshipsServer).GetRelationship(ctx, req.(*FriendPairRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Friendships_ServiceDesc is the grpc.ServiceDesc for Friendships service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Friendships_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "friendconnection.v1.Friendships",
+	HandlerType: (*FriendshipsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddFriend",
+			Handler:    _Friendships_AddFriend_Handler,
+		},
+		{
+			MethodName: "ListFriends",
+			Handler:    _Friendships_ListFriends_Handler,
+		},
+		{
+			MethodName: "ListMutualFriends",
+			Handler:    _Friendships_ListMutualFriends_Handler,
+		},
+		{
+			MethodName: "GetRelationship",
+			Handler:    _Friendships_GetRelationship_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "friend_connection.proto",
+}
+
+// SubscriptionsClient is the client API for Subscriptions service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SubscriptionsClient interface {
+	Subscribe(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*AddFriendResponse, error)
+	Mute(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Success, error)
+	Unmute(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Success, error)
+}
+
+type subscriptionsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSubscriptionsClient(cc grpc.ClientConnInterface) SubscriptionsClient {
+	return &subscriptionsClient{cc}
+}
+
+func (c *subscriptionsClient) Subscribe(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*AddFriendResponse, error) {
+	out := new(AddFriendResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Subscriptions/Subscribe", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *subscriptionsClient) Mute(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Success, error) {
+	out := new(Success)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Subscriptions/Mute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *subscriptionsClient) Unmute(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Success, error) {
+	out := new(Success)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Subscriptions/Unmute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubscriptionsServer is the server API for Subscriptions service.
+// All implementations must embed UnimplementedSubscriptionsServer
+// for forward compatibility
+type SubscriptionsServer interface {
+	Subscribe(context.Context, *UpdateRequest) (*AddFriendResponse, error)
+	Mute(context.Context, *UpdateRequest) (*Success, error)
+	Unmute(context.Context, *UpdateRequest) (*Success, error)
+	mustEmbedUnimplementedSubscriptionsServer()
+}
+
+// UnimplementedSubscriptionsServer must be embedded to have forward compatible implementations.
+type UnimplementedSubscriptionsServer struct {
+}
+
+func (UnimplementedSubscriptionsServer) Subscribe(context.Context, *UpdateRequest) (*AddFriendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedSubscriptionsServer) Mute(context.Context, *UpdateRequest) (*Success, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Mute not implemented")
+}
+func (UnimplementedSubscriptionsServer) Unmute(context.Context, *UpdateRequest) (*Success, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unmute not implemented")
+}
+func (UnimplementedSubscriptionsServer) mustEmbedUnimplementedSubscriptionsServer() {}
+
+// UnsafeSubscriptionsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SubscriptionsServer will
+// result in compilation errors.
+type UnsafeSubscriptionsServer interface {
+	mustEmbedUnimplementedSubscriptionsServer()
+}
+
+func RegisterSubscriptionsServer(s grpc.ServiceRegistrar, srv SubscriptionsServer) {
+	s.RegisterService(&Subscriptions_ServiceDesc, srv)
+}
+
+func _Subscriptions_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SubscriptionsServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Subscriptions/Subscribe",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SubscriptionsServer).Subscribe(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Subscriptions_Mute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SubscriptionsServer).Mute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Subscriptions/Mute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SubscriptionsServer).Mute(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Subscriptions_Unmute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SubscriptionsServer).Unmute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Subscriptions/Unmute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SubscriptionsServer).Unmute(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Subscriptions_ServiceDesc is the grpc.ServiceDesc for Subscriptions service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Subscriptions_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "friendconnection.v1.Subscriptions",
+	HandlerType: (*SubscriptionsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Subscribe",
+			Handler:    _Subscriptions_Subscribe_Handler,
+		},
+		{
+			MethodName: "Mute",
+			Handler:    _Subscriptions_Mute_Handler,
+		},
+		{
+			MethodName: "Unmute",
+			Handler:    _Subscriptions_Unmute_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "friend_connection.proto",
+}
+
+// BlocksClient is the client API for Blocks service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BlocksClient interface {
+	Block(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Success, error)
+}
+
+type blocksClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBlocksClient(cc grpc.ClientConnInterface) BlocksClient {
+	return &blocksClient{cc}
+}
+
+func (c *blocksClient) Block(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Success, error) {
+	out := new(Success)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Blocks/Block", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlocksServer is the server API for Blocks service.
+// All implementations must embed UnimplementedBlocksServer
+// for forward compatibility
+type BlocksServer interface {
+	Block(context.Context, *UpdateRequest) (*Success, error)
+	mustEmbedUnimplementedBlocksServer()
+}
+
+// UnimplementedBlocksServer must be embedded to have forward compatible implementations.
+type UnimplementedBlocksServer struct {
+}
+
+func (UnimplementedBlocksServer) Block(context.Context, *UpdateRequest) (*Success, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Block not implemented")
+}
+func (UnimplementedBlocksServer) mustEmbedUnimplementedBlocksServer() {}
+
+// UnsafeBlocksServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BlocksServer will
+// result in compilation errors.
+type UnsafeBlocksServer interface {
+	mustEmbedUnimplementedBlocksServer()
+}
+
+func RegisterBlocksServer(s grpc.ServiceRegistrar, srv BlocksServer) {
+	s.RegisterService(&Blocks_ServiceDesc, srv)
+}
+
+func _Blocks_Block_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlocksServer).Block(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Blocks/Block",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlocksServer).Block(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Blocks_ServiceDesc is the grpc.ServiceDesc for Blocks service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Blocks_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "friendconnection.v1.Blocks",
+	HandlerType: (*BlocksServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Block",
+			Handler:    _Blocks_Block_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "friend_connection.proto",
+}
+
+// UpdatesClient is the client API for Updates service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UpdatesClient interface {
+	ListRecipients(ctx context.Context, in *ReceiveUpdateRequest, opts ...grpc.CallOption) (*RecipientsResponse, error)
+	Deliver(ctx context.Context, in *ReceiveUpdateRequest, opts ...grpc.CallOption) (Updates_DeliverClient, error)
+}
+
+type updatesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUpdatesClient(cc grpc.ClientConnInterface) UpdatesClient {
+	return &updatesClient{cc}
+}
+
+func (c *updatesClient) ListRecipients(ctx context.Context, in *ReceiveUpdateRequest, opts ...grpc.CallOption) (*RecipientsResponse, error) {
+	out := new(RecipientsResponse)
+	err := c.cc.Invoke(ctx, "/friendconnection.v1.Updates/ListRecipients", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *updatesClient) Deliver(ctx context.Context, in *ReceiveUpdateRequest, opts ...grpc.CallOption) (Updates_DeliverClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Updates_ServiceDesc.Streams[0], "/friendconnection.v1.Updates/Deliver", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &updatesDeliverClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Updates_DeliverClient interface {
+	Recv() (*Delivery, error)
+	grpc.ClientStream
+}
+
+type updatesDeliverClient struct {
+	grpc.ClientStream
+}
+
+func (x *updatesDeliverClient) Recv() (*Delivery, error) {
+	m := new(Delivery)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdatesServer is the server API for Updates service.
+// All implementations must embed UnimplementedUpdatesServer
+// for forward compatibility
+type UpdatesServer interface {
+	ListRecipients(context.Context, *ReceiveUpdateRequest) (*RecipientsResponse, error)
+	Deliver(*ReceiveUpdateRequest, Updates_DeliverServer) error
+	mustEmbedUnimplementedUpdatesServer()
+}
+
+// UnimplementedUpdatesServer must be embedded to have forward compatible implementations.
+type UnimplementedUpdatesServer struct {
+}
+
+func (UnimplementedUpdatesServer) ListRecipients(context.Context, *ReceiveUpdateRequest) (*RecipientsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRecipients not implemented")
+}
+func (UnimplementedUpdatesServer) Deliver(*ReceiveUpdateRequest, Updates_DeliverServer) error {
+	return status.Errorf(codes.Unimplemented, "method Deliver not implemented")
+}
+func (UnimplementedUpdatesServer) mustEmbedUnimplementedUpdatesServer() {}
+
+// UnsafeUpdatesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UpdatesServer will
+// result in compilation errors.
+type UnsafeUpdatesServer interface {
+	mustEmbedUnimplementedUpdatesServer()
+}
+
+func RegisterUpdatesServer(s grpc.ServiceRegistrar, srv UpdatesServer) {
+	s.RegisterService(&Updates_ServiceDesc, srv)
+}
+
+func _Updates_ListRecipients_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReceiveUpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpdatesServer).ListRecipients(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/friendconnection.v1.Updates/ListRecipients",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpdatesServer).ListRecipients(ctx, req.(*ReceiveUpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Updates_Deliver_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReceiveUpdateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UpdatesServer).Deliver(m, &updatesDeliverServer{stream})
+}
+
+type Updates_DeliverServer interface {
+	Send(*Delivery) error
+	grpc.ServerStream
+}
+
+type updatesDeliverServer struct {
+	grpc.ServerStream
+}
+
+func (x *updatesDeliverServer) Send(m *Delivery) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Updates_ServiceDesc is the grpc.ServiceDesc for Updates service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Updates_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "friendconnection.v1.Updates",
+	HandlerType: (*UpdatesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRecipients",
+			Handler:    _Updates_ListRecipients_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Deliver",
+			Handler:       _Updates_Deliver_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "friend_connection.proto",
+}
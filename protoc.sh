@@ -0,0 +1,7 @@
+#!/bin/bash
+
+# Requires protoc, protoc-gen-go v1.27.1 and protoc-gen-go-grpc v1.1.0
+protoc --proto_path=proto \
+	--go_out=proto/friendpb --go_opt=paths=source_relative \
+	--go-grpc_out=proto/friendpb --go-grpc_opt=paths=source_relative \
+	proto/friend_connection.proto
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"friend_connection_rest_api/services/metrics"
+	"friend_connection_rest_api/utils/logger"
+)
+
+// Cache store encoded values by key, an external store can implement it to share the cache between instances.
+// A store failing to read or write behaves as a miss, the value is then loaded from the database
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// GetJSON decode the value of key into dest and return false on a miss, requests are counted by name.
+// A nil cache always misses
+func GetJSON(ctx context.Context, c Cache, name string, key string, dest interface{}) bool {
+	if c == nil {
+		return false
+	}
+
+	data, ok := c.Get(ctx, key)
+	if ok {
+		if err := json.Unmarshal(data, dest); err != nil {
+			logger.FromContext(ctx).Warn("decode cached value failed", "key", key, "error", err)
+			ok = false
+		}
+	}
+
+	if ok {
+		metrics.CacheRequests.WithLabelValues(name, "hit").Inc()
+	} else {
+		metrics.CacheRequests.WithLabelValues(name, "miss").Inc()
+	}
+	return ok
+}
+
+// SetJSON encode value and store it in key for ttl
+func SetJSON(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		logger.FromContext(ctx).Warn("encode cached value failed", "key", key, "error", err)
+		return
+	}
+	c.Set(ctx, key, data, ttl)
+}
+
+// Invalidate remove keys from the cache
+func Invalidate(ctx context.Context, c Cache, keys ...string) {
+	if c == nil || len(keys) == 0 {
+		return
+	}
+	c.Delete(ctx, keys...)
+}
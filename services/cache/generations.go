@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// generationStripes is the number of generations the keys are spread over
+const generationStripes = 1024
+
+// Generations order the loads of the keys with their invalidations, a value loaded before an invalidation of its
+// key is not stored so a stale value can not outlive the write. Keys are hashed into stripes sharing a generation,
+// an invalidation may then also skip the store of another key of its stripe
+type Generations struct {
+	mutex   sync.Mutex
+	stripes [generationStripes]uint64
+}
+
+// NewGenerations initializes the generations of the keys
+func NewGenerations() *Generations {
+	return &Generations{}
+}
+
+func stripe(key string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % generationStripes)
+}
+
+// Current return the generation of key, it is read before the value is loaded
+func (g *Generations) Current(key string) uint64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.stripes[stripe(key)]
+}
+
+// SetJSON store value in key when key was not invalidated since generation was read
+func (g *Generations) SetJSON(ctx context.Context, c Cache, key string, generation uint64, value interface{}, ttl time.Duration) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.stripes[stripe(key)] != generation {
+		return false
+	}
+	SetJSON(ctx, c, key, value, ttl)
+	return true
+}
+
+// Invalidate start a new generation of keys then remove them from the cache
+func (g *Generations) Invalidate(ctx context.Context, c Cache, keys ...string) {
+	g.mutex.Lock()
+	for _, key := range keys {
+		g.stripes[stripe(key)]++
+	}
+	g.mutex.Unlock()
+
+	Invalidate(ctx, c, keys...)
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerations(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(10)
+	generations := NewGenerations()
+
+	// Value loaded before the invalidation of its key is not stored
+	generation := generations.Current("friends:a")
+	generations.Invalidate(ctx, lru, "friends:a")
+	assert.False(t, generations.SetJSON(ctx, lru, "friends:a", generation, []string{"b"}, time.Minute))
+	_, ok := lru.Get(ctx, "friends:a")
+	assert.False(t, ok)
+
+	// Value loaded after it is stored
+	generation = generations.Current("friends:a")
+	assert.True(t, generations.SetJSON(ctx, lru, "friends:a", generation, []string{"b"}, time.Minute))
+	value, ok := lru.Get(ctx, "friends:a")
+	assert.True(t, ok)
+	assert.Equal(t, `["b"]`, string(value))
+
+	// Invalidation removes the stored value
+	generations.Invalidate(ctx, lru, "friends:a")
+	_, ok = lru.Get(ctx, "friends:a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"friend_connection_rest_api/services/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	lru := NewLRU(2)
+	lru.now = func() time.Time { return now }
+
+	lru.Set(ctx, "a", []byte("1"), time.Minute)
+	lru.Set(ctx, "b", []byte("2"), time.Minute)
+
+	value, ok := lru.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(value))
+
+	// b is the least recently used entry
+	evictions := testutil.ToFloat64(metrics.CacheEvictions)
+	lru.Set(ctx, "c", []byte("3"), time.Minute)
+	_, ok = lru.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, evictions+1, testutil.ToFloat64(metrics.CacheEvictions))
+	assert.Equal(t, 2, lru.Len())
+
+	// Update keep a single entry
+	lru.Set(ctx, "a", []byte("4"), time.Minute)
+	value, _ = lru.Get(ctx, "a")
+	assert.Equal(t, "4", string(value))
+	assert.Equal(t, 2, lru.Len())
+
+	lru.Delete(ctx, "a", "missing")
+	_, ok = lru.Get(ctx, "a")
+	assert.False(t, ok)
+
+	// Expired entry is a miss
+	now = now.Add(time.Minute)
+	_, ok = lru.Get(ctx, "c")
+	assert.False(t, ok)
+	assert.Equal(t, 0, lru.Len())
+}
+
+func TestJSON(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(10)
+	hits := metrics.CacheRequests.WithLabelValues("friends", "hit")
+	misses := metrics.CacheRequests.WithLabelValues("friends", "miss")
+	beforeHits, beforeMisses := testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	list := []string{}
+	assert.False(t, GetJSON(ctx, lru, "friends", "friends:abc@gmail.com", &list))
+
+	SetJSON(ctx, lru, "friends:abc@gmail.com", []string{"xyz@gmail.com"}, time.Minute)
+	assert.True(t, GetJSON(ctx, lru, "friends", "friends:abc@gmail.com", &list))
+	assert.Equal(t, []string{"xyz@gmail.com"}, list)
+
+	Invalidate(ctx, lru, "friends:abc@gmail.com")
+	assert.False(t, GetJSON(ctx, lru, "friends", "friends:abc@gmail.com", &list))
+
+	assert.Equal(t, beforeHits+1, testutil.ToFloat64(hits))
+	assert.Equal(t, beforeMisses+2, testutil.ToFloat64(misses))
+
+	// Nil cache is disabled
+	assert.False(t, GetJSON(ctx, nil, "friends", "friends:abc@gmail.com", &list))
+	SetJSON(ctx, nil, "friends:abc@gmail.com", list, time.Minute)
+	Invalidate(ctx, nil, "friends:abc@gmail.com")
+}
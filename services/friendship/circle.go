@@ -0,0 +1,30 @@
+package friendship
+
+import (
+	"friend_connection_rest_api/services/user"
+
+	"gorm.io/gorm"
+)
+
+// Circle is a named group of friends owned by an user
+type Circle struct {
+	gorm.Model
+	Owner   string         `json:"owner" gorm:"column:owner; uniqueIndex:idx_circle"`
+	Name    string         `json:"name" gorm:"column:name; uniqueIndex:idx_circle"`
+	User    user.Users     `json:"-" gorm:"foreignKey:Owner;references:Email"`
+	Members []CircleMember `json:"-" gorm:"foreignKey:CircleID"`
+}
+
+// CircleMember is a friend of the owner in a circle
+type CircleMember struct {
+	gorm.Model
+	CircleID uint       `json:"circle_id" gorm:"column:circle_id; uniqueIndex:idx_circle_member"`
+	Email    string     `json:"email" gorm:"column:email; uniqueIndex:idx_circle_member"`
+	User     user.Users `json:"-" gorm:"foreignKey:Email;references:Email"`
+}
+
+// CircleInfo is a circle with the list of members
+type CircleInfo struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
@@ -0,0 +1,41 @@
+package friendship
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type CircleMockService struct {
+	mock.Mock
+}
+
+func (_m *CircleMockService) CreateCircle(ctx context.Context, owner string, name string) error {
+	args := _m.Called(ctx, owner, name)
+	return args.Error(0)
+}
+
+func (_m *CircleMockService) GetCircles(ctx context.Context, owner string) ([]CircleInfo, error) {
+	args := _m.Called(ctx, owner)
+	return args.Get(0).([]CircleInfo), args.Error(1)
+}
+
+func (_m *CircleMockService) RenameCircle(ctx context.Context, owner string, name string, newName string) error {
+	args := _m.Called(ctx, owner, name, newName)
+	return args.Error(0)
+}
+
+func (_m *CircleMockService) DeleteCircle(ctx context.Context, owner string, name string) error {
+	args := _m.Called(ctx, owner, name)
+	return args.Error(0)
+}
+
+func (_m *CircleMockService) AddCircleMembers(ctx context.Context, owner string, name string, members []string) error {
+	args := _m.Called(ctx, owner, name, members)
+	return args.Error(0)
+}
+
+func (_m *CircleMockService) RemoveCircleMember(ctx context.Context, owner string, name string, member string) error {
+	args := _m.Called(ctx, owner, name, member)
+	return args.Error(0)
+}
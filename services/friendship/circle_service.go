@@ -0,0 +1,244 @@
+package friendship
+
+import (
+	"context"
+	"errors"
+
+	"friend_connection_rest_api/services/unitofwork"
+
+	"gorm.io/gorm"
+)
+
+type CircleServices interface {
+	CreateCircle(ctx context.Context, owner string, name string) error
+	GetCircles(ctx context.Context, owner string) ([]CircleInfo, error)
+	RenameCircle(ctx context.Context, owner string, name string, newName string) error
+	DeleteCircle(ctx context.Context, owner string, name string) error
+	AddCircleMembers(ctx context.Context, owner string, name string, members []string) error
+	RemoveCircleMember(ctx context.Context, owner string, name string, member string) error
+}
+
+// CircleManager is the implementation of circle service
+type CircleManager struct {
+	dbconn *gorm.DB
+}
+
+// NewCircleManager initializes circle service
+func NewCircleManager(dbconn *gorm.DB) *CircleManager {
+	return &CircleManager{
+		dbconn: dbconn,
+	}
+}
+
+// withContext return a manager whose queries are bound to ctx
+func (m *CircleManager) withContext(ctx context.Context) *CircleManager {
+	return NewCircleManager(m.dbconn.WithContext(ctx))
+}
+
+// transaction run fn in a unit of work composed into the transaction of the manager if any
+func (m *CircleManager) transaction(fn func(txManager *CircleManager) error) error {
+	return unitofwork.NewGormUnitOfWork(m.dbconn).Do(func(tx *gorm.DB) error {
+		return fn(NewCircleManager(tx))
+	})
+}
+
+func (m *CircleManager) CreateCircle(ctx context.Context, owner string, name string) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *CircleManager) error {
+		IsExist, err := NewFriendshipManager(txManager.dbconn).checkUserExist([]string{owner})
+
+		if err != nil {
+			return err
+		}
+
+		if IsExist == false {
+			return errors.New("User Not Exist")
+		}
+
+		circle, err := txManager.findCircle(owner, name)
+		if err != nil {
+			return err
+		}
+
+		if circle != nil {
+			return errors.New("Circle was exist")
+		}
+
+		rs := txManager.dbconn.Create(&Circle{Owner: owner, Name: name})
+		return rs.Error
+	})
+}
+
+func (m *CircleManager) GetCircles(ctx context.Context, owner string) ([]CircleInfo, error) {
+	m = m.withContext(ctx)
+
+	IsExist, err := NewFriendshipManager(m.dbconn).checkUserExist([]string{owner})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if IsExist == false {
+		return nil, errors.New("User Not Exist")
+	}
+
+	circles := []Circle{}
+	rs := m.dbconn.Preload("Members").Where("owner = ?", owner).Order("name").Find(&circles)
+
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+
+	listCircles := []CircleInfo{}
+	for _, circle := range circles {
+		info := CircleInfo{Name: circle.Name, Members: []string{}}
+		for _, member := range circle.Members {
+			info.Members = append(info.Members, member.Email)
+		}
+		listCircles = append(listCircles, info)
+	}
+	return listCircles, nil
+}
+
+func (m *CircleManager) RenameCircle(ctx context.Context, owner string, name string, newName string) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *CircleManager) error {
+		circle, err := txManager.requireCircle(owner, name)
+		if err != nil {
+			return err
+		}
+
+		other, err := txManager.findCircle(owner, newName)
+		if err != nil {
+			return err
+		}
+
+		if other != nil {
+			return errors.New("Circle was exist")
+		}
+
+		rs := txManager.dbconn.Model(circle).Update("name", newName)
+		return rs.Error
+	})
+}
+
+func (m *CircleManager) DeleteCircle(ctx context.Context, owner string, name string) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *CircleManager) error {
+		circle, err := txManager.requireCircle(owner, name)
+		if err != nil {
+			return err
+		}
+
+		rs := txManager.dbconn.Unscoped().Where("circle_id = ?", circle.ID).Delete(&CircleMember{})
+		if rs.Error != nil {
+			return rs.Error
+		}
+		rs = txManager.dbconn.Unscoped().Delete(circle)
+		return rs.Error
+	})
+}
+
+// AddCircleMembers add friends of the owner to a circle, users are not friend are refused
+func (m *CircleManager) AddCircleMembers(ctx context.Context, owner string, name string, members []string) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *CircleManager) error {
+		circle, err := txManager.requireCircle(owner, name)
+		if err != nil {
+			return err
+		}
+
+		friendshipManager := NewFriendshipManager(txManager.dbconn)
+		for _, member := range members {
+			friendship, err := friendshipManager.checkFriendship(owner, member)
+			if err != nil {
+				return err
+			}
+
+			if friendship == nil || friendship.IsFriend == false {
+				return errors.New("Member Is Not Friend")
+			}
+
+			circleMember := CircleMember{CircleID: circle.ID, Email: member}
+			rs := txManager.dbconn.Where(circleMember).FirstOrCreate(&circleMember)
+			if rs.Error != nil {
+				return rs.Error
+			}
+		}
+		return nil
+	})
+}
+
+func (m *CircleManager) RemoveCircleMember(ctx context.Context, owner string, name string, member string) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *CircleManager) error {
+		circle, err := txManager.requireCircle(owner, name)
+		if err != nil {
+			return err
+		}
+
+		rs := txManager.dbconn.Unscoped().Where("circle_id = ? AND email = ?", circle.ID, member).Delete(&CircleMember{})
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		if rs.RowsAffected <= 0 {
+			return errors.New("Member Not In Circle")
+		}
+		return nil
+	})
+}
+
+// getCircleMembers return members of a circle of owner
+func (m *CircleManager) getCircleMembers(owner string, name string) ([]string, error) {
+	circle, err := m.requireCircle(owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	listMembers := []string{}
+	rs := m.dbconn.Model(&CircleMember{}).Where("circle_id = ?", circle.ID).Pluck("email", &listMembers)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+	return listMembers, nil
+}
+
+// removeFromCircles remove each user from the circles of the other, used when they are not friend anymore
+func (m *CircleManager) removeFromCircles(firstUser string, secondUser string) error {
+	stm := `DELETE FROM circle_members WHERE
+		(email = @fuser AND circle_id IN (SELECT id FROM circles WHERE owner = @suser))
+		OR (email = @suser AND circle_id IN (SELECT id FROM circles WHERE owner = @fuser))`
+
+	rs := m.dbconn.Exec(stm, map[string]interface{}{"fuser": firstUser, "suser": secondUser})
+	return rs.Error
+}
+
+func (m *CircleManager) requireCircle(owner string, name string) (*Circle, error) {
+	circle, err := m.findCircle(owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if circle == nil {
+		return nil, errors.New("Circle Not Exist")
+	}
+	return circle, nil
+}
+
+func (m *CircleManager) findCircle(owner string, name string) (*Circle, error) {
+	circle := Circle{}
+	rs := m.dbconn.Where("owner = ? AND name = ?", owner, name).Limit(1).Find(&circle)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+	if rs.RowsAffected <= 0 {
+		return nil, nil
+	}
+	return &circle, nil
+}
@@ -0,0 +1,88 @@
+package friendship
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"friend_connection_rest_api/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircle(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 3
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	owner := users[0]
+	friendshipManager := NewFriendshipManager(tx)
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: users[1]}))
+
+	circleManager := NewCircleManager(tx)
+
+	assert.NoError(t, circleManager.CreateCircle(ctx, owner, "family"))
+	assert.Equal(t, errors.New("Circle was exist"), circleManager.CreateCircle(ctx, owner, "family"))
+	assert.Equal(t, errors.New("User Not Exist"), circleManager.CreateCircle(ctx, "usernotexist@notfound.com", "family"))
+
+	assert.Equal(t, errors.New("Member Is Not Friend"), circleManager.AddCircleMembers(ctx, owner, "family", []string{users[2]}))
+	assert.Equal(t, errors.New("Circle Not Exist"), circleManager.AddCircleMembers(ctx, owner, "work", []string{users[1]}))
+	assert.NoError(t, circleManager.AddCircleMembers(ctx, owner, "family", []string{users[1]}))
+
+	assert.NoError(t, circleManager.RenameCircle(ctx, owner, "family", "home"))
+
+	circles, err := circleManager.GetCircles(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, []CircleInfo{{Name: "home", Members: []string{users[1]}}}, circles)
+
+	// Member is removed from circle when blocked, blocking end the friendship
+	assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: owner}))
+	circles, err = circleManager.GetCircles(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, []CircleInfo{{Name: "home", Members: []string{}}}, circles)
+	assert.Equal(t, errors.New("Member Not In Circle"), circleManager.RemoveCircleMember(ctx, owner, "home", users[1]))
+
+	assert.NoError(t, circleManager.DeleteCircle(ctx, owner, "home"))
+	circles, err = circleManager.GetCircles(ctx, owner)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(circles))
+}
+
+func TestGetUsersReceiveUpdateInCircle(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 4
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	sender := users[0]
+	friendshipManager := NewFriendshipManager(tx)
+	for i := 1; i < numUsers; i++ {
+		assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[i], TargetEmail: sender}))
+	}
+
+	circleManager := NewCircleManager(tx)
+	assert.NoError(t, circleManager.CreateCircle(ctx, sender, "family"))
+	assert.NoError(t, circleManager.AddCircleMembers(ctx, sender, "family", []string{users[1], users[2]}))
+
+	actualRs, err := friendshipManager.GetUsersReceiveUpdate(ctx, sender, []string{}, "family")
+	assert.Nil(t, err)
+	assert.Nil(t, difference([]string{users[1], users[2]}, actualRs))
+
+	// Member is removed from circle when unfriend
+	assert.NoError(t, friendshipManager.Unfriend(ctx, FrienshipServiceInput{RequestEmail: sender, TargetEmail: users[2]}))
+	actualRs, err = friendshipManager.GetUsersReceiveUpdate(ctx, sender, []string{}, "family")
+	assert.Nil(t, err)
+	assert.Nil(t, difference([]string{users[1]}, actualRs))
+
+	_, err = friendshipManager.GetUsersReceiveUpdate(ctx, sender, []string{}, "work")
+	assert.Equal(t, errors.New("Circle Not Exist"), err)
+}
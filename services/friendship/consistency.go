@@ -0,0 +1,46 @@
+package friendship
+
+const (
+	AnomalySelfEdge       = "self_edge"
+	AnomalyDanglingUser   = "dangling_user"
+	AnomalyDuplicatePair  = "duplicate_pair"
+	AnomalyReversedPair   = "reversed_pair"
+	AnomalyFriendBlocked  = "friend_blocked"
+	AnomalyBlockerFollows = "blocker_follows"
+	AnomalyEmptyRow       = "empty_row"
+)
+
+// anomalyKinds is the order anomalies are detected, repaired and reported
+var anomalyKinds = []string{
+	AnomalySelfEdge,
+	AnomalyDanglingUser,
+	AnomalyDuplicatePair,
+	AnomalyReversedPair,
+	AnomalyFriendBlocked,
+	AnomalyBlockerFollows,
+	AnomalyEmptyRow,
+}
+
+// maxAnomalySamples is the maximum number of rows reported as samples of an anomaly
+const maxAnomalySamples = 5
+
+// FriendshipSample identify a friendship row reported by the consistency checker
+type FriendshipSample struct {
+	ID         uint   `json:"id"`
+	FirstUser  string `json:"first_user"`
+	SecondUser string `json:"second_user"`
+}
+
+// Anomaly is one class of inconsistent friendships
+type Anomaly struct {
+	Kind    string             `json:"kind"`
+	Count   int                `json:"count"`
+	Samples []FriendshipSample `json:"samples"`
+}
+
+// ConsistencyReport is the result of scanning friendships, Applied is true when the anomalies were repaired
+type ConsistencyReport struct {
+	Scanned   int       `json:"scanned"`
+	Applied   bool      `json:"applied"`
+	Anomalies []Anomaly `json:"anomalies"`
+}
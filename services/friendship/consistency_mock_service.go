@@ -0,0 +1,16 @@
+package friendship
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ConsistencyMockService struct {
+	mock.Mock
+}
+
+func (_m *ConsistencyMockService) CheckConsistency(ctx context.Context, apply bool) (ConsistencyReport, error) {
+	args := _m.Called(ctx, apply)
+	return args.Get(0).(ConsistencyReport), args.Error(1)
+}
@@ -0,0 +1,250 @@
+package friendship
+
+import (
+	"context"
+
+	"friend_connection_rest_api/services/unitofwork"
+
+	"gorm.io/gorm"
+)
+
+type ConsistencyServices interface {
+	CheckConsistency(ctx context.Context, apply bool) (ConsistencyReport, error)
+}
+
+// ConsistencyManager is the implementation of consistency service
+type ConsistencyManager struct {
+	dbconn      *gorm.DB
+	friendships *FriendshipManager
+}
+
+// NewConsistencyManager initializes consistency service
+func NewConsistencyManager(dbconn *gorm.DB) *ConsistencyManager {
+	return &ConsistencyManager{
+		dbconn: dbconn,
+	}
+}
+
+// WithFriendshipManager invalidate the cache and update the graph index of friendships for each repaired pair
+func (m *ConsistencyManager) WithFriendshipManager(friendships *FriendshipManager) *ConsistencyManager {
+	m.friendships = friendships
+	return m
+}
+
+// repairPlan is the list of writes fixing the anomalies found by a scan
+type repairPlan struct {
+	deletes []uint
+	updates []*Friendship
+	// removed are the pairs left without row, removedUsers are the users of dangling rows
+	removed      []Friendship
+	removedUsers []string
+	// pairs are no longer friends, they are removed from circles of each other
+	unfriended [][2]string
+}
+
+// withContext return a manager whose queries are bound to ctx
+func (m *ConsistencyManager) withContext(ctx context.Context) *ConsistencyManager {
+	return &ConsistencyManager{dbconn: m.dbconn.WithContext(ctx), friendships: m.friendships}
+}
+
+// CheckConsistency scan friendships and report anomalies, when apply is set they are repaired in one transaction.
+// Repair is deterministic: rows of a pair are merged into the oldest one in canonical order,
+// a block wins over friendship and blocker stop following the blocked user
+func (m *ConsistencyManager) CheckConsistency(ctx context.Context, apply bool) (ConsistencyReport, error) {
+	m = m.withContext(ctx)
+
+	report := ConsistencyReport{}
+
+	friendships := []Friendship{}
+	rs := m.dbconn.Order("id").Find(&friendships)
+	if rs.Error != nil {
+		return report, rs.Error
+	}
+	report.Scanned = len(friendships)
+
+	registered := []string{}
+	rs = m.dbconn.Table("users").Where("deleted_at IS NULL").Pluck("email", &registered)
+	if rs.Error != nil {
+		return report, rs.Error
+	}
+
+	anomalies, plan := m.scan(friendships, registered)
+	for _, kind := range anomalyKinds {
+		if anomaly, ok := anomalies[kind]; ok {
+			report.Anomalies = append(report.Anomalies, *anomaly)
+		}
+	}
+
+	if apply == false || len(report.Anomalies) == 0 {
+		return report, nil
+	}
+
+	err := unitofwork.NewGormUnitOfWork(m.dbconn).Do(func(tx *gorm.DB) error {
+		return m.repair(tx, plan)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Applied = true
+	return report, nil
+}
+
+// scan group rows by pair, rows are ordered by id so the oldest row of a pair is kept
+func (m *ConsistencyManager) scan(friendships []Friendship, registered []string) (map[string]*Anomaly, repairPlan) {
+	anomalies := map[string]*Anomaly{}
+	plan := repairPlan{}
+
+	report := func(kind string, friendship *Friendship) {
+		anomaly, ok := anomalies[kind]
+		if !ok {
+			anomaly = &Anomaly{Kind: kind, Samples: []FriendshipSample{}}
+			anomalies[kind] = anomaly
+		}
+		anomaly.Count++
+		if len(anomaly.Samples) < maxAnomalySamples {
+			anomaly.Samples = append(anomaly.Samples, FriendshipSample{ID: friendship.ID, FirstUser: friendship.FirstUser, SecondUser: friendship.SecondUser})
+		}
+	}
+
+	users := map[string]bool{}
+	for _, email := range registered {
+		users[email] = true
+	}
+	removedUsers := map[string]bool{}
+
+	pairs := [][2]string{}
+	rowsByPair := map[[2]string][]*Friendship{}
+	for i := range friendships {
+		friendship := &friendships[i]
+
+		if friendship.FirstUser == friendship.SecondUser {
+			report(AnomalySelfEdge, friendship)
+			plan.deletes = append(plan.deletes, friendship.ID)
+			plan.removed = append(plan.removed, *friendship)
+			continue
+		}
+
+		if !users[friendship.FirstUser] || !users[friendship.SecondUser] {
+			report(AnomalyDanglingUser, friendship)
+			plan.deletes = append(plan.deletes, friendship.ID)
+			for _, email := range []string{friendship.FirstUser, friendship.SecondUser} {
+				if !users[email] && !removedUsers[email] {
+					removedUsers[email] = true
+					plan.removedUsers = append(plan.removedUsers, email)
+				}
+			}
+			continue
+		}
+
+		first, second := canonicalPair(friendship.FirstUser, friendship.SecondUser)
+		pair := [2]string{first, second}
+		if _, ok := rowsByPair[pair]; !ok {
+			pairs = append(pairs, pair)
+		}
+		rowsByPair[pair] = append(rowsByPair[pair], friendship)
+	}
+
+	for _, pair := range pairs {
+		rows := rowsByPair[pair]
+		kept := rows[0]
+		merged := Friendship{FirstUser: pair[0], SecondUser: pair[1]}
+		merged.ID = kept.ID
+		changed := false
+
+		for i, row := range rows {
+			merged.IsFriend = merged.IsFriend || row.IsFriend
+			for _, email := range pair {
+				merged.setFollows(email, merged.follows(email) || row.follows(email))
+				merged.setBlocks(email, merged.blocks(email) || row.blocks(email))
+			}
+
+			if i > 0 {
+				report(AnomalyDuplicatePair, row)
+				plan.deletes = append(plan.deletes, row.ID)
+				changed = true
+			}
+		}
+
+		if kept.FirstUser != pair[0] {
+			report(AnomalyReversedPair, kept)
+			changed = true
+		}
+
+		if merged.IsFriend && merged.blocked() {
+			report(AnomalyFriendBlocked, kept)
+			merged.IsFriend = false
+			plan.unfriended = append(plan.unfriended, pair)
+			changed = true
+		}
+
+		for _, email := range pair {
+			if merged.blocks(email) && merged.follows(email) {
+				report(AnomalyBlockerFollows, kept)
+				merged.setFollows(email, false)
+				changed = true
+			}
+		}
+
+		if !merged.IsFriend && !merged.FirstFollowsSecond && !merged.SecondFollowsFirst && !merged.blocked() {
+			report(AnomalyEmptyRow, kept)
+			plan.deletes = append(plan.deletes, kept.ID)
+			plan.removed = append(plan.removed, merged)
+			continue
+		}
+
+		if changed {
+			plan.updates = append(plan.updates, &merged)
+		}
+	}
+
+	return anomalies, plan
+}
+
+// repair delete rows first so the kept row of a pair can be moved to canonical order without conflict.
+// Cached lists of both users of each repaired pair and the graph index are updated once the repair is committed
+func (m *ConsistencyManager) repair(tx *gorm.DB, plan repairPlan) error {
+	if m.friendships != nil {
+		for _, email := range plan.removedUsers {
+			if err := m.friendships.InvalidateUser(tx, email); err != nil {
+				return err
+			}
+		}
+		for _, friendship := range plan.removed {
+			m.friendships.friendshipWritten(tx, friendship, true)
+		}
+		for _, friendship := range plan.updates {
+			m.friendships.friendshipWritten(tx, *friendship, false)
+		}
+	}
+
+	if len(plan.deletes) > 0 {
+		rs := tx.Unscoped().Where("id IN ?", plan.deletes).Delete(&Friendship{})
+		if rs.Error != nil {
+			return rs.Error
+		}
+	}
+
+	for _, friendship := range plan.updates {
+		rs := tx.Model(&Friendship{}).Where("id = ?", friendship.ID).Updates(map[string]interface{}{
+			"first_user":           friendship.FirstUser,
+			"second_user":          friendship.SecondUser,
+			"is_friend":            friendship.IsFriend,
+			"first_follows_second": friendship.FirstFollowsSecond,
+			"second_follows_first": friendship.SecondFollowsFirst,
+			"first_blocks_second":  friendship.FirstBlocksSecond,
+			"second_blocks_first":  friendship.SecondBlocksFirst,
+		})
+		if rs.Error != nil {
+			return rs.Error
+		}
+	}
+
+	circleManager := NewCircleManager(tx)
+	for _, pair := range plan.unfriended {
+		if err := circleManager.removeFromCircles(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
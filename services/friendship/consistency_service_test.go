@@ -0,0 +1,197 @@
+package friendship
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"friend_connection_rest_api/services/cache"
+	"friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFriendshipTest(id uint, firstUser string, secondUser string) Friendship {
+	friendship := Friendship{FirstUser: firstUser, SecondUser: secondUser}
+	friendship.ID = id
+	return friendship
+}
+
+func TestConsistencyScan(t *testing.T) {
+	registered := []string{"a@gmail.com", "b@gmail.com", "c@gmail.com", "d@gmail.com"}
+
+	selfEdge := newFriendshipTest(1, "a@gmail.com", "a@gmail.com")
+	dangling := newFriendshipTest(2, "a@gmail.com", "gone@gmail.com")
+
+	// Pair a-b is stored twice, the oldest row is reversed
+	reversed := newFriendshipTest(3, "b@gmail.com", "a@gmail.com")
+	reversed.FirstFollowsSecond = true
+	duplicate := newFriendshipTest(4, "a@gmail.com", "b@gmail.com")
+	duplicate.FirstFollowsSecond = true
+
+	friendBlocked := newFriendshipTest(5, "a@gmail.com", "c@gmail.com")
+	friendBlocked.IsFriend = true
+	friendBlocked.FirstFollowsSecond = true
+	friendBlocked.SecondFollowsFirst = true
+	friendBlocked.SecondBlocksFirst = true
+
+	empty := newFriendshipTest(6, "a@gmail.com", "d@gmail.com")
+
+	valid := newFriendshipTest(7, "b@gmail.com", "c@gmail.com")
+	valid.IsFriend = true
+	valid.FirstFollowsSecond = true
+	valid.SecondFollowsFirst = true
+
+	anomalies, plan := NewConsistencyManager(nil).scan([]Friendship{selfEdge, dangling, reversed, duplicate, friendBlocked, empty, valid}, registered)
+
+	counts := map[string]int{}
+	for kind, anomaly := range anomalies {
+		counts[kind] = anomaly.Count
+	}
+	assert.Equal(t, map[string]int{
+		AnomalySelfEdge:       1,
+		AnomalyDanglingUser:   1,
+		AnomalyDuplicatePair:  1,
+		AnomalyReversedPair:   1,
+		AnomalyFriendBlocked:  1,
+		AnomalyBlockerFollows: 1,
+		AnomalyEmptyRow:       1,
+	}, counts)
+	assert.Equal(t, []FriendshipSample{{ID: 4, FirstUser: "a@gmail.com", SecondUser: "b@gmail.com"}}, anomalies[AnomalyDuplicatePair].Samples)
+
+	assert.Equal(t, []uint{1, 2, 4, 6}, plan.deletes)
+	assert.Equal(t, [][2]string{{"a@gmail.com", "c@gmail.com"}}, plan.unfriended)
+
+	// Self edge and empty pair are left without row, users of dangling rows are removed
+	assert.Equal(t, 2, len(plan.removed))
+	assert.Equal(t, []string{"gone@gmail.com"}, plan.removedUsers)
+
+	// Both rows of a-b are merged into the oldest one in canonical order
+	assert.Equal(t, 2, len(plan.updates))
+	merged := plan.updates[0]
+	assert.Equal(t, uint(3), merged.ID)
+	assert.Equal(t, "a@gmail.com", merged.FirstUser)
+	assert.Equal(t, "b@gmail.com", merged.SecondUser)
+	assert.Equal(t, true, merged.FirstFollowsSecond)
+	assert.Equal(t, true, merged.SecondFollowsFirst)
+
+	// Block wins over friendship and blocker stop following
+	repaired := plan.updates[1]
+	assert.Equal(t, uint(5), repaired.ID)
+	assert.Equal(t, false, repaired.IsFriend)
+	assert.Equal(t, true, repaired.FirstFollowsSecond)
+	assert.Equal(t, false, repaired.SecondFollowsFirst)
+	assert.Equal(t, true, repaired.SecondBlocksFirst)
+}
+
+func TestCheckConsistency(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+	// Constraints are dropped to insert broken rows, the transaction must not stay open holding the table lock
+	defer tx.Rollback()
+
+	users, ok := insertUsersTest(tx, 2)
+	assert.Equal(t, true, ok)
+	first, second := canonicalPair(users[0], users[1])
+
+	assert.NoError(t, tx.Exec("ALTER TABLE friendships DROP CONSTRAINT IF EXISTS chk_friendship_pair").Error)
+	assert.NoError(t, tx.Exec("DROP INDEX IF EXISTS idx_friendship_pair").Error)
+
+	reversed := Friendship{FirstUser: second, SecondUser: first, FirstFollowsSecond: true}
+	assert.NoError(t, tx.Create(&reversed).Error)
+	assert.NoError(t, tx.Create(&Friendship{FirstUser: first, SecondUser: second, IsFriend: true, FirstFollowsSecond: true, SecondFollowsFirst: true}).Error)
+	assert.NoError(t, tx.Create(&Friendship{FirstUser: first, SecondUser: first, IsFriend: true}).Error)
+
+	consistencyManager := NewConsistencyManager(tx)
+
+	// Dry run report without writing
+	report, err := consistencyManager.CheckConsistency(ctx, false)
+	assert.Nil(t, err)
+	assert.Equal(t, false, report.Applied)
+	kinds := []string{}
+	for _, anomaly := range report.Anomalies {
+		kinds = append(kinds, anomaly.Kind)
+	}
+	assert.Subset(t, kinds, []string{AnomalySelfEdge, AnomalyDuplicatePair, AnomalyReversedPair})
+
+	var count int64
+	assert.NoError(t, tx.Model(&Friendship{}).Where("first_user IN ? OR second_user IN ?", users, users).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+
+	report, err = consistencyManager.CheckConsistency(ctx, true)
+	assert.Nil(t, err)
+	assert.Equal(t, true, report.Applied)
+
+	friendships := []Friendship{}
+	assert.NoError(t, tx.Where("first_user IN ? OR second_user IN ?", users, users).Find(&friendships).Error)
+	assert.Equal(t, 1, len(friendships))
+	assert.Equal(t, reversed.ID, friendships[0].ID)
+	assert.Equal(t, first, friendships[0].FirstUser)
+	assert.Equal(t, true, friendships[0].IsFriend)
+	assert.Equal(t, true, friendships[0].FirstFollowsSecond)
+	assert.Equal(t, true, friendships[0].SecondFollowsFirst)
+
+	// Nothing left to repair for these users
+	report, err = consistencyManager.CheckConsistency(ctx, false)
+	assert.Nil(t, err)
+	for _, anomaly := range report.Anomalies {
+		for _, sample := range anomaly.Samples {
+			assert.NotContains(t, users, sample.FirstUser)
+		}
+	}
+}
+
+func TestConsistencyRepairInvalidates(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+
+	// Cache and graph index are updated when the repair is committed, so the users are not created in a test transaction
+	users, ok := insertUsersTest(dbconn, 3)
+	assert.Equal(t, true, ok)
+	defer func() {
+		dbconn.Unscoped().Where("first_user IN ? OR second_user IN ?", users, users).Delete(&Friendship{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.VerificationToken{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.Users{})
+		dbconn.Where("email IN ?", users).Delete(&RelationshipVersion{})
+	}()
+
+	lru := cache.NewLRU(100)
+	index := NewGraphIndex()
+	friendshipManager := NewFriendshipManager(dbconn).WithCache(lru, time.Minute).WithGraphIndex(index, map[string]bool{})
+	cached := func(name string, email string) bool {
+		_, ok := lru.Get(ctx, cacheKey(name, email))
+		return ok
+	}
+
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}))
+	assert.NoError(t, index.Load(ctx, dbconn))
+	for _, email := range users {
+		_, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: email}, email)
+		assert.NoError(t, err)
+		assert.True(t, cached(cacheFriends, email))
+	}
+
+	// Friends blocking each other and a friendship of a removed user are written outside the manager
+	first, second := canonicalPair(users[0], users[1])
+	assert.NoError(t, dbconn.Model(&Friendship{}).Where("first_user = ? AND second_user = ?", first, second).
+		Update("first_blocks_second", true).Error)
+	assert.NoError(t, dbconn.Where("email = ?", users[2]).Delete(&user.Users{}).Error)
+
+	report, err := NewConsistencyManager(dbconn).WithFriendshipManager(friendshipManager).CheckConsistency(ctx, true)
+	assert.NoError(t, err)
+	assert.Equal(t, true, report.Applied)
+
+	// Lists of both users of each repaired pair and the removed user are no longer cached
+	for _, email := range users {
+		assert.False(t, cached(cacheFriends, email))
+	}
+	assert.False(t, cached(cacheUsers, users[2]))
+
+	// Graph index has the repaired pair and no longer the pair of the removed user
+	assert.Equal(t, []string{users[1]}, index.Blocked(users[0]))
+	assert.Equal(t, []string{}, index.Friends(users[0]))
+	assert.Equal(t, []string{}, index.Friends(users[2]))
+}
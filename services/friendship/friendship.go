@@ -1,6 +1,8 @@
 package friendship
 
 import (
+	"time"
+
 	"friend_connection_rest_api/services/user"
 
 	"gorm.io/gorm"
@@ -13,34 +15,201 @@ type FrienshipServiceInput struct {
 
 type Friendship struct {
 	gorm.Model
-	ID           uint       `json:"id" gorm:"column:id; primaryKey"`
-	FirstUser    string     `json:"first_user" gorm:"column:first_user"`
-	SecondUser   string     `json:"second_user" gorm:"column:second_user"`
-	IsFriend     bool       `json:"is_friend" gorm:"column:is_friend"`
-	UpdateStatus int        `json:"update_status" gorm:"column:update_status"`
-	User         user.Users `gorm:"foreignKey:FirstUser;references:Email"`
-	User1        user.Users `gorm:"foreignKey:SecondUser;references:Email"`
-}
-
-// UpdateStatus Mean
-// -Case 1:
-//  + equal 0 when FirstUser and SecondUser block update together
-// A <--------------x------------- B
-// A -------------x--------------> B
-
-// -Case 2:
-// 	+ equal 1 when FirstUser subscribe update to SecondUser
-//	+ and SecondUser block update to FirstUser
-// A <------------x--------------- B
-// A ----------------------------> B
-//
-// -Case 3: Default using for make friend connection
-// 	+ equal 2 when SecondUser subscribe update to FirstUser
-//	+ and FirstUser block update to SecondUser
-// A <---------------------------- B
-// A -------------x--------------> B
+	ID                 uint       `json:"id" gorm:"column:id; primaryKey"`
+	FirstUser          string     `json:"first_user" gorm:"column:first_user; uniqueIndex:idx_friendship_pair"`
+	SecondUser         string     `json:"second_user" gorm:"column:second_user; uniqueIndex:idx_friendship_pair; check:chk_friendship_pair,first_user < second_user"`
+	IsFriend           bool       `json:"is_friend" gorm:"column:is_friend"`
+	FirstFollowsSecond bool       `json:"first_follows_second" gorm:"column:first_follows_second; default:false"`
+	SecondFollowsFirst bool       `json:"second_follows_first" gorm:"column:second_follows_first; default:false"`
+	FirstBlocksSecond  bool       `json:"first_blocks_second" gorm:"column:first_blocks_second; default:false"`
+	SecondBlocksFirst  bool       `json:"second_blocks_first" gorm:"column:second_blocks_first; default:false"`
+	User               user.Users `gorm:"foreignKey:FirstUser;references:Email"`
+	User1              user.Users `gorm:"foreignKey:SecondUser;references:Email"`
+}
+
+// A pair has only one friendship, stored in canonical order with first_user < second_user.
+// Each direction of a friendship is stored explicitly, X follows Y means X receive updates of Y
+// and X blocks Y hides the pair from each other, no update is delivered in either direction of a blocked pair
 //
-// -Case 4:
-//  + equal 3 when both subscribe update togerther
-// A <---------------------------- B
-// A ----------------------------> B
+// A ---- first_follows_second ---> B     A ---- first_blocks_second ---x B
+// A <--- second_follows_first ---- B     A x--- second_blocks_first ---- B
+
+// canonicalPair order the emails of a pair the way it is stored
+func canonicalPair(a string, b string) (string, string) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+// follows return true when email follows the other user of the friendship
+func (f *Friendship) follows(email string) bool {
+	if email == f.FirstUser {
+		return f.FirstFollowsSecond
+	}
+	return f.SecondFollowsFirst
+}
+
+// blocks return true when email blocks the other user of the friendship
+func (f *Friendship) blocks(email string) bool {
+	if email == f.FirstUser {
+		return f.FirstBlocksSecond
+	}
+	return f.SecondBlocksFirst
+}
+
+// blocked return true when one of users blocks the other
+func (f *Friendship) blocked() bool {
+	return f.FirstBlocksSecond || f.SecondBlocksFirst
+}
+
+// friends return true when users are friends and none of them blocks the other, a follow is not a friendship
+func (f *Friendship) friends() bool {
+	return f.IsFriend && !f.blocked()
+}
+
+func (f *Friendship) setFollows(email string, value bool) {
+	if email == f.FirstUser {
+		f.FirstFollowsSecond = value
+	} else {
+		f.SecondFollowsFirst = value
+	}
+}
+
+func (f *Friendship) setBlocks(email string, value bool) {
+	if email == f.FirstUser {
+		f.FirstBlocksSecond = value
+	} else {
+		f.SecondBlocksFirst = value
+	}
+}
+
+// Relationship is the decoded view of the connection between User and Other seen from User
+type Relationship struct {
+	User                string     `json:"user"`
+	Other               string     `json:"other"`
+	AreFriends          bool       `json:"are_friends"`
+	UserFollowsOther    bool       `json:"user_follows_other"`
+	OtherFollowsUser    bool       `json:"other_follows_user"`
+	UserBlocksOther     bool       `json:"user_blocks_other"`
+	OtherBlocksUser     bool       `json:"other_blocks_user"`
+	PendingRequest      bool       `json:"pending_request"`
+	PendingRequestFrom  string     `json:"pending_request_from,omitempty"`
+	ConnectedSince      *time.Time `json:"connected_since,omitempty"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+	PendingRequestSince *time.Time `json:"pending_request_since,omitempty"`
+	// Err is the error of a relationship read in batch by GetRelationships
+	Err error `json:"-"`
+}
+
+// setFriendship decode the friendship of the pair, it is nil when the users have no connection
+func (r *Relationship) setFriendship(friendship *Friendship) {
+	if friendship == nil {
+		return
+	}
+	r.AreFriends = friendship.IsFriend
+	r.UserBlocksOther = friendship.blocks(r.User)
+	r.OtherBlocksUser = friendship.blocks(r.Other)
+	// Updates are not delivered in either direction of a blocked pair
+	r.UserFollowsOther = friendship.follows(r.User) && !friendship.blocked()
+	r.OtherFollowsUser = friendship.follows(r.Other) && !friendship.blocked()
+	r.ConnectedSince = &friendship.CreatedAt
+	r.UpdatedAt = &friendship.UpdatedAt
+}
+
+// setInvitation record the pending friend request between the users
+func (r *Relationship) setInvitation(invitation *Invitation) {
+	r.PendingRequest = true
+	r.PendingRequestFrom = invitation.Requestor
+	r.PendingRequestSince = &invitation.CreatedAt
+}
+
+// RelationshipVersion of an user is bumped by a trigger on every write to its friendships, invitations
+// and privacy setting, an user without version has never been written
+type RelationshipVersion struct {
+	Email     string    `json:"email" gorm:"column:email; primaryKey"`
+	Version   int64     `json:"version" gorm:"column:version"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+	// IndexVersion is the version of the user in the graph index, lists answered by the index
+	// may lag behind the table until the index changes too
+	IndexVersion uint64 `json:"-" gorm:"-"`
+}
+
+// FriendsList is the friend list or the mutual friends of an user read in a batch,
+// Err is set when it can not be read, e.g. ErrPrivacyRestricted
+type FriendsList struct {
+	Email   string
+	Friends []string
+	Err     error
+}
+
+const (
+	InvitationFriend    = "friend"
+	InvitationSubscribe = "subscribe"
+)
+
+// Invitation is recorded when the target of a friend request or subscription is not registered yet,
+// it will be converted into friendship when the target register
+type Invitation struct {
+	gorm.Model
+	Requestor string     `json:"requestor" gorm:"column:requestor; uniqueIndex:idx_invitation"`
+	Email     string     `json:"email" gorm:"column:email; uniqueIndex:idx_invitation; index"`
+	Kind      string     `json:"kind" gorm:"column:kind; uniqueIndex:idx_invitation"`
+	User      user.Users `gorm:"foreignKey:Requestor;references:Email"`
+}
+
+const (
+	ContactActionNone       = ""
+	ContactActionSubscribe  = "subscribe"
+	ContactActionMakeFriend = "make_friend"
+)
+
+const (
+	ContactConnected  = "connected"
+	ContactSubscribed = "subscribed"
+	ContactInvited    = "invited"
+	ContactSkipped    = "skipped"
+	// ContactRestricted is a contact whose privacy settings refuse the friend request of the owner
+	ContactRestricted = "restricted"
+)
+
+// ContactStatus is the result of importing a contact of an user
+type ContactStatus struct {
+	Email      string `json:"email"`
+	Registered bool   `json:"registered"`
+	Friend     bool   `json:"friend"`
+	Blocked    bool   `json:"blocked"`
+	Status     string `json:"status,omitempty"`
+}
+
+const (
+	OperationMakeFriend = "make_friend"
+	OperationSubscribe  = "subscribe"
+	OperationBlock      = "block"
+	OperationUnfriend   = "unfriend"
+)
+
+// BatchOperation is one graph operation of a batch request
+type BatchOperation struct {
+	Operation string
+	Input     FrienshipServiceInput
+}
+
+// BatchResult is the result of one operation of a batch request
+type BatchResult struct {
+	Operation string `json:"operation"`
+	Requestor string `json:"requestor"`
+	Target    string `json:"target"`
+	Success   bool   `json:"success"`
+	Invited   bool   `json:"invited,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Mute suppress updates from Target to Muter without affecting their friendship
+type Mute struct {
+	gorm.Model
+	Muter  string     `json:"muter" gorm:"column:muter; uniqueIndex:idx_mute"`
+	Target string     `json:"target" gorm:"column:target; uniqueIndex:idx_mute; index"`
+	User   user.Users `gorm:"foreignKey:Muter;references:Email"`
+	User1  user.Users `gorm:"foreignKey:Target;references:Email"`
+}
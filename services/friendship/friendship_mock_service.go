@@ -1,6 +1,8 @@
 package friendship
 
 import (
+	"context"
+
 	"friend_connection_rest_api/services/user"
 
 	"github.com/stretchr/testify/mock"
@@ -10,33 +12,83 @@ type FrienshipMockService struct {
 	mock.Mock
 }
 
-func (_m *FrienshipMockService) MakeFriend(input FrienshipServiceInput) error {
-	args := _m.Called(input)
+func (_m *FrienshipMockService) MakeFriend(ctx context.Context, input FrienshipServiceInput) error {
+	args := _m.Called(ctx, input)
 	output := args.Error(0)
 	return output
 }
 
-func (_m *FrienshipMockService) GetFriendsList(ur user.Users) ([]string, error) {
-	args := _m.Called(ur)
+func (_m *FrienshipMockService) GetFriendsList(ctx context.Context, ur user.Users, viewer string) ([]string, error) {
+	args := _m.Called(ctx, ur, viewer)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (_m *FrienshipMockService) GetMutualFriendsList(input FrienshipServiceInput) ([]string, error) {
-	args := _m.Called(input)
+func (_m *FrienshipMockService) GetMutualFriendsList(ctx context.Context, input FrienshipServiceInput, viewer string) ([]string, error) {
+	args := _m.Called(ctx, input, viewer)
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (_m *FrienshipMockService) Subscribe(input FrienshipServiceInput) error {
-	args := _m.Called(input)
+func (_m *FrienshipMockService) Subscribe(ctx context.Context, input FrienshipServiceInput) error {
+	args := _m.Called(ctx, input)
 	return args.Error(0)
 }
 
-func (_m *FrienshipMockService) Block(input FrienshipServiceInput) error {
-	args := _m.Called(input)
+func (_m *FrienshipMockService) Block(ctx context.Context, input FrienshipServiceInput) error {
+	args := _m.Called(ctx, input)
 	return args.Error(0)
 }
 
-func (_m *FrienshipMockService) GetUsersReceiveUpdate(sender string, mentionedUsers []string) ([]string, error) {
-	args := _m.Called(sender, mentionedUsers)
+func (_m *FrienshipMockService) GetUsersReceiveUpdate(ctx context.Context, sender string, mentionedUsers []string, circle string) ([]string, error) {
+	args := _m.Called(ctx, sender, mentionedUsers, circle)
 	return args.Get(0).([]string), args.Error(1)
 }
+
+func (_m *FrienshipMockService) ImportContacts(ctx context.Context, owner string, contacts []string, action string) ([]ContactStatus, error) {
+	args := _m.Called(ctx, owner, contacts, action)
+	return args.Get(0).([]ContactStatus), args.Error(1)
+}
+
+func (_m *FrienshipMockService) Unfriend(ctx context.Context, input FrienshipServiceInput) error {
+	args := _m.Called(ctx, input)
+	return args.Error(0)
+}
+
+func (_m *FrienshipMockService) ExecuteBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	args := _m.Called(ctx, operations, atomic)
+	return args.Get(0).([]BatchResult), args.Error(1)
+}
+
+func (_m *FrienshipMockService) Mute(ctx context.Context, input FrienshipServiceInput) error {
+	args := _m.Called(ctx, input)
+	return args.Error(0)
+}
+
+func (_m *FrienshipMockService) Unmute(ctx context.Context, input FrienshipServiceInput) error {
+	args := _m.Called(ctx, input)
+	return args.Error(0)
+}
+
+func (_m *FrienshipMockService) GetRelationship(ctx context.Context, input FrienshipServiceInput, viewer string) (Relationship, error) {
+	args := _m.Called(ctx, input, viewer)
+	return args.Get(0).(Relationship), args.Error(1)
+}
+
+func (_m *FrienshipMockService) GetRelationshipVersions(ctx context.Context, emails []string) ([]RelationshipVersion, error) {
+	args := _m.Called(ctx, emails)
+	return args.Get(0).([]RelationshipVersion), args.Error(1)
+}
+
+func (_m *FrienshipMockService) GetFriendsLists(ctx context.Context, emails []string, viewer string) ([]FriendsList, error) {
+	args := _m.Called(ctx, emails, viewer)
+	return args.Get(0).([]FriendsList), args.Error(1)
+}
+
+func (_m *FrienshipMockService) GetMutualFriendsLists(ctx context.Context, email string, others []string, viewer string) ([]FriendsList, error) {
+	args := _m.Called(ctx, email, others, viewer)
+	return args.Get(0).([]FriendsList), args.Error(1)
+}
+
+func (_m *FrienshipMockService) GetRelationships(ctx context.Context, email string, others []string, viewer string) ([]Relationship, error) {
+	args := _m.Called(ctx, email, others, viewer)
+	return args.Get(0).([]Relationship), args.Error(1)
+}
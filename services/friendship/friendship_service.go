@@ -1,25 +1,60 @@
 package friendship
 
 import (
+	"context"
 	"errors"
+	"sort"
+	"time"
 
+	"friend_connection_rest_api/services/cache"
+	"friend_connection_rest_api/services/metrics"
+	"friend_connection_rest_api/services/unitofwork"
 	"friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils/logger"
+	"friend_connection_rest_api/utils/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrInvitationPending is returned when the target is not registered and an invitation was recorded
+var ErrInvitationPending = errors.New("User Not Exist, Invitation Was Recorded")
+
+// ErrPrivacyRestricted is returned when privacy settings of an user do not allow the operation
+var ErrPrivacyRestricted = errors.New("Restricted By Privacy Settings")
+
+// ErrBatchRolledBack is returned when an operation of an atomic batch failed and all operations were rolled back
+var ErrBatchRolledBack = errors.New("Batch Was Rolled Back")
+
 type FrienshipServices interface {
-	MakeFriend(input FrienshipServiceInput) error
-	GetFriendsList(user user.Users) ([]string, error)
-	GetMutualFriendsList(input FrienshipServiceInput) ([]string, error)
-	Subscribe(input FrienshipServiceInput) error
-	Block(input FrienshipServiceInput) error
-	GetUsersReceiveUpdate(sender string, mentionedUsers []string) ([]string, error)
+	MakeFriend(ctx context.Context, input FrienshipServiceInput) error
+	GetFriendsList(ctx context.Context, user user.Users, viewer string) ([]string, error)
+	GetMutualFriendsList(ctx context.Context, input FrienshipServiceInput, viewer string) ([]string, error)
+	Subscribe(ctx context.Context, input FrienshipServiceInput) error
+	Block(ctx context.Context, input FrienshipServiceInput) error
+	GetUsersReceiveUpdate(ctx context.Context, sender string, mentionedUsers []string, circle string) ([]string, error)
+	ImportContacts(ctx context.Context, owner string, contacts []string, action string) ([]ContactStatus, error)
+	Unfriend(ctx context.Context, input FrienshipServiceInput) error
+	Mute(ctx context.Context, input FrienshipServiceInput) error
+	Unmute(ctx context.Context, input FrienshipServiceInput) error
+	ExecuteBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]BatchResult, error)
+	GetRelationship(ctx context.Context, input FrienshipServiceInput, viewer string) (Relationship, error)
+	GetRelationshipVersions(ctx context.Context, emails []string) ([]RelationshipVersion, error)
+	GetFriendsLists(ctx context.Context, emails []string, viewer string) ([]FriendsList, error)
+	GetMutualFriendsLists(ctx context.Context, email string, others []string, viewer string) ([]FriendsList, error)
+	GetRelationships(ctx context.Context, email string, others []string, viewer string) ([]Relationship, error)
 }
 
 // FriendshipManager is the implementation of recurring service
 type FriendshipManager struct {
-	dbconn *gorm.DB
+	dbconn       *gorm.DB
+	cache        cache.Cache
+	cacheTTL     time.Duration
+	generations  *cache.Generations
+	graph        *GraphIndex
+	graphQueries map[string]bool
 }
 
 // NewFriendshipManager initializes recurring service
@@ -29,59 +64,257 @@ func NewFriendshipManager(dbconn *gorm.DB) *FriendshipManager {
 	}
 }
 
-func (m *FriendshipManager) MakeFriend(input FrienshipServiceInput) error {
+// WithCache cache friend lists, followers and existing users in c for ttl,
+// entries of a pair are removed when a write to its friendship is committed
+func (m *FriendshipManager) WithCache(c cache.Cache, ttl time.Duration) *FriendshipManager {
+	m.cache = c
+	m.cacheTTL = ttl
+	m.generations = cache.NewGenerations()
+	return m
+}
+
+// WithGraphIndex keep index current on each committed write and answer queries from it instead of SQL,
+// queries are the names of the queries answered by the index, e.g. GraphQueryFriends
+func (m *FriendshipManager) WithGraphIndex(index *GraphIndex, queries map[string]bool) *FriendshipManager {
+	m.graph = index
+	m.graphQueries = queries
+	return m
+}
+
+// useGraph return true when query is answered by the graph index, SQL is used until the index is loaded
+func (m *FriendshipManager) useGraph(query string) bool {
+	return m.graph != nil && m.graphQueries[query] && m.graph.Loaded()
+}
+
+// withDB return a manager on dbconn, the cache and the graph index are kept
+func (m *FriendshipManager) withDB(dbconn *gorm.DB) *FriendshipManager {
+	return &FriendshipManager{dbconn: dbconn, cache: m.cache, cacheTTL: m.cacheTTL, generations: m.generations, graph: m.graph, graphQueries: m.graphQueries}
+}
+
+// withContext return a manager whose queries are bound to ctx, they are cancelled when ctx is done
+func (m *FriendshipManager) withContext(ctx context.Context) *FriendshipManager {
+	return m.withDB(m.dbconn.WithContext(ctx))
+}
+
+// transaction run fn in a unit of work, when the manager is built on a transaction
+// fn runs in a savepoint of it so the caller decide whether all writes are committed
+func (m *FriendshipManager) transaction(fn func(txManager *FriendshipManager) error) error {
+	return unitofwork.NewGormUnitOfWork(m.dbconn).Do(func(tx *gorm.DB) error {
+		return fn(m.withDB(tx))
+	})
+}
+
+const (
+	cacheFriends   = "friends"
+	cacheFollowers = "followers"
+	cacheUsers     = "users"
+)
+
+func cacheKey(name string, email string) string {
+	return name + ":" + email
+}
+
+// cacheGeneration return the generation of key, it is read before the value is queried
+func (m *FriendshipManager) cacheGeneration(key string) uint64 {
+	if m.cache == nil {
+		return 0
+	}
+	return m.generations.Current(key)
+}
+
+// cacheSet store value in key once the transaction reading it is committed, so uncommitted rows are never cached.
+// It is not stored when key was invalidated since generation, the value may then be older than the write
+func (m *FriendshipManager) cacheSet(key string, generation uint64, value interface{}) {
+	if m.cache == nil {
+		return
+	}
+	ctx := m.dbconn.Statement.Context
+	unitofwork.AfterCommit(m.dbconn, func() {
+		m.generations.SetJSON(ctx, m.cache, key, generation, value, m.cacheTTL)
+	})
+}
+
+// friendshipWritten remove the cached lists of both users and update the graph index once the write to their
+// friendship is committed, a rolled back write is never seen. The time of the write orders the writes of a pair,
+// they are serialized by the lock of the friendship
+func (m *FriendshipManager) friendshipWritten(tx *gorm.DB, friendship Friendship, removed bool) {
+	if m.cache == nil && m.graph == nil {
+		return
+	}
+	ctx := tx.Statement.Context
+	at := time.Now()
+	first, second := canonicalPair(friendship.FirstUser, friendship.SecondUser)
+	friendship.FirstUser, friendship.SecondUser = first, second
+	unitofwork.AfterCommit(tx, func() {
+		if m.cache != nil {
+			m.generations.Invalidate(ctx, m.cache,
+				cacheKey(cacheFriends, first), cacheKey(cacheFriends, second),
+				cacheKey(cacheFollowers, first), cacheKey(cacheFollowers, second))
+		}
+
+		if m.graph == nil {
+			return
+		}
+		if removed {
+			m.graph.Remove(first, second, at)
+		} else {
+			m.graph.Set(friendship, at)
+		}
+	})
+}
+
+// InvalidateUser remove the cached existence of a removed user, its lists and the lists of the users in a pair
+// with it, and its pairs from the graph index. It is called in the transaction removing the user before its
+// friendships are deleted, the entries are removed once the transaction is committed
+func (m *FriendshipManager) InvalidateUser(tx *gorm.DB, email string) error {
+	if m.cache == nil && m.graph == nil {
+		return nil
+	}
+
+	listFriendships := []Friendship{}
+	rs := tx.Where("first_user = ? OR second_user = ?", email, email).Find(&listFriendships)
+	if rs.Error != nil {
+		return rs.Error
+	}
+	for _, friendship := range listFriendships {
+		m.friendshipWritten(tx, friendship, true)
+	}
+
+	if m.cache != nil {
+		ctx := tx.Statement.Context
+		unitofwork.AfterCommit(tx, func() {
+			m.generations.Invalidate(ctx, m.cache,
+				cacheKey(cacheUsers, email), cacheKey(cacheFriends, email), cacheKey(cacheFollowers, email))
+		})
+	}
+	return nil
+}
+
+func (m *FriendshipManager) MakeFriend(ctx context.Context, input FrienshipServiceInput) error {
+	m = m.withContext(ctx)
+
 	requestor := input.RequestEmail
 	target := input.TargetEmail
 
-	// Check Friends Connection Exist
-	friendship, err := m.checkFriendship(requestor, target)
+	// Check user exits
+	listUsers := []string{requestor, target}
 
-	if err != nil {
-		return err
-	}
+	return m.transaction(func(txManager *FriendshipManager) error {
+		ok, err := txManager.checkUserExist(listUsers)
+
+		if err != nil {
+			return err
+		}
+
+		if ok == false {
+			return txManager.inviteUser(requestor, target, InvitationFriend)
+		}
+
+		// Check Friends Connection Exist, it is locked until the end of the transaction
+		friendship, err := txManager.lockFriendship(requestor, target)
+		if err != nil {
+			return err
+		}
 
-	if friendship != nil {
 		if friendship.IsFriend == true {
 			return errors.New("Friendship was exist")
 		}
 
-		if friendship.UpdateStatus != 3 {
+		if friendship.blocked() {
 			return errors.New("Blocked Add Friend")
 		}
-		return m.execUpdateMakeFriend([]string{requestor, target})
-	}
 
-	// Check user exits
-	listUsers := []string{requestor, target}
+		if err := txManager.requireUserVerified(listUsers); err != nil {
+			return err
+		}
 
-	ok, err := m.checkUserExist(listUsers)
+		if err := txManager.checkFriendRequestPolicy(requestor, target); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		// When Make Friend both user will subscribe together
+		friendship.IsFriend = true
+		friendship.FirstFollowsSecond = true
+		friendship.SecondFollowsFirst = true
+		if err := txManager.updateFriendship(friendship); err != nil {
+			return err
+		}
+		unitofwork.AfterCommit(txManager.dbconn, func() {
+			metrics.FriendshipsCreated.Inc()
+			logger.FromContext(ctx).Info("friendship created", "requestor", requestor, "target", target)
+		})
+		return nil
+	})
+}
 
-	if ok == false {
-		return errors.New("User Not Exist")
+// lockFriendship return the friendship of the pair locked until the end of the transaction,
+// an empty friendship is inserted when the pair has none so concurrent writers always wait on the same row
+func (m *FriendshipManager) lockFriendship(a string, b string) (*Friendship, error) {
+	first, second := canonicalPair(a, b)
+
+	rs := m.dbconn.Clauses(clause.OnConflict{DoNothing: true}).Create(&Friendship{FirstUser: first, SecondUser: second})
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+	if rs.RowsAffected > 0 {
+		m.friendshipWritten(m.dbconn, Friendship{FirstUser: first, SecondUser: second}, false)
 	}
 
-	// When Make Friend both user will subscribe together
-	return m.execCreateFriendConnection(requestor, target, true, 2)
+	friendship := Friendship{}
+	rs = m.dbconn.Clauses(clause.Locking{Strength: "UPDATE"}).Where("first_user = ? AND second_user = ?", first, second).Limit(1).Find(&friendship)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+	return &friendship, nil
 }
 
-// execMakeFriend execute query make friend
-func (m *FriendshipManager) execCreateFriendConnection(requestor string, target string, isFr bool, updateStatus int) error {
-	rs := m.dbconn.Create(&Friendship{FirstUser: requestor, SecondUser: target, IsFriend: isFr, UpdateStatus: updateStatus})
+// updateFriendship write the flags of a friendship
+func (m *FriendshipManager) updateFriendship(friendship *Friendship) error {
+	m.friendshipWritten(m.dbconn, *friendship, false)
+	rs := m.dbconn.Model(&Friendship{}).Where("id = ?", friendship.ID).Updates(map[string]interface{}{
+		"is_friend":            friendship.IsFriend,
+		"first_follows_second": friendship.FirstFollowsSecond,
+		"second_follows_first": friendship.SecondFollowsFirst,
+		"first_blocks_second":  friendship.FirstBlocksSecond,
+		"second_blocks_first":  friendship.SecondBlocksFirst,
+	})
 	return rs.Error
 }
 
-// execMakeFriend execute query update make friend if requestor subscribe target but not be friend together
-func (m *FriendshipManager) execUpdateMakeFriend(input []string) error {
-	rs := m.dbconn.Model(&Friendship{}).Where("first_user IN ? AND second_user IN ?", input, input).Update("is_friend", true)
-	return rs.Error
+// Unfriend remove the friend connection between two users
+func (m *FriendshipManager) Unfriend(ctx context.Context, input FrienshipServiceInput) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *FriendshipManager) error {
+		friendship, err := txManager.lockFriendship(input.RequestEmail, input.TargetEmail)
+
+		if err != nil {
+			return err
+		}
+
+		if friendship.IsFriend == false {
+			return errors.New("Friendship Not Exist")
+		}
+
+		rs := txManager.dbconn.Unscoped().Where("first_user IN ? AND second_user IN ?", []string{input.RequestEmail, input.TargetEmail}, []string{input.RequestEmail, input.TargetEmail}).Delete(&Friendship{})
+		if rs.Error != nil {
+			return rs.Error
+		}
+		txManager.friendshipWritten(txManager.dbconn, Friendship{FirstUser: input.RequestEmail, SecondUser: input.TargetEmail}, true)
+		// Circles contain only friends
+		if err := NewCircleManager(txManager.dbconn).removeFromCircles(input.RequestEmail, input.TargetEmail); err != nil {
+			return err
+		}
+		unitofwork.AfterCommit(txManager.dbconn, func() {
+			logger.FromContext(ctx).Info("friendship removed", "requestor", input.RequestEmail, "target", input.TargetEmail)
+		})
+		return nil
+	})
 }
 
-// GetUserFriendList
-func (m *FriendshipManager) GetFriendsList(ur user.Users) ([]string, error) {
+// GetUserFriendList, viewer is the authenticated caller and it is empty for anonymous request
+func (m *FriendshipManager) GetFriendsList(ctx context.Context, ur user.Users, viewer string) ([]string, error) {
+	m = m.withContext(ctx)
 
 	IsExist, err := m.checkUserExist([]string{ur.Email})
 
@@ -93,21 +326,59 @@ func (m *FriendshipManager) GetFriendsList(ur user.Users) ([]string, error) {
 		return nil, errors.New("User Not Exist")
 	}
 
-	stm := `SELECT f1.second_user friend FROM friendships as f1 WHERE f1.first_user = ? UNION SELECT f2.first_user friend FROM friendships as f2 WHERE f2.second_user = ?`
+	setting, err := m.getPrivacySetting(ur.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.checkVisibility(ur.Email, viewer, setting.FriendList); err != nil {
+		return nil, err
+	}
+
+	// Visibility is checked on each request, the list does not depend on the viewer
+	if m.useGraph(GraphQueryFriends) {
+		return m.graph.Friends(ur.Email), nil
+	}
 
 	listFriend := []string{}
+	key := cacheKey(cacheFriends, ur.Email)
+	if cache.GetJSON(ctx, m.cache, cacheFriends, key, &listFriend) {
+		return listFriend, nil
+	}
+	generation := m.cacheGeneration(key)
+
+	listFriend, err = m.queryFriends(ur.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	listBlocked, err := m.getBlockedUsers(ur.Email)
+	if err != nil {
+		return nil, err
+	}
 
-	rs := m.dbconn.Raw(stm, ur.Email, ur.Email).Scan(&listFriend)
+	listFriend = exclude(listFriend, listBlocked)
+	m.cacheSet(key, generation, listFriend)
+	return listFriend, nil
+}
+
+// queryFriends return users are friends of email, a follow only pair is not a friendship
+func (m *FriendshipManager) queryFriends(email string) ([]string, error) {
+	stm := `SELECT f1.second_user friend FROM friendships as f1 WHERE f1.first_user = ? AND f1.is_friend = true UNION SELECT f2.first_user friend FROM friendships as f2 WHERE f2.second_user = ? AND f2.is_friend = true`
+
+	listFriend := []string{}
+
+	rs := m.dbconn.Raw(stm, email, email).Scan(&listFriend)
 
 	if rs.Error != nil {
 		return nil, rs.Error
 	}
-
 	return listFriend, nil
 }
 
-// GetMutualFriendsList
-func (m *FriendshipManager) GetMutualFriendsList(input FrienshipServiceInput) ([]string, error) {
+// GetMutualFriendsList, mutual friends are visible when settings of both users allow viewer
+func (m *FriendshipManager) GetMutualFriendsList(ctx context.Context, input FrienshipServiceInput, viewer string) ([]string, error) {
+	m = m.withContext(ctx)
 
 	listUsers := []string{input.RequestEmail, input.TargetEmail}
 
@@ -121,22 +392,67 @@ func (m *FriendshipManager) GetMutualFriendsList(input FrienshipServiceInput) ([
 		return nil, errors.New("User Not Exist")
 	}
 
+	for _, email := range listUsers {
+		setting, err := m.getPrivacySetting(email)
+		if err != nil {
+			return nil, err
+		}
+
+		// Mutual friends are part of the friend list, they are never more visible than it
+		for _, visibility := range []string{setting.FriendList, setting.MutualFriends} {
+			if err := m.checkVisibility(email, viewer, visibility); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if m.useGraph(GraphQueryMutualFriends) {
+		return m.graph.MutualFriends(input.RequestEmail, input.TargetEmail), nil
+	}
+
+	listMutualFriends, err := m.queryMutualFriends(input.RequestEmail, input.TargetEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users blocked each other can not see their common friends
+	listBlocked, err := m.getBlockedUsers(input.RequestEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	if contains(listBlocked, input.TargetEmail) {
+		return []string{}, nil
+	}
+
+	listTargetBlocked, err := m.getBlockedUsers(input.TargetEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	return exclude(listMutualFriends, append(listBlocked, listTargetBlocked...)), nil
+}
+
+// queryMutualFriends return users are friends of both users, follow only and blocked pairs are not friendships
+func (m *FriendshipManager) queryMutualFriends(firstUser string, secondUser string) ([]string, error) {
 	stm := `SELECT UserAFriends.friend FROM
 	(
-	 SELECT f1.second_user friend FROM friendships as f1 WHERE f1.first_user = ?
+	 SELECT f1.second_user friend FROM friendships as f1 WHERE f1.first_user = ? AND ` + friendsCondition("f1") + `
 		UNION 
-	 SELECT f2.first_user friend FROM friendships as f2 WHERE f2.second_user = ?
+	 SELECT f2.first_user friend FROM friendships as f2 WHERE f2.second_user = ? AND ` + friendsCondition("f2") + `
 	) AS UserAFriends
 	JOIN  
 	(
-	  SELECT f1.second_user friend FROM friendships as f1 WHERE f1.first_user = ?
+	  SELECT f1.second_user friend FROM friendships as f1 WHERE f1.first_user = ? AND ` + friendsCondition("f1") + `
 		UNION 
-	  SELECT f2.first_user friend FROM friendships as f2 WHERE f2.second_user = ?
+	  SELECT f2.first_user friend FROM friendships as f2 WHERE f2.second_user = ? AND ` + friendsCondition("f2") + `
 	) AS UserBFriends 
 	ON  UserAFriends.friend = UserBFriends.friend`
 
+	ctx, span := tracing.StartSpan(m.dbconn.Statement.Context, "FriendshipManager.queryMutualFriends")
 	listMutualFriends := []string{}
-	rs := m.dbconn.Raw(stm, input.RequestEmail, input.RequestEmail, input.TargetEmail, input.TargetEmail).Scan(&listMutualFriends)
+	rs := m.dbconn.WithContext(ctx).Raw(stm, firstUser, firstUser, secondUser, secondUser).Scan(&listMutualFriends)
+	tracing.EndSpan(span, rs.Error)
 
 	if rs.Error != nil {
 		return nil, rs.Error
@@ -145,136 +461,740 @@ func (m *FriendshipManager) GetMutualFriendsList(input FrienshipServiceInput) ([
 	return listMutualFriends, nil
 }
 
+// friendsCondition is the SQL condition of Friendship.friends on the friendships aliased by table
+func friendsCondition(table string) string {
+	return table + ".is_friend = true AND " + table + ".first_blocks_second = false AND " + table + ".second_blocks_first = false AND " + table + ".deleted_at IS NULL"
+}
+
 // Subscribe Update
-func (m *FriendshipManager) Subscribe(input FrienshipServiceInput) error {
+func (m *FriendshipManager) Subscribe(ctx context.Context, input FrienshipServiceInput) error {
+	m = m.withContext(ctx)
+
+	listUsers := []string{input.RequestEmail, input.TargetEmail}
+
+	return m.transaction(func(txManager *FriendshipManager) error {
+		IsExist, err := txManager.checkUserExist(listUsers)
+
+		if err != nil {
+			return err
+		}
+
+		if IsExist == false {
+			return txManager.inviteUser(input.RequestEmail, input.TargetEmail, InvitationSubscribe)
+		}
+
+		if err := txManager.requireUserVerified(listUsers); err != nil {
+			return err
+		}
+
+		friendship, err := txManager.lockFriendship(input.RequestEmail, input.TargetEmail)
+		if err != nil {
+			return err
+		}
+
+		// Subscription is recorded while the pair is blocked, but nothing is delivered
+		friendship.setFollows(input.RequestEmail, true)
+		if err := txManager.updateFriendship(friendship); err != nil {
+			return err
+		}
+		unitofwork.AfterCommit(txManager.dbconn, func() {
+			metrics.Subscriptions.Inc()
+			logger.FromContext(ctx).Info("subscription created", "requestor", input.RequestEmail, "target", input.TargetEmail)
+		})
+		return nil
+	})
+}
+
+func (m *FriendshipManager) Block(ctx context.Context, input FrienshipServiceInput) error {
+	m = m.withContext(ctx)
+
 	listUsers := []string{input.RequestEmail, input.TargetEmail}
 
+	return m.transaction(func(txManager *FriendshipManager) error {
+		IsExist, err := txManager.checkUserExist(listUsers)
+
+		if err != nil {
+			return err
+		}
+
+		if IsExist == false {
+			return errors.New("User Not Exist")
+		}
+
+		if err := txManager.requireUserVerified(listUsers); err != nil {
+			return err
+		}
+
+		friendship, err := txManager.lockFriendship(input.RequestEmail, input.TargetEmail)
+		if err != nil {
+			return err
+		}
+
+		// Requestor also stop following target, the subscription of target is kept but nothing is delivered
+		friendship.setBlocks(input.RequestEmail, true)
+		friendship.setFollows(input.RequestEmail, false)
+
+		// A blocked pair can not stay friends, circles contain only friends
+		if friendship.IsFriend {
+			friendship.IsFriend = false
+			if err := NewCircleManager(txManager.dbconn).removeFromCircles(input.RequestEmail, input.TargetEmail); err != nil {
+				return err
+			}
+		}
+		if err := txManager.updateFriendship(friendship); err != nil {
+			return err
+		}
+		unitofwork.AfterCommit(txManager.dbconn, func() {
+			metrics.Blocks.Inc()
+			logger.FromContext(ctx).Info("user blocked", "requestor", input.RequestEmail, "target", input.TargetEmail)
+		})
+		return nil
+	})
+}
+
+// GetUsersReceiveUpdate return subscribers of sender and mentioned users,
+// when circle is set only subscribers are members of the circle receive update
+func (m *FriendshipManager) GetUsersReceiveUpdate(ctx context.Context, sender string, metion []string, circle string) ([]string, error) {
+	m = m.withContext(ctx)
+
+	listUsers := []string{sender}
+
 	IsExist, err := m.checkUserExist(listUsers)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if IsExist == false {
-		return errors.New("User Not Exist")
+		return nil, errors.New("User Not Exist")
 	}
 
-	friendship, err := m.checkFriendship(input.RequestEmail, input.TargetEmail)
+	listFriend, err := m.getFollowers(sender)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if friendship != nil {
-		stm := `UPDATE friendships as f0
-		SET update_status = 
-		(select 
-			case 
-				when f.update_status = 0 then 
-					case 
-						when f.first_user = @fuser then 1 else 2 
-					end 
-				when f.update_status = 3 then 3
-				else
-					case
-						when f.first_user = @fuser then
-							case
-								when f.update_status = 1 then 1 else 3
-							end
-						else
-							case
-								when f.update_status = 1 then 3 else 2
-							end
-					end
-			END AS update_status_code 
-		from friendships as f where f.first_user IN (@fuser,@suser) AND f.second_user IN (@fuser,@suser)) 
-		WHERE f0.first_user IN (@fuser,@suser) AND f0.second_user IN (@fuser,@suser)`
-
-		rs := m.dbconn.Exec(stm, map[string]interface{}{"fuser": input.RequestEmail, "suser": input.TargetEmail})
+	if circle != "" {
+		members, err := NewCircleManager(m.dbconn).getCircleMembers(sender, circle)
+		if err != nil {
+			return nil, err
+		}
+		listFriend = intersect(listFriend, members)
+	}
 
-		if rs.Error != nil {
+	mentionValid := []string{}
+
+	rsCheckMentionValid := m.dbconn.Raw("select email from users where email IN ? and verified = true", metion).Scan(&mentionValid)
+
+	if rsCheckMentionValid.Error != nil {
+		return nil, rsCheckMentionValid.Error
+	}
+
+	mentionValid, err = m.filterMentionsByPrivacy(sender, mentionValid)
+	if err != nil {
+		return nil, err
+	}
+
+	listFriend = append(listFriend, mentionValid...)
+
+	// Users muted sender do not receive update
+	listMuters := []string{}
+
+	rsMuters := m.dbconn.Model(&Mute{}).Where("target = ?", sender).Pluck("muter", &listMuters)
+
+	if rsMuters.Error != nil {
+		return nil, rsMuters.Error
+	}
+
+	// Users blocked sender or blocked by sender do not receive update, even when they are mentioned
+	listBlocked, err := m.getBlockedUsers(sender)
+	if err != nil {
+		return nil, err
+	}
+
+	listRecipients := exclude(listFriend, append(listMuters, listBlocked...))
+	metrics.UpdatesFannedOut.Inc()
+	metrics.UpdateRecipients.Observe(float64(len(listRecipients)))
+	return listRecipients, nil
+}
+
+// Mute stop receiving updates from target, friendship between two users is not affected
+func (m *FriendshipManager) Mute(ctx context.Context, input FrienshipServiceInput) error {
+	m = m.withContext(ctx)
+
+	listUsers := []string{input.RequestEmail, input.TargetEmail}
+
+	return m.transaction(func(txManager *FriendshipManager) error {
+		IsExist, err := txManager.checkUserExist(listUsers)
+
+		if err != nil {
 			return err
 		}
-	} else {
-		rs1 := m.dbconn.Create(&Friendship{FirstUser: input.RequestEmail, SecondUser: input.TargetEmail, IsFriend: false, UpdateStatus: 1})
-		if rs1.Error != nil {
+
+		if IsExist == false {
+			return errors.New("User Not Exist")
+		}
+
+		if err := txManager.requireUserVerified(listUsers); err != nil {
 			return err
 		}
+
+		mute := Mute{Muter: input.RequestEmail, Target: input.TargetEmail}
+		rs := txManager.dbconn.Where(mute).FirstOrCreate(&mute)
+		return rs.Error
+	})
+}
+
+// Unmute receive updates from target again
+func (m *FriendshipManager) Unmute(ctx context.Context, input FrienshipServiceInput) error {
+	m = m.withContext(ctx)
+
+	rs := m.dbconn.Unscoped().Where("muter = ? AND target = ?", input.RequestEmail, input.TargetEmail).Delete(&Mute{})
+
+	if rs.Error != nil {
+		return rs.Error
+	}
+
+	if rs.RowsAffected <= 0 {
+		return errors.New("Mute Not Exist")
 	}
 	return nil
 }
 
-func (m *FriendshipManager) Block(input FrienshipServiceInput) error {
-	listUsers := []string{input.RequestEmail, input.TargetEmail}
+// GetRelationship return the relationship between RequestEmail and TargetEmail seen from RequestEmail
+func (m *FriendshipManager) GetRelationship(ctx context.Context, input FrienshipServiceInput, viewer string) (Relationship, error) {
+	m = m.withContext(ctx)
 
-	IsExist, err := m.checkUserExist(listUsers)
+	relationship := Relationship{User: input.RequestEmail, Other: input.TargetEmail}
+
+	if !relationshipVisibleTo(input.RequestEmail, input.TargetEmail, viewer) {
+		return relationship, ErrPrivacyRestricted
+	}
 
+	IsExist, err := m.checkUserExist([]string{input.RequestEmail})
 	if err != nil {
-		return err
+		return relationship, err
 	}
 
 	if IsExist == false {
-		return errors.New("User Not Exist")
+		return relationship, errors.New("User Not Exist")
 	}
 
 	friendship, err := m.checkFriendship(input.RequestEmail, input.TargetEmail)
 	if err != nil {
-		return err
+		return relationship, err
+	}
+
+	relationship.setFriendship(friendship)
+
+	invitation := Invitation{}
+	rs := m.dbconn.Where("requestor IN ? AND email IN ? AND kind = ?", []string{input.RequestEmail, input.TargetEmail}, []string{input.RequestEmail, input.TargetEmail}, InvitationFriend).Limit(1).Find(&invitation)
+	if rs.Error != nil {
+		return relationship, rs.Error
+	}
+
+	if rs.RowsAffected > 0 {
+		relationship.setInvitation(&invitation)
+	}
+	return relationship, nil
+}
+
+// GetRelationships return the relationship between email and each of others seen from email, in the order of others.
+// It reads all of them with one query of the friendships and one of the invitations, a relationship
+// viewer is not part of has ErrPrivacyRestricted as error
+func (m *FriendshipManager) GetRelationships(ctx context.Context, email string, others []string, viewer string) ([]Relationship, error) {
+	m = m.withContext(ctx)
+
+	IsExist, err := m.checkUserExist([]string{email})
+	if err != nil {
+		return nil, err
+	}
+
+	if IsExist == false {
+		return nil, errors.New("User Not Exist")
+	}
+
+	friendships := []Friendship{}
+	rs := m.dbconn.Where("(first_user = ? AND second_user IN ?) OR (second_user = ? AND first_user IN ?)", email, others, email, others).Find(&friendships)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+
+	invitations := []Invitation{}
+	rs = m.dbconn.Where("((requestor = ? AND email IN ?) OR (email = ? AND requestor IN ?)) AND kind = ?", email, others, email, others, InvitationFriend).Find(&invitations)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+
+	listRelationships := make([]Relationship, 0, len(others))
+	for _, other := range others {
+		relationship := Relationship{User: email, Other: other}
+		if !relationshipVisibleTo(email, other, viewer) {
+			relationship.Err = ErrPrivacyRestricted
+			listRelationships = append(listRelationships, relationship)
+			continue
+		}
+		for i := range friendships {
+			if friendships[i].FirstUser == other || friendships[i].SecondUser == other {
+				relationship.setFriendship(&friendships[i])
+			}
+		}
+		for i := range invitations {
+			if invitations[i].Requestor == other || invitations[i].Email == other {
+				relationship.setInvitation(&invitations[i])
+			}
+		}
+		listRelationships = append(listRelationships, relationship)
+	}
+	return listRelationships, nil
+}
+
+// GetFriendsLists return the friend list of each user visible to viewer like GetFriendsList, in the order of emails.
+// A list which can not be read has its error, the friendships of all users are read with one query
+func (m *FriendshipManager) GetFriendsLists(ctx context.Context, emails []string, viewer string) ([]FriendsList, error) {
+	m = m.withContext(ctx)
+
+	existing, settings, neighbors, err := m.loadUsersGraph(emails)
+	if err != nil {
+		return nil, err
+	}
+
+	listFriends := make([]FriendsList, 0, len(emails))
+	for _, email := range emails {
+		list := FriendsList{Email: email}
+		switch {
+		case !existing[email]:
+			list.Err = errors.New("User Not Exist")
+		case !visibleTo(email, viewer, settings[email].FriendList, neighbors[email][viewer]):
+			list.Err = ErrPrivacyRestricted
+		case m.useGraph(GraphQueryFriends):
+			list.Friends = m.graph.Friends(email)
+		default:
+			list.Friends = []string{}
+			for other, friendship := range neighbors[email] {
+				if friendship.friends() {
+					list.Friends = append(list.Friends, other)
+				}
+			}
+			sort.Strings(list.Friends)
+		}
+		listFriends = append(listFriends, list)
+	}
+	return listFriends, nil
+}
+
+// GetMutualFriendsLists return the mutual friends of email and each of others visible to viewer like GetMutualFriendsList,
+// in the order of others. A list which can not be read has its error, the friendships of all users are read with one query
+func (m *FriendshipManager) GetMutualFriendsLists(ctx context.Context, email string, others []string, viewer string) ([]FriendsList, error) {
+	m = m.withContext(ctx)
+
+	existing, settings, neighbors, err := m.loadUsersGraph(append([]string{email}, others...))
+	if err != nil {
+		return nil, err
+	}
+
+	listMutualFriends := make([]FriendsList, 0, len(others))
+	for _, other := range others {
+		list := FriendsList{Email: other}
+		switch {
+		case !existing[email] || !existing[other]:
+			list.Err = errors.New("User Not Exist")
+		case !mutualFriendsVisibleTo(email, viewer, settings[email], neighbors[email][viewer]) ||
+			!mutualFriendsVisibleTo(other, viewer, settings[other], neighbors[other][viewer]):
+			list.Err = ErrPrivacyRestricted
+		case m.useGraph(GraphQueryMutualFriends):
+			list.Friends = m.graph.MutualFriends(email, other)
+		default:
+			// Users blocked each other can not see their common friends
+			list.Friends = []string{}
+			if pair, ok := neighbors[email][other]; ok && pair.blocked() {
+				break
+			}
+			for friend, first := range neighbors[email] {
+				second, ok := neighbors[other][friend]
+				if ok && first.friends() && second.friends() {
+					list.Friends = append(list.Friends, friend)
+				}
+			}
+			sort.Strings(list.Friends)
+		}
+		listMutualFriends = append(listMutualFriends, list)
+	}
+	return listMutualFriends, nil
+}
+
+// loadUsersGraph read the existence, the privacy setting and the friendships of users with one query each,
+// friendships are indexed by user then by the other user of the pair
+func (m *FriendshipManager) loadUsersGraph(emails []string) (map[string]bool, map[string]user.PrivacySetting, map[string]map[string]*Friendship, error) {
+	registered := []user.Users{}
+	rs := m.dbconn.Select("email").Where("email IN ?", emails).Find(&registered)
+	if rs.Error != nil {
+		return nil, nil, nil, rs.Error
+	}
+
+	existing := map[string]bool{}
+	for _, ur := range registered {
+		existing[ur.Email] = true
+	}
+
+	stored := []user.PrivacySetting{}
+	rs = m.dbconn.Where("email IN ?", emails).Find(&stored)
+	if rs.Error != nil {
+		return nil, nil, nil, rs.Error
+	}
+
+	settings := map[string]user.PrivacySetting{}
+	for _, email := range emails {
+		settings[email] = user.DefaultPrivacySetting(email)
+	}
+	for _, setting := range stored {
+		settings[setting.Email] = setting
+	}
+
+	friendships := []Friendship{}
+	rs = m.dbconn.Where("first_user IN ? OR second_user IN ?", emails, emails).Find(&friendships)
+	if rs.Error != nil {
+		return nil, nil, nil, rs.Error
 	}
-	if friendship != nil {
-		stm := `UPDATE friendships as f0
-		SET update_status = 
-		(select 
-			case 
-				when f.update_status = 3 then
-					case
-						when f.first_user = @fuser then 2 else 1	
-					end
-				else
-					case
-						when f.update_status <> 0 then
-							case
-								when f.first_user = @fuser then
-									case
-										when f.update_status = 1 then 0 else 2
-									end
-								else
-									case
-										when f.update_status = 2 then 0 else 1
-									end
-							end
-						else 0	
-					end	
-			END AS update_status_code 
-		from friendships as f where f.first_user IN (@fuser,@suser) AND f.second_user IN (@fuser,@suser)) 
-		WHERE f0.first_user IN (@fuser,@suser) AND f0.second_user IN (@fuser,@suser)`
-
-		rs := m.dbconn.Exec(stm, map[string]interface{}{"fuser": input.RequestEmail, "suser": input.TargetEmail})
 
+	neighbors := map[string]map[string]*Friendship{}
+	for i := range friendships {
+		friendship := &friendships[i]
+		for _, pair := range [][2]string{{friendship.FirstUser, friendship.SecondUser}, {friendship.SecondUser, friendship.FirstUser}} {
+			if neighbors[pair[0]] == nil {
+				neighbors[pair[0]] = map[string]*Friendship{}
+			}
+			neighbors[pair[0]][pair[1]] = friendship
+		}
+	}
+	return existing, settings, neighbors, nil
+}
+
+// GetRelationshipVersions return the relationship version of each user in the order of emails,
+// with its version in the graph index when the index is used
+func (m *FriendshipManager) GetRelationshipVersions(ctx context.Context, emails []string) ([]RelationshipVersion, error) {
+	m = m.withContext(ctx)
+
+	stored := []RelationshipVersion{}
+	rs := m.dbconn.Where("email IN ?", emails).Find(&stored)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+
+	versions := make([]RelationshipVersion, 0, len(emails))
+	for _, email := range emails {
+		version := RelationshipVersion{Email: email}
+		for _, v := range stored {
+			if v.Email == email {
+				version = v
+			}
+		}
+		if m.graph != nil {
+			version.IndexVersion = m.graph.Version(email)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// ImportContacts match contacts of owner against registered users and
+// optionally make friend or subscribe to all of them in one transaction
+func (m *FriendshipManager) ImportContacts(ctx context.Context, owner string, contacts []string, action string) ([]ContactStatus, error) {
+	m = m.withContext(ctx)
+
+	if action != ContactActionNone && action != ContactActionSubscribe && action != ContactActionMakeFriend {
+		return nil, errors.New("Import Action Invalid")
+	}
+
+	listContacts := []ContactStatus{}
+
+	err := m.transaction(func(txManager *FriendshipManager) error {
+		tx := txManager.dbconn
+
+		IsExist, err := txManager.checkUserExist([]string{owner})
+
+		if err != nil {
+			return err
+		}
+
+		if IsExist == false {
+			return errors.New("User Not Exist")
+		}
+
+		if action != ContactActionNone {
+			if err := txManager.requireUserVerified([]string{owner}); err != nil {
+				return err
+			}
+		}
+
+		registered := []user.Users{}
+		rs := tx.Select("email, verified").Where("email IN ?", contacts).Find(&registered)
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		verifiedUsers := map[string]bool{}
+		for _, ur := range registered {
+			verifiedUsers[ur.Email] = ur.Verified
+		}
+
+		friendships := []Friendship{}
+		rs = tx.Where("(first_user = ? AND second_user IN ?) OR (second_user = ? AND first_user IN ?)", owner, contacts, owner, contacts).Find(&friendships)
 		if rs.Error != nil {
+			return rs.Error
+		}
+
+		connections := map[string]Friendship{}
+		for _, friendship := range friendships {
+			if friendship.FirstUser == owner {
+				connections[friendship.SecondUser] = friendship
+			} else {
+				connections[friendship.FirstUser] = friendship
+			}
+		}
+
+		listBlocked, err := txManager.getBlockedUsers(owner)
+		if err != nil {
 			return err
 		}
+
+		for _, contact := range contacts {
+			verified, registered := verifiedUsers[contact]
+			contactStatus := ContactStatus{Email: contact, Registered: registered}
+
+			if friendship, ok := connections[contact]; ok {
+				contactStatus.Friend = friendship.IsFriend
+			}
+			contactStatus.Blocked = contains(listBlocked, contact)
+
+			if action != ContactActionNone {
+				status, err := txManager.applyContactAction(ctx, owner, contactStatus, verified, action)
+				if err != nil {
+					return err
+				}
+				contactStatus.Status = status
+			}
+
+			listContacts = append(listContacts, contactStatus)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return listContacts, nil
+}
+
+// applyContactAction make friend or subscribe a contact, not registered contact will be invited
+// and contact refusing the request by its privacy settings is reported as restricted
+func (m *FriendshipManager) applyContactAction(ctx context.Context, owner string, contact ContactStatus, verified bool, action string) (string, error) {
+	// Blocked contact is the same condition MakeFriend use to refuse a friend connection
+	if contact.Email == owner || contact.Friend || contact.Blocked {
+		return ContactSkipped, nil
+	}
+
+	if contact.Registered && verified == false {
+		return ContactSkipped, nil
+	}
+
+	input := FrienshipServiceInput{RequestEmail: owner, TargetEmail: contact.Email}
+
+	var err error
+	status := ContactConnected
+	if action == ContactActionMakeFriend {
+		err = m.MakeFriend(ctx, input)
 	} else {
+		status = ContactSubscribed
+		err = m.Subscribe(ctx, input)
+	}
 
-		rs1 := m.dbconn.Create(&Friendship{FirstUser: input.RequestEmail, SecondUser: input.TargetEmail, IsFriend: false, UpdateStatus: -1})
-		if rs1.Error != nil {
-			return err
+	if err == ErrInvitationPending {
+		return ContactInvited, nil
+	}
+
+	// Refused request is rolled back with the savepoint of MakeFriend, the other contacts are still imported
+	if err == ErrPrivacyRestricted {
+		return ContactRestricted, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// ExecuteBatch execute operations in order, in atomic mode all operations are rolled back when one of them failed
+func (m *FriendshipManager) ExecuteBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	m = m.withContext(ctx)
+
+	listResults := make([]BatchResult, len(operations))
+	for i, operation := range operations {
+		listResults[i] = BatchResult{Operation: operation.Operation, Requestor: operation.Input.RequestEmail, Target: operation.Input.TargetEmail}
+	}
+
+	if atomic == false {
+		for i, operation := range operations {
+			m.executeOperation(ctx, operation, &listResults[i])
+		}
+		return listResults, nil
+	}
+
+	// Each operation runs in its own unit of work composed into the transaction of the batch
+	failed := -1
+	err := m.transaction(func(txManager *FriendshipManager) error {
+		for i, operation := range operations {
+			if err := txManager.executeOperation(ctx, operation, &listResults[i]); err != nil {
+				failed = i
+				return err
+			}
 		}
+		return nil
+	})
+
+	if err != nil && failed < 0 {
+		return nil, err
+	}
+
+	if failed >= 0 {
+		for i := range listResults {
+			if i < failed {
+				listResults[i].Success = false
+				listResults[i].Invited = false
+				listResults[i].Error = "Rolled Back"
+			} else if i > failed {
+				listResults[i].Error = "Not Executed"
+			}
+		}
+		return listResults, ErrBatchRolledBack
+	}
+
+	return listResults, nil
+}
+
+// executeOperation execute one operation of a batch and record its result
+func (m *FriendshipManager) executeOperation(ctx context.Context, operation BatchOperation, result *BatchResult) error {
+	var err error
+	switch operation.Operation {
+	case OperationMakeFriend:
+		err = m.MakeFriend(ctx, operation.Input)
+	case OperationSubscribe:
+		err = m.Subscribe(ctx, operation.Input)
+	case OperationBlock:
+		err = m.Block(ctx, operation.Input)
+	case OperationUnfriend:
+		err = m.Unfriend(ctx, operation.Input)
+	default:
+		err = errors.New("Operation Invalid")
+	}
+
+	if err == ErrInvitationPending {
+		result.Success = true
+		result.Invited = true
+		return nil
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		return err
 	}
+
+	result.Success = true
 	return nil
 }
 
-func (m *FriendshipManager) GetUsersReceiveUpdate(sender string, metion []string) ([]string, error) {
-	listUsers := []string{sender}
+// inviteUser record an invitation when requestor is registered but target is not,
+// it runs in the unit of work of the caller which commits the invitation
+func (m *FriendshipManager) inviteUser(requestor string, target string, kind string) error {
+	ok, err := m.checkUserExist([]string{requestor})
+	if err != nil {
+		return err
+	}
 
-	IsExist, err := m.checkUserExist(listUsers)
+	if ok == false {
+		return errors.New("User Not Exist")
+	}
 
+	ok, err = m.checkUserExist([]string{target})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if IsExist == false {
-		return nil, errors.New("User Not Exist")
+	// Requestor and target are registered but the requestor was not found in the first check
+	if ok == true {
+		return errors.New("User Not Exist")
+	}
+
+	if err := m.requireUserVerified([]string{requestor}); err != nil {
+		return err
+	}
+
+	invitation := Invitation{Requestor: requestor, Email: target, Kind: kind}
+	rs := m.dbconn.Where(invitation).FirstOrCreate(&invitation)
+	if rs.Error != nil {
+		return rs.Error
+	}
+	// The invitation is committed although the unit of work returns an error
+	return unitofwork.Commit(ErrInvitationPending)
+}
+
+// AcceptInvitations convert the pending invitations of a new user into friendship,
+// it is called in the transaction verifying the email address of the user
+func (m *FriendshipManager) AcceptInvitations(tx *gorm.DB, email string) error {
+	invitations := []Invitation{}
+	rs := tx.Where("email = ?", email).Order("kind, id").Find(&invitations)
+	if rs.Error != nil {
+		return rs.Error
+	}
+
+	// A friend invitation already includes subscription, so only one connection is created per requestor
+	connected := map[string]bool{}
+	for _, invitation := range invitations {
+		if connected[invitation.Requestor] == true {
+			continue
+		}
+		connected[invitation.Requestor] = true
+
+		first, second := canonicalPair(invitation.Requestor, email)
+		friendship := Friendship{FirstUser: first, SecondUser: second}
+		friendship.setFollows(invitation.Requestor, true)
+		if invitation.Kind == InvitationFriend {
+			friendship.IsFriend = true
+			friendship.setFollows(email, true)
+		}
+
+		rs := tx.Create(&friendship)
+		if rs.Error != nil {
+			return rs.Error
+		}
+		m.friendshipWritten(tx, friendship, false)
+
+		if friendship.IsFriend {
+			unitofwork.AfterCommit(tx, metrics.FriendshipsCreated.Inc)
+		} else {
+			unitofwork.AfterCommit(tx, metrics.Subscriptions.Inc)
+		}
+	}
+
+	rs = tx.Unscoped().Where("email = ?", email).Delete(&Invitation{})
+	return rs.Error
+}
+
+// getFollowers return users follow sender and are not in a blocked pair with it,
+// they are cached until a friendship of sender is written
+func (m *FriendshipManager) getFollowers(sender string) ([]string, error) {
+	if m.useGraph(GraphQueryFollowers) {
+		return m.graph.Followers(sender), nil
+	}
+
+	ctx := m.dbconn.Statement.Context
+	listFollowers := []string{}
+	key := cacheKey(cacheFollowers, sender)
+	if cache.GetJSON(ctx, m.cache, cacheFollowers, key, &listFollowers) {
+		return listFollowers, nil
 	}
+	generation := m.cacheGeneration(key)
 
 	stm := `select
 				f1.second_user friend
@@ -282,8 +1202,9 @@ func (m *FriendshipManager) GetUsersReceiveUpdate(sender string, metion []string
 				friendships as f1
 			where
 				f1.first_user = ?
-				and (f1.update_status <> 1 AND f1.update_status <> 0)  
-				and (f1.is_friend = true or f1.update_status = 2 or f1.update_status = 3)
+				and f1.second_follows_first = true
+				and f1.first_blocks_second = false and f1.second_blocks_first = false
+				and f1.deleted_at IS NULL
 			union
 			select
 				f2.first_user friend
@@ -291,34 +1212,137 @@ func (m *FriendshipManager) GetUsersReceiveUpdate(sender string, metion []string
 				friendships as f2
 			where
 				f2.second_user = ?
-				and (f2.update_status <> 2 OR f2.update_status <> 0)  
-				and (f2.is_friend = true or f2.update_status = 1 or f2.update_status = 3)`
-
-	listFriend := []string{}
+				and f2.first_follows_second = true
+				and f2.first_blocks_second = false and f2.second_blocks_first = false
+				and f2.deleted_at IS NULL`
 
-	rs := m.dbconn.Raw(stm, sender, sender).Scan(&listFriend)
+	rs := m.dbconn.Raw(stm, sender, sender).Scan(&listFollowers)
 
 	if rs.Error != nil {
-		return nil, err
+		return nil, rs.Error
 	}
 
-	mentionValid := []string{}
+	m.cacheSet(key, generation, listFollowers)
+	return listFollowers, nil
+}
 
-	rsCheckMentionValid := m.dbconn.Raw("select email from users where email IN ?", metion).Scan(&mentionValid)
+// checkVisibility return ErrPrivacyRestricted when viewer is not allowed by the visibility setting of owner
+func (m *FriendshipManager) checkVisibility(owner string, viewer string, visibility string) error {
+	var friendship *Friendship
+	if visibility == user.VisibilityFriends && viewer != "" && viewer != owner {
+		var err error
+		friendship, err = m.checkFriendship(owner, viewer)
+		if err != nil {
+			return err
+		}
+	}
 
-	if rsCheckMentionValid.Error != nil {
-		return nil, err
+	if !visibleTo(owner, viewer, visibility, friendship) {
+		return ErrPrivacyRestricted
 	}
+	return nil
+}
 
-	listFriend = append(listFriend, mentionValid...)
+// visibleTo return true when viewer is allowed by the visibility setting of owner, friendship is the pair of owner and viewer
+func visibleTo(owner string, viewer string, visibility string, friendship *Friendship) bool {
+	if viewer == owner || visibility == user.VisibilityEveryone {
+		return true
+	}
+	return visibility == user.VisibilityFriends && viewer != "" && friendship != nil && friendship.IsFriend
+}
 
-	return listFriend, nil
+// mutualFriendsVisibleTo return true when viewer can see the mutual friends of owner, like GetMutualFriendsList
+func mutualFriendsVisibleTo(owner string, viewer string, setting user.PrivacySetting, friendship *Friendship) bool {
+	return visibleTo(owner, viewer, setting.FriendList, friendship) && visibleTo(owner, viewer, setting.MutualFriends, friendship)
+}
+
+// relationshipVisibleTo return true when viewer is one of the users, the relationship of a pair tells
+// whether they are friends, follow or block each other and it is never shown to another user
+func relationshipVisibleTo(email string, other string, viewer string) bool {
+	return viewer != "" && (viewer == email || viewer == other)
+}
+
+// checkFriendRequestPolicy return ErrPrivacyRestricted when target does not accept friend request from requestor
+func (m *FriendshipManager) checkFriendRequestPolicy(requestor string, target string) error {
+	setting, err := m.getPrivacySetting(target)
+	if err != nil {
+		return err
+	}
+
+	switch setting.FriendRequest {
+	case user.FriendRequestNobody:
+		return ErrPrivacyRestricted
+	case user.FriendRequestFriendsOfFriends:
+		listMutualFriends, err := m.queryMutualFriends(requestor, target)
+		if err != nil {
+			return err
+		}
+		if len(listMutualFriends) == 0 {
+			return ErrPrivacyRestricted
+		}
+	}
+	return nil
+}
+
+// filterMentionsByPrivacy remove mentioned users do not want to be notified by sender is not their friend
+func (m *FriendshipManager) filterMentionsByPrivacy(sender string, mentioned []string) ([]string, error) {
+	result := []string{}
+	for _, email := range mentioned {
+		setting, err := m.getPrivacySetting(email)
+		if err != nil {
+			return nil, err
+		}
+
+		if setting.NotifyNonFriendMentions == false {
+			ok, err := m.isFriend(email, sender)
+			if err != nil {
+				return nil, err
+			}
+			if ok == false {
+				continue
+			}
+		}
+		result = append(result, email)
+	}
+	return result, nil
+}
+
+func (m *FriendshipManager) isFriend(firstUser string, secondUser string) (bool, error) {
+	friendship, err := m.checkFriendship(firstUser, secondUser)
+	if err != nil {
+		return false, err
+	}
+	return friendship != nil && friendship.IsFriend == true, nil
+}
+
+func (m *FriendshipManager) getPrivacySetting(email string) (user.PrivacySetting, error) {
+	ur := user.NewUserManager(m.dbconn)
+	return ur.GetPrivacySetting(m.dbconn.Statement.Context, email)
+}
+
+// getBlockedUsers return users blocked user or blocked by user, they are hidden from user
+func (m *FriendshipManager) getBlockedUsers(email string) ([]string, error) {
+	stm := `SELECT f1.second_user blocked FROM friendships as f1
+			WHERE f1.first_user = ? AND (f1.first_blocks_second = true OR f1.second_blocks_first = true) AND f1.deleted_at IS NULL
+		UNION
+		SELECT f2.first_user blocked FROM friendships as f2
+			WHERE f2.second_user = ? AND (f2.first_blocks_second = true OR f2.second_blocks_first = true) AND f2.deleted_at IS NULL`
+
+	listBlocked := []string{}
+
+	rs := m.dbconn.Raw(stm, email, email).Scan(&listBlocked)
+
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+	return listBlocked, nil
 }
 
 // Check Connection Between Two User
 func (m *FriendshipManager) checkFriendship(firstUser, secondUser string) (*Friendship, error) {
+	first, second := canonicalPair(firstUser, secondUser)
 	friendship := Friendship{}
-	rs := m.dbconn.Where("first_user IN ? AND second_user IN ?", []string{firstUser, secondUser}, []string{firstUser, secondUser}).Find(&Friendship{}).Scan(&friendship)
+	rs := m.dbconn.Where("first_user = ? AND second_user = ?", first, second).Find(&Friendship{}).Scan(&friendship)
 	if rs.Error != nil {
 		return nil, rs.Error
 	}
@@ -329,6 +1353,87 @@ func (m *FriendshipManager) checkFriendship(firstUser, secondUser string) (*Frie
 }
 
 func (m *FriendshipManager) checkUserExist(listUsers []string) (bool, error) {
+	ctx, span := tracing.StartSpan(m.dbconn.Statement.Context, "FriendshipManager.checkUserExist", attribute.Int("friendship.users", len(listUsers)))
+
+	// Only existing users are cached, so a new user does not need an invalidation
+	missing := []string{}
+	generations := map[string]uint64{}
+	for _, email := range listUsers {
+		exist := false
+		key := cacheKey(cacheUsers, email)
+		if !cache.GetJSON(ctx, m.cache, cacheUsers, key, &exist) || !exist {
+			missing = append(missing, email)
+			generations[email] = m.cacheGeneration(key)
+		}
+	}
+	span.SetAttributes(attribute.Int("friendship.users_cached", len(listUsers)-len(missing)))
+	if len(missing) == 0 {
+		tracing.EndSpan(span, nil)
+		return true, nil
+	}
+
+	ur := user.NewUserManager(m.dbconn.WithContext(ctx))
+	ok, err := ur.CheckUserExist(missing)
+	tracing.EndSpan(span, err)
+
+	if ok {
+		for _, email := range missing {
+			m.cacheSet(cacheKey(cacheUsers, email), generations[email], true)
+		}
+	}
+	return ok, err
+}
+
+// requireUserVerified return error when one of users not yet confirm email address
+func (m *FriendshipManager) requireUserVerified(listUsers []string) error {
 	ur := user.NewUserManager(m.dbconn)
-	return ur.CheckUserExist(listUsers)
+	ok, err := ur.CheckUserVerified(listUsers)
+	if err != nil {
+		return err
+	}
+	if ok == false {
+		return errors.New("User Not Verified")
+	}
+	return nil
+}
+
+// intersect return elements of list are also in other
+func intersect(list []string, other []string) []string {
+	set := map[string]bool{}
+	for _, element := range other {
+		set[element] = true
+	}
+
+	result := []string{}
+	for _, element := range list {
+		if set[element] == true {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// exclude return elements of list are not in other
+func exclude(list []string, other []string) []string {
+	set := map[string]bool{}
+	for _, element := range other {
+		set[element] = true
+	}
+
+	result := []string{}
+	for _, element := range list {
+		if set[element] == false {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+func contains(list []string, element string) bool {
+	for _, e := range list {
+		if e == element {
+			return true
+		}
+	}
+	return false
 }
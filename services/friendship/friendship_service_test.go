@@ -1,19 +1,28 @@
 package friendship
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"friend_connection_rest_api/services/cache"
+	"friend_connection_rest_api/services/mailer"
 	"friend_connection_rest_api/services/user"
 	"friend_connection_rest_api/utils"
 
 	randomData "github.com/Pallinder/go-randomdata"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
 )
 
 // TestMakeFriendSuccess func test create friend connection between 2 users and both not yet subscribe/block together
 func TestMakeFriend(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 	users, ok := insertUsersTest(tx, 2)
@@ -42,24 +51,129 @@ func TestMakeFriend(t *testing.T) {
 			expectedError: errors.New("Friendship was exist"),
 		},
 		{
-			scenario: "User not exist",
+			scenario: "Target not registered",
 			mockInput: FrienshipServiceInput{
 				RequestEmail: users[0],
 				TargetEmail:  "usernotexist123@notexist.notfound",
 			},
+			expectedError: ErrInvitationPending,
+		},
+		{
+			scenario: "User not exist",
+			mockInput: FrienshipServiceInput{
+				RequestEmail: "usernotexist123@notexist.notfound",
+				TargetEmail:  users[0],
+			},
 			expectedError: errors.New("User Not Exist"),
 		},
 	}
 
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
-			actualRs := friendshipManager.MakeFriend(tc.mockInput)
+			actualRs := friendshipManager.MakeFriend(ctx, tc.mockInput)
 			assert.Equal(t, tc.expectedError, actualRs)
 		})
 	}
 }
 
+func TestCancelledContext(t *testing.T) {
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+	defer tx.Rollback()
+
+	users, ok := insertUsersTest(tx, 2)
+	assert.Equal(t, true, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Queries of a cancelled request are not executed and nothing is written
+	friendshipManager := NewFriendshipManager(tx)
+	err := friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]})
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	friendship, err := friendshipManager.checkFriendship(users[0], users[1])
+	assert.Nil(t, err)
+	assert.Nil(t, friendship)
+}
+
+func TestMakeFriendUserNotVerified(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+	users, ok := insertUsersTest(tx, 1)
+	assert.Equal(t, true, ok)
+
+	unverified := randomData.Email()
+	assert.NoError(t, user.NewUserManager(tx).CreateNewUser(ctx, user.Users{Email: unverified}))
+
+	friendshipManager := NewFriendshipManager(tx)
+	err := friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: unverified})
+	assert.Equal(t, errors.New("User Not Verified"), err)
+
+	// An existing row of the pair does not skip the verification
+	first, second := canonicalPair(users[0], unverified)
+	assert.NoError(t, tx.Create(&Friendship{FirstUser: first, SecondUser: second}).Error)
+	err = friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: unverified})
+	assert.Equal(t, errors.New("User Not Verified"), err)
+}
+
+func TestAcceptInvitations(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 2
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	friendshipManager := NewFriendshipManager(tx)
+	newUser := randomData.Email()
+
+	// users[0] invite to make friend, users[1] invite to subscribe
+	assert.Equal(t, ErrInvitationPending, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: newUser}))
+	assert.Equal(t, ErrInvitationPending, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: newUser}))
+	assert.Equal(t, ErrInvitationPending, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: newUser}))
+
+	// Capture the token was sent to the new user
+	var token string
+	mailerMock := new(mailer.MailerMock)
+	mailerMock.On("Send", newUser, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		body := args.String(2)
+		token = strings.TrimSpace(strings.Split(strings.Split(body, ":")[1], "\n")[0])
+	}).Return(nil)
+
+	userManager := user.NewUserManager(tx).WithMailer(mailerMock).WithVerifyHook(friendshipManager.AcceptInvitations)
+	assert.NoError(t, userManager.CreateNewUser(ctx, user.Users{Email: newUser}))
+
+	// Invitations are pending until the new user is verified
+	friend, err := friendshipManager.checkFriendship(users[0], newUser)
+	assert.Nil(t, err)
+	assert.Nil(t, friend)
+
+	_, err = userManager.VerifyUser(ctx, newUser, token)
+	assert.Nil(t, err)
+
+	friend, err = friendshipManager.checkFriendship(users[0], newUser)
+	assert.Nil(t, err)
+	assert.NotNil(t, friend)
+	assert.Equal(t, true, friend.IsFriend)
+
+	subscriber, err := friendshipManager.checkFriendship(users[1], newUser)
+	assert.Nil(t, err)
+	assert.NotNil(t, subscriber)
+	assert.Equal(t, false, subscriber.IsFriend)
+	assert.Equal(t, true, subscriber.follows(users[1]))
+	assert.Equal(t, false, subscriber.follows(newUser))
+
+	var pending int64
+	assert.NoError(t, tx.Model(&Invitation{}).Where("email = ?", newUser).Count(&pending).Error)
+	assert.Equal(t, int64(0), pending)
+}
+
 func TestGetUserFriendList(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 
@@ -69,12 +183,14 @@ func TestGetUserFriendList(t *testing.T) {
 	assert.Equal(t, numUsers, len(users))
 
 	friendshipManager := NewFriendshipManager(tx)
-	for i := 1; i < numUsers; i++ {
-		assert.NoError(t, friendshipManager.MakeFriend(FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[i]}))
+	for i := 1; i < numUsers-1; i++ {
+		assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[i]}))
 	}
+	// Subscriber is not a friend
+	assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[numUsers-1], TargetEmail: users[0]}))
 
 	expectedListUsers := []string{}
-	expectedListUsers = append(expectedListUsers, users[1:]...)
+	expectedListUsers = append(expectedListUsers, users[1:numUsers-1]...)
 
 	testCase := []struct {
 		scenario       string
@@ -98,7 +214,7 @@ func TestGetUserFriendList(t *testing.T) {
 
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
-			actualRs, err := friendshipManager.GetFriendsList(tc.mockInput)
+			actualRs, err := friendshipManager.GetFriendsList(ctx, tc.mockInput, "")
 			if tc.scenario == "Success" {
 				assert.Nil(t, err)
 				assert.Nil(t, difference(tc.expectedResult, actualRs))
@@ -111,6 +227,7 @@ func TestGetUserFriendList(t *testing.T) {
 }
 
 func TestGetMutualFriendsList(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 
@@ -124,8 +241,8 @@ func TestGetMutualFriendsList(t *testing.T) {
 
 	friendshipManager := NewFriendshipManager(tx)
 	for i := 2; i < numUsers; i++ {
-		assert.NoError(t, friendshipManager.MakeFriend(FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: users[i]}))
-		assert.NoError(t, friendshipManager.MakeFriend(FrienshipServiceInput{RequestEmail: secondUser, TargetEmail: users[i]}))
+		assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: firstUser, TargetEmail: users[i]}))
+		assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: secondUser, TargetEmail: users[i]}))
 	}
 
 	expectedMutualFriendsList := []string{}
@@ -159,7 +276,7 @@ func TestGetMutualFriendsList(t *testing.T) {
 
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
-			actualRs, err := friendshipManager.GetMutualFriendsList(tc.mockInput)
+			actualRs, err := friendshipManager.GetMutualFriendsList(ctx, tc.mockInput, "")
 			if tc.scenario == "Success" {
 				assert.Nil(t, err)
 				assert.Nil(t, difference(tc.expectedResult, actualRs))
@@ -172,6 +289,7 @@ func TestGetMutualFriendsList(t *testing.T) {
 }
 
 func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 
@@ -216,9 +334,9 @@ func TestSubscribe(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			if tc.scenario == "Success in case both is friends" {
-				assert.NoError(t, friendshipManager.MakeFriend(tc.mockInput))
+				assert.NoError(t, friendshipManager.MakeFriend(ctx, tc.mockInput))
 			}
-			err := friendshipManager.Subscribe(tc.mockInput)
+			err := friendshipManager.Subscribe(ctx, tc.mockInput)
 			if tc.scenario == "User not exist" {
 				assert.Equal(t, tc.expectedError, err)
 			} else {
@@ -229,6 +347,7 @@ func TestSubscribe(t *testing.T) {
 }
 
 func TestBlock(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 
@@ -276,9 +395,9 @@ func TestBlock(t *testing.T) {
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
 			if tc.scenario == "Success in case both is friends" {
-				assert.NoError(t, friendshipManager.MakeFriend(tc.mockInput))
+				assert.NoError(t, friendshipManager.MakeFriend(ctx, tc.mockInput))
 			}
-			err := friendshipManager.Block(tc.mockInput)
+			err := friendshipManager.Block(ctx, tc.mockInput)
 			if tc.scenario == "User not exist" {
 				assert.Equal(t, tc.expectedError, err)
 			} else {
@@ -288,8 +407,728 @@ func TestBlock(t *testing.T) {
 	}
 }
 
+func TestImportContacts(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 5
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	owner := users[0]
+	friendshipManager := NewFriendshipManager(tx)
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: users[1]}))
+	assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: users[2]}))
+	assert.NoError(t, user.NewUserManager(tx).UpdatePrivacySetting(ctx, user.PrivacySetting{
+		Email:         users[4],
+		FriendList:    user.VisibilityEveryone,
+		MutualFriends: user.VisibilityEveryone,
+		FriendRequest: user.FriendRequestNobody,
+	}))
+
+	notRegistered := randomData.Email()
+	contacts := []string{users[1], users[2], users[3], users[4], notRegistered}
+
+	// Report only
+	actualRs, err := friendshipManager.ImportContacts(ctx, owner, contacts, ContactActionNone)
+	assert.Nil(t, err)
+	assert.Equal(t, []ContactStatus{
+		{Email: users[1], Registered: true, Friend: true},
+		{Email: users[2], Registered: true, Blocked: true},
+		{Email: users[3], Registered: true},
+		{Email: users[4], Registered: true},
+		{Email: notRegistered},
+	}, actualRs)
+
+	// Make friend in bulk
+	actualRs, err = friendshipManager.ImportContacts(ctx, owner, contacts, ContactActionMakeFriend)
+	assert.Nil(t, err)
+	assert.Equal(t, []ContactStatus{
+		{Email: users[1], Registered: true, Friend: true, Status: ContactSkipped},
+		{Email: users[2], Registered: true, Blocked: true, Status: ContactSkipped},
+		{Email: users[3], Registered: true, Status: ContactConnected},
+		{Email: users[4], Registered: true, Status: ContactRestricted},
+		{Email: notRegistered, Status: ContactInvited},
+	}, actualRs)
+
+	// Blocked contact is hidden from friends list, restricted contact is not connected
+	friendsList, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: owner}, owner)
+	assert.Nil(t, err)
+	assert.Nil(t, difference([]string{users[1], users[3]}, friendsList))
+
+	_, err = friendshipManager.ImportContacts(ctx, owner, contacts, "unknown")
+	assert.Equal(t, errors.New("Import Action Invalid"), err)
+
+	_, err = friendshipManager.ImportContacts(ctx, "usernotexist@notfound.com", contacts, ContactActionNone)
+	assert.Equal(t, errors.New("User Not Exist"), err)
+}
+
+func TestUnfriend(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 2
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	friendshipManager := NewFriendshipManager(tx)
+	input := FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}
+
+	assert.Equal(t, errors.New("Friendship Not Exist"), friendshipManager.Unfriend(ctx, input))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, input))
+	assert.NoError(t, friendshipManager.Unfriend(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[0]}))
+
+	friendship, err := friendshipManager.checkFriendship(users[0], users[1])
+	assert.Nil(t, err)
+	assert.Nil(t, friendship)
+
+	// Make friend again after unfriend
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, input))
+}
+
+func TestExecuteBatch(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 3
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	friendshipManager := NewFriendshipManager(tx)
+
+	operations := []BatchOperation{
+		{Operation: OperationMakeFriend, Input: FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}},
+		{Operation: OperationMakeFriend, Input: FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}},
+		{Operation: OperationSubscribe, Input: FrienshipServiceInput{RequestEmail: users[2], TargetEmail: users[0]}},
+	}
+
+	// Atomic mode roll back all operations when the duplicate make friend failed
+	actualRs, err := friendshipManager.ExecuteBatch(ctx, operations, true)
+	assert.Equal(t, ErrBatchRolledBack, err)
+	assert.Equal(t, "Rolled Back", actualRs[0].Error)
+	assert.Equal(t, "Friendship was exist", actualRs[1].Error)
+	assert.Equal(t, "Not Executed", actualRs[2].Error)
+
+	friendship, err := friendshipManager.checkFriendship(users[0], users[1])
+	assert.Nil(t, err)
+	assert.Nil(t, friendship)
+
+	// Non atomic mode keep the successful operations
+	actualRs, err = friendshipManager.ExecuteBatch(ctx, operations, false)
+	assert.Nil(t, err)
+	assert.Equal(t, true, actualRs[0].Success)
+	assert.Equal(t, false, actualRs[1].Success)
+	assert.Equal(t, "Friendship was exist", actualRs[1].Error)
+	assert.Equal(t, true, actualRs[2].Success)
+
+	friendship, err = friendshipManager.checkFriendship(users[0], users[1])
+	assert.Nil(t, err)
+	assert.NotNil(t, friendship)
+
+	// Invitation recorded by an operation is rolled back with the batch, and kept when the batch succeed
+	notRegistered := "notregistered." + users[2]
+	operations = []BatchOperation{
+		{Operation: OperationSubscribe, Input: FrienshipServiceInput{RequestEmail: users[2], TargetEmail: notRegistered}},
+		{Operation: OperationUnfriend, Input: FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[2]}},
+	}
+	actualRs, err = friendshipManager.ExecuteBatch(ctx, operations, true)
+	assert.Equal(t, ErrBatchRolledBack, err)
+	assert.Equal(t, "Friendship Not Exist", actualRs[1].Error)
+
+	var count int64
+	tx.Model(&Invitation{}).Where("email = ?", notRegistered).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Failed unfriend does not leave the row it locked
+	friendship, err = friendshipManager.checkFriendship(users[1], users[2])
+	assert.Nil(t, err)
+	assert.Nil(t, friendship)
+
+	actualRs, err = friendshipManager.ExecuteBatch(ctx, operations[:1], true)
+	assert.Nil(t, err)
+	assert.Equal(t, true, actualRs[0].Invited)
+	tx.Model(&Invitation{}).Where("email = ?", notRegistered).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestMute(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 3
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	sender := users[0]
+	friendshipManager := NewFriendshipManager(tx)
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: sender}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[2], TargetEmail: sender}))
+
+	muteInput := FrienshipServiceInput{RequestEmail: users[1], TargetEmail: sender}
+	assert.NoError(t, friendshipManager.Mute(ctx, muteInput))
+	assert.NoError(t, friendshipManager.Mute(ctx, muteInput))
+
+	// Muted user is still a friend but its updates are suppressed, even when it mentions the muter
+	friendsList, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: users[1]}, users[1])
+	assert.Nil(t, err)
+	assert.Nil(t, difference([]string{sender}, friendsList))
+
+	actualRs, err := friendshipManager.GetUsersReceiveUpdate(ctx, sender, []string{users[1]}, "")
+	assert.Nil(t, err)
+	assert.Nil(t, difference([]string{users[2]}, actualRs))
+
+	// Mute does not prevent friendship creation
+	assert.NoError(t, friendshipManager.Mute(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[2]}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[2]}))
+
+	assert.NoError(t, friendshipManager.Unmute(ctx, muteInput))
+	assert.Equal(t, errors.New("Mute Not Exist"), friendshipManager.Unmute(ctx, muteInput))
+
+	actualRs, err = friendshipManager.GetUsersReceiveUpdate(ctx, sender, []string{}, "")
+	assert.Nil(t, err)
+	assert.Nil(t, difference([]string{users[1], users[2]}, actualRs))
+
+	assert.Equal(t, errors.New("User Not Exist"), friendshipManager.Mute(ctx, FrienshipServiceInput{RequestEmail: "usernotexist@notfound.com", TargetEmail: sender}))
+}
+
+func TestGetRelationship(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 4
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	friendshipManager := NewFriendshipManager(tx)
+
+	// No connection
+	relationship, err := friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}, users[0])
+	assert.Nil(t, err)
+	assert.Equal(t, Relationship{User: users[0], Other: users[1]}, relationship)
+
+	// Only one of the pair reads the relationship
+	for _, viewer := range []string{users[2], ""} {
+		_, err = friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}, viewer)
+		assert.Equal(t, ErrPrivacyRestricted, err)
+	}
+
+	// Friends follow each other, seen from both sides
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	for _, input := range []FrienshipServiceInput{{RequestEmail: users[0], TargetEmail: users[1]}, {RequestEmail: users[1], TargetEmail: users[0]}} {
+		relationship, err = friendshipManager.GetRelationship(ctx, input, input.TargetEmail)
+		assert.Nil(t, err)
+		assert.Equal(t, true, relationship.AreFriends)
+		assert.Equal(t, true, relationship.UserFollowsOther)
+		assert.Equal(t, true, relationship.OtherFollowsUser)
+		assert.NotNil(t, relationship.ConnectedSince)
+	}
+
+	// Subscription is one way
+	assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[2], TargetEmail: users[0]}))
+	relationship, err = friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}, users[0])
+	assert.Nil(t, err)
+	assert.Equal(t, false, relationship.AreFriends)
+	assert.Equal(t, false, relationship.UserFollowsOther)
+	assert.Equal(t, true, relationship.OtherFollowsUser)
+
+	// Block is direction aware and stops updates both ways
+	assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}))
+	relationship, err = friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: users[2], TargetEmail: users[0]}, users[2])
+	assert.Nil(t, err)
+	assert.Equal(t, false, relationship.UserBlocksOther)
+	assert.Equal(t, true, relationship.OtherBlocksUser)
+	assert.Equal(t, false, relationship.UserFollowsOther)
+	assert.Equal(t, false, relationship.OtherFollowsUser)
+
+	// Friend request to an unregistered user is pending
+	unregistered := "relationship_pending@notfound.com"
+	assert.Equal(t, ErrInvitationPending, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[3], TargetEmail: unregistered}))
+	relationship, err = friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: users[3], TargetEmail: unregistered}, users[3])
+	assert.Nil(t, err)
+	assert.Equal(t, true, relationship.PendingRequest)
+	assert.Equal(t, users[3], relationship.PendingRequestFrom)
+
+	_, err = friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: unregistered, TargetEmail: users[3]}, users[3])
+	assert.Equal(t, errors.New("User Not Exist"), err)
+}
+
+func TestGetRelationshipVersions(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+	defer tx.Rollback()
+
+	const numUsers int = 3
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+
+	friendshipManager := NewFriendshipManager(tx)
+
+	versions, err := friendshipManager.GetRelationshipVersions(ctx, users)
+	assert.NoError(t, err)
+	assert.Equal(t, numUsers, len(versions))
+	before := map[string]int64{}
+	for i, version := range versions {
+		assert.Equal(t, users[i], version.Email)
+		before[version.Email] = version.Version
+	}
+
+	// Write to a friendship bumps the version of both users only
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	versions, err = friendshipManager.GetRelationshipVersions(ctx, users)
+	assert.NoError(t, err)
+	assert.Greater(t, versions[0].Version, before[users[0]])
+	assert.Greater(t, versions[1].Version, before[users[1]])
+	assert.Equal(t, before[users[2]], versions[2].Version)
+	assert.False(t, versions[0].UpdatedAt.IsZero())
+
+	// Privacy setting changes who can see the friends
+	userManager := user.NewUserManager(tx)
+	setting := user.DefaultPrivacySetting(users[2])
+	setting.FriendList = user.VisibilityOnlyMe
+	assert.NoError(t, userManager.UpdatePrivacySetting(ctx, setting))
+	versions, err = friendshipManager.GetRelationshipVersions(ctx, users[2:])
+	assert.NoError(t, err)
+	assert.Greater(t, versions[0].Version, before[users[2]])
+}
+
+// TestBlockVisibility check a blocked pair is hidden from each other in every read, for all block combinations
+func TestBlockVisibility(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	friendshipManager := NewFriendshipManager(tx)
+	userManager := user.NewUserManager(tx)
+
+	testCase := []struct {
+		scenario          string
+		firstBlocksSecond bool
+		secondBlocksFirst bool
+	}{
+		{
+			scenario:          "Case 1: both users block together",
+			firstBlocksSecond: true,
+			secondBlocksFirst: true,
+		},
+		{
+			scenario:          "Case 2: second user block first user",
+			secondBlocksFirst: true,
+		},
+		{
+			scenario:          "Case 3: first user block second user",
+			firstBlocksSecond: true,
+		},
+		{
+			scenario: "Case 4: both users subscribe together",
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			users, ok := insertUsersTest(tx, 3)
+			assert.Equal(t, true, ok)
+			first, second, common := users[0], users[1], users[2]
+
+			// Friends and subscribe together, then apply blocks
+			assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: first, TargetEmail: second}))
+			assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: first, TargetEmail: second}))
+			assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: first, TargetEmail: common}))
+			assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: second, TargetEmail: common}))
+			if tc.secondBlocksFirst {
+				assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: second, TargetEmail: first}))
+			}
+			if tc.firstBlocksSecond {
+				assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: first, TargetEmail: second}))
+			}
+
+			friendship, err := friendshipManager.checkFriendship(first, second)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.firstBlocksSecond, friendship.blocks(first))
+			assert.Equal(t, tc.secondBlocksFirst, friendship.blocks(second))
+
+			visible := tc.firstBlocksSecond == false && tc.secondBlocksFirst == false
+			assert.Equal(t, visible, friendship.IsFriend)
+
+			for _, pair := range [][]string{{first, second}, {second, first}} {
+				viewer, other := pair[0], pair[1]
+
+				friendsList, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: viewer}, viewer)
+				assert.Nil(t, err)
+				assert.Equal(t, visible, contains(friendsList, other))
+
+				mutualFriends, err := friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: viewer, TargetEmail: common}, viewer)
+				assert.Nil(t, err)
+				assert.Equal(t, visible, contains(mutualFriends, other))
+
+				mutualFriends, err = friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: viewer, TargetEmail: other}, viewer)
+				assert.Nil(t, err)
+				assert.Equal(t, visible, contains(mutualFriends, common))
+
+				listUsers, err := userManager.GetListUser(ctx, viewer)
+				assert.Nil(t, err)
+				assert.Equal(t, visible, contains(listUsers, other))
+
+				recipients, err := friendshipManager.GetUsersReceiveUpdate(ctx, viewer, []string{other}, "")
+				assert.Nil(t, err)
+				assert.Equal(t, visible, contains(recipients, other))
+			}
+		})
+	}
+}
+
+// friendshipState is the stored state of a pair, nil state means no friendship row
+type friendshipState struct {
+	isFriend           bool
+	firstFollowsSecond bool
+	secondFollowsFirst bool
+	firstBlocksSecond  bool
+	secondBlocksFirst  bool
+}
+
+// TestFriendshipStateTransitions apply every operation by both users on every state of a pair,
+// then check the stored flags and the delivery of updates in both directions
+func TestFriendshipStateTransitions(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	friendshipManager := NewFriendshipManager(tx)
+
+	states := []*friendshipState{nil}
+	for bits := 0; bits < 32; bits++ {
+		states = append(states, &friendshipState{
+			isFriend:           bits&1 != 0,
+			firstFollowsSecond: bits&2 != 0,
+			secondFollowsFirst: bits&4 != 0,
+			firstBlocksSecond:  bits&8 != 0,
+			secondBlocksFirst:  bits&16 != 0,
+		})
+	}
+
+	operations := []string{OperationSubscribe, OperationBlock, OperationMakeFriend}
+
+	for _, start := range states {
+		for _, operation := range operations {
+			for _, byFirst := range []bool{true, false} {
+				scenario := fmt.Sprintf("%s by first %v from %+v", operation, byFirst, start)
+				t.Run(scenario, func(t *testing.T) {
+					users, ok := insertUsersTest(tx, 2)
+					assert.Equal(t, true, ok)
+					first, second := canonicalPair(users[0], users[1])
+
+					expected := friendshipState{}
+					if start != nil {
+						expected = *start
+						assert.NoError(t, tx.Create(&Friendship{
+							FirstUser:          first,
+							SecondUser:         second,
+							IsFriend:           start.isFriend,
+							FirstFollowsSecond: start.firstFollowsSecond,
+							SecondFollowsFirst: start.secondFollowsFirst,
+							FirstBlocksSecond:  start.firstBlocksSecond,
+							SecondBlocksFirst:  start.secondBlocksFirst,
+						}).Error)
+					}
+
+					input := FrienshipServiceInput{RequestEmail: first, TargetEmail: second}
+					if byFirst == false {
+						input = FrienshipServiceInput{RequestEmail: second, TargetEmail: first}
+					}
+					blocked := expected.firstBlocksSecond || expected.secondBlocksFirst
+
+					var expectedError error
+					switch operation {
+					case OperationSubscribe:
+						if byFirst {
+							expected.firstFollowsSecond = true
+						} else {
+							expected.secondFollowsFirst = true
+						}
+					case OperationBlock:
+						expected.isFriend = false
+						if byFirst {
+							expected.firstBlocksSecond = true
+							expected.firstFollowsSecond = false
+						} else {
+							expected.secondBlocksFirst = true
+							expected.secondFollowsFirst = false
+						}
+					case OperationMakeFriend:
+						if expected.isFriend {
+							expectedError = errors.New("Friendship was exist")
+						} else if blocked {
+							expectedError = errors.New("Blocked Add Friend")
+						} else {
+							expected = friendshipState{isFriend: true, firstFollowsSecond: true, secondFollowsFirst: true}
+						}
+					}
+
+					var err error
+					switch operation {
+					case OperationSubscribe:
+						err = friendshipManager.Subscribe(ctx, input)
+					case OperationBlock:
+						err = friendshipManager.Block(ctx, input)
+					case OperationMakeFriend:
+						err = friendshipManager.MakeFriend(ctx, input)
+					}
+					assert.Equal(t, expectedError, err)
+
+					friendship, err := friendshipManager.checkFriendship(first, second)
+					assert.Nil(t, err)
+					assert.NotNil(t, friendship)
+					if friendship == nil {
+						return
+					}
+					// Row is kept in canonical order
+					assert.Equal(t, first, friendship.FirstUser)
+					assert.Equal(t, expected, friendshipState{
+						isFriend:           friendship.IsFriend,
+						firstFollowsSecond: friendship.FirstFollowsSecond,
+						secondFollowsFirst: friendship.SecondFollowsFirst,
+						firstBlocksSecond:  friendship.FirstBlocksSecond,
+						secondBlocksFirst:  friendship.SecondBlocksFirst,
+					})
+
+					// Nothing is delivered in either direction of a blocked pair
+					blocked = expected.firstBlocksSecond || expected.secondBlocksFirst
+
+					recipients, err := friendshipManager.GetUsersReceiveUpdate(ctx, first, []string{}, "")
+					assert.Nil(t, err)
+					assert.Equal(t, expected.secondFollowsFirst && !blocked, contains(recipients, second))
+
+					recipients, err = friendshipManager.GetUsersReceiveUpdate(ctx, second, []string{}, "")
+					assert.Nil(t, err)
+					assert.Equal(t, expected.firstFollowsSecond && !blocked, contains(recipients, first))
+				})
+			}
+		}
+	}
+}
+
+// TestConcurrentFriendshipWrites hammer the same pair from both users at once,
+// it runs outside of a test transaction so the writers really race
+func TestConcurrentFriendshipWrites(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+
+	users, ok := insertUsersTest(dbconn, 2)
+	assert.Equal(t, true, ok)
+	defer func() {
+		dbconn.Unscoped().Where("first_user IN ? OR second_user IN ?", users, users).Delete(&Friendship{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.VerificationToken{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.Users{})
+	}()
+
+	const numWriters int = 40
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	madeFriend := 0
+	errs := []error{}
+
+	for i := 0; i < numWriters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			friendshipManager := NewFriendshipManager(dbconn)
+			input := FrienshipServiceInput{RequestEmail: users[i%2], TargetEmail: users[(i+1)%2]}
+
+			var err error
+			if i%4 < 2 {
+				err = friendshipManager.MakeFriend(ctx, input)
+			} else {
+				err = friendshipManager.Subscribe(ctx, input)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if i%4 < 2 && err == nil {
+				madeFriend++
+			} else if err != nil && err.Error() != "Friendship was exist" {
+				errs = append(errs, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Empty(t, errs)
+	assert.Equal(t, 1, madeFriend)
+
+	var count int64
+	assert.NoError(t, dbconn.Model(&Friendship{}).Where("first_user IN ? AND second_user IN ?", users, users).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	friendship, err := NewFriendshipManager(dbconn).checkFriendship(users[0], users[1])
+	assert.Nil(t, err)
+	assert.NotNil(t, friendship)
+	if friendship == nil {
+		return
+	}
+
+	first, second := canonicalPair(users[0], users[1])
+	assert.Equal(t, first, friendship.FirstUser)
+	assert.Equal(t, second, friendship.SecondUser)
+	assert.Equal(t, true, friendship.IsFriend)
+	assert.Equal(t, true, friendship.FirstFollowsSecond)
+	assert.Equal(t, true, friendship.SecondFollowsFirst)
+	assert.Equal(t, false, friendship.blocked())
+}
+
+func TestPrivacySettings(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 4
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	owner, friend, friendOfFriend, stranger := users[0], users[1], users[2], users[3]
+
+	friendshipManager := NewFriendshipManager(tx)
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: friend}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: friend, TargetEmail: friendOfFriend}))
+
+	userManager := user.NewUserManager(tx)
+	assert.NoError(t, userManager.UpdatePrivacySetting(ctx, user.PrivacySetting{
+		Email:                   owner,
+		FriendList:              user.VisibilityFriends,
+		MutualFriends:           user.VisibilityOnlyMe,
+		FriendRequest:           user.FriendRequestFriendsOfFriends,
+		NotifyNonFriendMentions: false,
+	}))
+
+	// Friend list is visible to friends only
+	_, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: owner}, friend)
+	assert.Nil(t, err)
+	_, err = friendshipManager.GetFriendsList(ctx, user.Users{Email: owner}, owner)
+	assert.Nil(t, err)
+	_, err = friendshipManager.GetFriendsList(ctx, user.Users{Email: owner}, stranger)
+	assert.Equal(t, ErrPrivacyRestricted, err)
+	_, err = friendshipManager.GetFriendsList(ctx, user.Users{Email: owner}, "")
+	assert.Equal(t, ErrPrivacyRestricted, err)
+
+	// Mutual friends are visible to owner only
+	_, err = friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: friendOfFriend}, owner)
+	assert.Nil(t, err)
+	_, err = friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: friendOfFriend}, friend)
+	assert.Equal(t, ErrPrivacyRestricted, err)
+
+	// Mention from non friend does not notify owner
+	recipients, err := friendshipManager.GetUsersReceiveUpdate(ctx, stranger, []string{owner}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, false, contains(recipients, owner))
+	recipients, err = friendshipManager.GetUsersReceiveUpdate(ctx, friend, []string{owner}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, true, contains(recipients, owner))
+
+	// Friend request is accepted from friends of friends only, a subscription to a friend is not a friendship
+	assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: stranger, TargetEmail: friend}))
+	assert.Equal(t, ErrPrivacyRestricted, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: stranger, TargetEmail: owner}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: friendOfFriend, TargetEmail: owner}))
+
+	assert.NoError(t, userManager.UpdatePrivacySetting(ctx, user.PrivacySetting{
+		Email:                   owner,
+		FriendList:              user.VisibilityEveryone,
+		MutualFriends:           user.VisibilityEveryone,
+		FriendRequest:           user.FriendRequestNobody,
+		NotifyNonFriendMentions: true,
+	}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: friend, TargetEmail: stranger}))
+	assert.Equal(t, ErrPrivacyRestricted, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: stranger, TargetEmail: owner}))
+
+	// Mutual friends are never more visible than the friend list
+	assert.NoError(t, userManager.UpdatePrivacySetting(ctx, user.PrivacySetting{
+		Email:         owner,
+		FriendList:    user.VisibilityOnlyMe,
+		MutualFriends: user.VisibilityEveryone,
+		FriendRequest: user.FriendRequestEveryone,
+	}))
+	_, err = friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: stranger}, stranger)
+	assert.Equal(t, ErrPrivacyRestricted, err)
+	_, err = friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: owner, TargetEmail: stranger}, owner)
+	assert.Nil(t, err)
+}
+
+func TestBatchReads(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	const numUsers int = 5
+	users, ok := insertUsersTest(tx, numUsers)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, numUsers, len(users))
+
+	friendshipManager := NewFriendshipManager(tx)
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[2]}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[2], TargetEmail: users[4]}))
+	assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[3], TargetEmail: users[0]}))
+	assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[4]}))
+	assert.NoError(t, user.NewUserManager(tx).UpdatePrivacySetting(ctx, user.PrivacySetting{
+		Email:         users[1],
+		FriendList:    user.VisibilityFriends,
+		MutualFriends: user.VisibilityOnlyMe,
+		FriendRequest: user.FriendRequestEveryone,
+	}))
+
+	// Batch reads answer the same as one read by user
+	unregistered := "batch_reads@notfound.com"
+	emails := append(append([]string{}, users...), unregistered)
+	for _, viewer := range []string{users[0], users[3], ""} {
+		listFriends, err := friendshipManager.GetFriendsLists(ctx, emails, viewer)
+		assert.Nil(t, err)
+		assert.Equal(t, len(emails), len(listFriends))
+		for i, email := range emails {
+			friends, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: email}, viewer)
+			assert.Equal(t, email, listFriends[i].Email)
+			assert.Equal(t, err, listFriends[i].Err)
+			assert.ElementsMatch(t, friends, listFriends[i].Friends)
+		}
+
+		listMutualFriends, err := friendshipManager.GetMutualFriendsLists(ctx, users[0], emails[1:], viewer)
+		assert.Nil(t, err)
+		for i, email := range emails[1:] {
+			mutualFriends, err := friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: email}, viewer)
+			assert.Equal(t, err, listMutualFriends[i].Err)
+			assert.ElementsMatch(t, mutualFriends, listMutualFriends[i].Friends)
+		}
+	}
+
+	for _, viewer := range []string{users[0], users[3], ""} {
+		listRelationships, err := friendshipManager.GetRelationships(ctx, users[0], emails[1:], viewer)
+		assert.Nil(t, err)
+		for i, email := range emails[1:] {
+			relationship, err := friendshipManager.GetRelationship(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: email}, viewer)
+			assert.Equal(t, err, listRelationships[i].Err)
+			if err == nil {
+				assert.Equal(t, relationship, listRelationships[i])
+			}
+		}
+	}
+
+	_, err := friendshipManager.GetRelationships(ctx, unregistered, users, unregistered)
+	assert.Equal(t, errors.New("User Not Exist"), err)
+}
+
 // ==================================== BEGIN TEST GetUsersReceiveUpdate FUNC =================================
 func TestGetUsersReceiveUpdate(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 	tx.SavePoint("sp1")
@@ -322,12 +1161,12 @@ func TestGetUsersReceiveUpdate(t *testing.T) {
 
 	// Make Friend
 	for i := 0; i < numUsersMakeFriend; i++ {
-		assert.NoError(t, friendshipManager.MakeFriend(FrienshipServiceInput{RequestEmail: usersWillMakeFriend[i], TargetEmail: sender[0]}))
+		assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: usersWillMakeFriend[i], TargetEmail: sender[0]}))
 	}
 
 	// Subscribe
 	for i := 0; i < numUsersSubscribe; i++ {
-		assert.NoError(t, friendshipManager.Subscribe(FrienshipServiceInput{RequestEmail: usersSubscribe[i], TargetEmail: sender[0]}))
+		assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: usersSubscribe[i], TargetEmail: sender[0]}))
 	}
 
 	// Expected result
@@ -361,7 +1200,7 @@ func TestGetUsersReceiveUpdate(t *testing.T) {
 
 	for _, tc := range testCase {
 		t.Run(tc.scenario, func(t *testing.T) {
-			actualRs, err := friendshipManager.GetUsersReceiveUpdate(tc.mockSenderInput, tc.mockMentionedUserInput)
+			actualRs, err := friendshipManager.GetUsersReceiveUpdate(ctx, tc.mockSenderInput, tc.mockMentionedUserInput, "")
 			if tc.scenario == "Success" {
 				assert.Nil(t, err)
 				assert.Nil(t, difference(tc.expectedResult, actualRs))
@@ -375,14 +1214,19 @@ func TestGetUsersReceiveUpdate(t *testing.T) {
 
 // InsertUsersTest
 func insertUsersTest(tx *gorm.DB, numsUser int) ([]string, bool) {
+	ctx := context.Background()
 	listUsers := []string{}
 	userManager := user.NewUserManager(tx)
 	for i := 0; i < numsUser; i++ {
 		email := randomData.Email()
-		err := userManager.CreateNewUser(user.Users{Email: email})
+		err := userManager.CreateNewUser(ctx, user.Users{Email: email})
 		if err != nil {
 			return nil, false
 		}
+		rs := tx.Model(&user.Users{}).Where("email = ?", email).Update("verified", true)
+		if rs.Error != nil {
+			return nil, false
+		}
 		listUsers = append(listUsers, email)
 	}
 	return listUsers, true
@@ -415,3 +1259,62 @@ func difference(slice1 []string, slice2 []string) []string {
 
 	return diff
 }
+
+func TestFriendshipCache(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+
+	// Cache is filled and invalidated when writes are committed, so the users are not created in a test transaction
+	users, ok := insertUsersTest(dbconn, 3)
+	assert.Equal(t, true, ok)
+	defer func() {
+		dbconn.Unscoped().Where("first_user IN ? OR second_user IN ?", users, users).Delete(&Friendship{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.VerificationToken{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.Users{})
+		dbconn.Where("email IN ?", users).Delete(&RelationshipVersion{})
+	}()
+
+	lru := cache.NewLRU(100)
+	friendshipManager := NewFriendshipManager(dbconn).WithCache(lru, time.Minute)
+	cached := func(name string, email string) bool {
+		_, ok := lru.Get(ctx, cacheKey(name, email))
+		return ok
+	}
+
+	friends, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: users[0]}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(friends))
+	assert.True(t, cached(cacheFriends, users[0]))
+	assert.True(t, cached(cacheUsers, users[0]))
+
+	// MakeFriend invalidates the lists of both users
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	assert.False(t, cached(cacheFriends, users[0]))
+	friends, err = friendshipManager.GetFriendsList(ctx, user.Users{Email: users[0]}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{users[1]}, friends)
+
+	// Subscribe invalidates the followers of the target
+	recipients, err := friendshipManager.GetUsersReceiveUpdate(ctx, users[0], []string{}, "")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{users[1]}, recipients)
+	assert.True(t, cached(cacheFollowers, users[0]))
+
+	assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[2], TargetEmail: users[0]}))
+	recipients, err = friendshipManager.GetUsersReceiveUpdate(ctx, users[0], []string{}, "")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{users[1], users[2]}, recipients)
+
+	// Block invalidates the followers of the blocker
+	assert.NoError(t, friendshipManager.Block(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}))
+	recipients, err = friendshipManager.GetUsersReceiveUpdate(ctx, users[0], []string{}, "")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{users[1]}, recipients)
+
+	// List read before a write committed meanwhile is not cached
+	key := cacheKey(cacheFriends, users[2])
+	generation := friendshipManager.cacheGeneration(key)
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[2]}))
+	friendshipManager.cacheSet(key, generation, []string{})
+	assert.False(t, cached(cacheFriends, users[2]))
+}
@@ -0,0 +1,155 @@
+package friendship
+
+import (
+	"context"
+
+	"friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FriendshipTracing trace each method of a friendship service, the spans of its queries are children of the method span
+type FriendshipTracing struct {
+	next FrienshipServices
+}
+
+// NewFriendshipTracing initializes friendship service tracing the methods of next
+func NewFriendshipTracing(next FrienshipServices) *FriendshipTracing {
+	return &FriendshipTracing{
+		next: next,
+	}
+}
+
+func inputAttributes(input FrienshipServiceInput) []attribute.KeyValue {
+	return []attribute.KeyValue{tracing.Email("friendship.requestor", input.RequestEmail), tracing.Email("friendship.target", input.TargetEmail)}
+}
+
+// endSpan end span of an operation recording an invitation, it is not an error
+func endSpan(span trace.Span, err error) {
+	if err == ErrInvitationPending {
+		span.SetAttributes(attribute.Bool("friendship.invited", true))
+		err = nil
+	}
+	tracing.EndSpan(span, err)
+}
+
+func (t *FriendshipTracing) MakeFriend(ctx context.Context, input FrienshipServiceInput) error {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.MakeFriend", inputAttributes(input)...)
+	err := t.next.MakeFriend(ctx, input)
+	endSpan(span, err)
+	return err
+}
+
+func (t *FriendshipTracing) GetFriendsList(ctx context.Context, ur user.Users, viewer string) ([]string, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetFriendsList", tracing.Email("friendship.user", ur.Email), tracing.Email("friendship.viewer", viewer))
+	rs, err := t.next.GetFriendsList(ctx, ur, viewer)
+	span.SetAttributes(attribute.Int("friendship.count", len(rs)))
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) GetMutualFriendsList(ctx context.Context, input FrienshipServiceInput, viewer string) ([]string, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetMutualFriendsList", append(inputAttributes(input), tracing.Email("friendship.viewer", viewer))...)
+	rs, err := t.next.GetMutualFriendsList(ctx, input, viewer)
+	span.SetAttributes(attribute.Int("friendship.count", len(rs)))
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) Subscribe(ctx context.Context, input FrienshipServiceInput) error {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.Subscribe", inputAttributes(input)...)
+	err := t.next.Subscribe(ctx, input)
+	endSpan(span, err)
+	return err
+}
+
+func (t *FriendshipTracing) Block(ctx context.Context, input FrienshipServiceInput) error {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.Block", inputAttributes(input)...)
+	err := t.next.Block(ctx, input)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *FriendshipTracing) GetUsersReceiveUpdate(ctx context.Context, sender string, mentionedUsers []string, circle string) ([]string, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetUsersReceiveUpdate", tracing.Email("friendship.sender", sender),
+		attribute.Int("friendship.mentions", len(mentionedUsers)), attribute.Bool("friendship.circle", circle != ""))
+	rs, err := t.next.GetUsersReceiveUpdate(ctx, sender, mentionedUsers, circle)
+	span.SetAttributes(attribute.Int("friendship.recipients", len(rs)))
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) ImportContacts(ctx context.Context, owner string, contacts []string, action string) ([]ContactStatus, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.ImportContacts", tracing.Email("friendship.owner", owner),
+		attribute.Int("friendship.contacts", len(contacts)), attribute.String("friendship.action", action))
+	rs, err := t.next.ImportContacts(ctx, owner, contacts, action)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) Unfriend(ctx context.Context, input FrienshipServiceInput) error {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.Unfriend", inputAttributes(input)...)
+	err := t.next.Unfriend(ctx, input)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *FriendshipTracing) Mute(ctx context.Context, input FrienshipServiceInput) error {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.Mute", inputAttributes(input)...)
+	err := t.next.Mute(ctx, input)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *FriendshipTracing) Unmute(ctx context.Context, input FrienshipServiceInput) error {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.Unmute", inputAttributes(input)...)
+	err := t.next.Unmute(ctx, input)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *FriendshipTracing) ExecuteBatch(ctx context.Context, operations []BatchOperation, atomic bool) ([]BatchResult, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.ExecuteBatch", attribute.Int("friendship.operations", len(operations)), attribute.Bool("friendship.atomic", atomic))
+	rs, err := t.next.ExecuteBatch(ctx, operations, atomic)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) GetRelationship(ctx context.Context, input FrienshipServiceInput, viewer string) (Relationship, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetRelationship", append(inputAttributes(input), tracing.Email("friendship.viewer", viewer))...)
+	rs, err := t.next.GetRelationship(ctx, input, viewer)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) GetRelationshipVersions(ctx context.Context, emails []string) ([]RelationshipVersion, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetRelationshipVersions", attribute.Int("friendship.users", len(emails)))
+	rs, err := t.next.GetRelationshipVersions(ctx, emails)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) GetFriendsLists(ctx context.Context, emails []string, viewer string) ([]FriendsList, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetFriendsLists", attribute.Int("friendship.users", len(emails)), tracing.Email("friendship.viewer", viewer))
+	rs, err := t.next.GetFriendsLists(ctx, emails, viewer)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) GetMutualFriendsLists(ctx context.Context, email string, others []string, viewer string) ([]FriendsList, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetMutualFriendsLists", tracing.Email("friendship.user", email),
+		attribute.Int("friendship.users", len(others)), tracing.Email("friendship.viewer", viewer))
+	rs, err := t.next.GetMutualFriendsLists(ctx, email, others, viewer)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *FriendshipTracing) GetRelationships(ctx context.Context, email string, others []string, viewer string) ([]Relationship, error) {
+	ctx, span := tracing.StartSpan(ctx, "FrienshipServices.GetRelationships", tracing.Email("friendship.user", email),
+		attribute.Int("friendship.users", len(others)), tracing.Email("friendship.viewer", viewer))
+	rs, err := t.next.GetRelationships(ctx, email, others, viewer)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
@@ -0,0 +1,83 @@
+package friendship
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"friend_connection_rest_api/utils/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestFriendshipTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Setup(exporter, true)
+
+	input := FrienshipServiceInput{RequestEmail: "abc@gmail.com", TargetEmail: "xyz@gmail.com"}
+	testCase := []struct {
+		scenario     string
+		mockError    error
+		expectedCode codes.Code
+		invited      bool
+	}{
+		{
+			scenario:     "Success",
+			expectedCode: codes.Unset,
+		},
+		{
+			scenario:     "Invitation Is Not An Error",
+			mockError:    ErrInvitationPending,
+			expectedCode: codes.Unset,
+			invited:      true,
+		},
+		{
+			scenario:     "Error",
+			mockError:    errors.New("Users Are Already Friends"),
+			expectedCode: codes.Error,
+		},
+	}
+
+	for _, tc := range testCase {
+		t.Run(tc.scenario, func(t *testing.T) {
+			exporter.Reset()
+
+			var spanContext oteltrace.SpanContext
+			mockService := new(FrienshipMockService)
+			mockService.On("MakeFriend", mock.Anything, input).Return(tc.mockError).Run(func(args mock.Arguments) {
+				spanContext = oteltrace.SpanContextFromContext(args.Get(0).(context.Context))
+			})
+
+			err := NewFriendshipTracing(mockService).MakeFriend(context.Background(), input)
+			assert.Equal(t, tc.mockError, err)
+
+			spans := exporter.GetSpans()
+			if assert.Len(t, spans, 1) {
+				span := spans[0]
+				assert.Equal(t, "FrienshipServices.MakeFriend", span.Name)
+				assert.Equal(t, tc.expectedCode, span.Status.Code)
+				// The service is called with the context of the span
+				assert.Equal(t, span.SpanContext.SpanID(), spanContext.SpanID())
+				// Emails are redacted
+				for _, attr := range span.Attributes {
+					assert.NotContains(t, attr.Value.Emit(), "@gmail.com")
+				}
+				assert.Equal(t, tc.invited, containsAttribute(span.Attributes, attribute.Bool("friendship.invited", true)))
+			}
+		})
+	}
+}
+
+func containsAttribute(attrs []attribute.KeyValue, attr attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
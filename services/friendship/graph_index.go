@@ -0,0 +1,443 @@
+package friendship
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"friend_connection_rest_api/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	GraphQueryFriends       = "friends"
+	GraphQueryMutualFriends = "mutual_friends"
+	GraphQueryFollowers     = "followers"
+)
+
+// ParseGraphQueries parse the comma separated queries answered by the graph index, e.g. "friends,mutual_friends"
+func ParseGraphQueries(value string) map[string]bool {
+	queries := map[string]bool{}
+	for _, query := range strings.Split(value, ",") {
+		if query = strings.TrimSpace(query); query != "" {
+			queries[query] = true
+		}
+	}
+	return queries
+}
+
+// removedRetention is the time a removed friendship is kept, writes are applied right after their commit
+// so an older write of the pair is not applied later than it
+const removedRetention = time.Minute
+
+// graphPair is the friendship of a pair in the index, a removed friendship is kept so an older write
+// applied late can not restore it. At is the time the write was made in its transaction
+type graphPair struct {
+	friendship Friendship
+	removed    bool
+	at         time.Time
+}
+
+// GraphIndex is an in-process adjacency index of the friendships. Each user has the sorted list of the users
+// it has a friendship with, the same rows the SQL queries read, so friend lists are O(degree)
+// and mutual friends are a merge of two sorted lists.
+// It is kept current by the writes of the FriendshipManager of this process once they are committed,
+// writes of other processes are only seen by Load or Check
+type GraphIndex struct {
+	mutex     sync.RWMutex
+	loaded    bool
+	pairs     map[[2]string]*graphPair
+	neighbors map[string][]string
+	// journal keep the newest write of each pair applied while a load reads the table, it is nil without load
+	journal map[[2]string]*graphPair
+	loads   int
+	// clock is increased by each load and applied write, an user has the clock of its last change
+	// and the users unchanged since the last load have the clock of the load
+	clock    uint64
+	base     uint64
+	versions map[string]uint64
+}
+
+// GraphIndexReport is the difference between the index and the friendships table
+type GraphIndexReport struct {
+	Missing    int `json:"missing"`
+	Extra      int `json:"extra"`
+	Mismatched int `json:"mismatched"`
+}
+
+// Consistent return true when the index has the same friendships as the table
+func (r GraphIndexReport) Consistent() bool {
+	return r.Missing == 0 && r.Extra == 0 && r.Mismatched == 0
+}
+
+// NewGraphIndex initializes an empty index, it answers no query until it is loaded
+func NewGraphIndex() *GraphIndex {
+	return &GraphIndex{
+		pairs:     map[[2]string]*graphPair{},
+		neighbors: map[string][]string{},
+		versions:  map[string]uint64{},
+	}
+}
+
+// Loaded return true once the friendships were loaded
+func (g *GraphIndex) Loaded() bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.loaded
+}
+
+// Load replace the index by the friendships of the table. Writes applied while the table is read are kept
+// when they are newer than the row read, a row keeps the time of the write the index has for its pair
+// so an older write applied late is still ignored
+func (g *GraphIndex) Load(ctx context.Context, dbconn *gorm.DB) error {
+	g.beginLoad()
+	listFriendships := []Friendship{}
+	rs := dbconn.WithContext(ctx).Find(&listFriendships)
+	if rs.Error != nil {
+		g.finishLoad(nil, false)
+		return rs.Error
+	}
+	g.finishLoad(listFriendships, true)
+	return nil
+}
+
+// beginLoad start journaling the applied writes, it is called before the table is read
+func (g *GraphIndex) beginLoad() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.loads == 0 {
+		g.journal = map[[2]string]*graphPair{}
+	}
+	g.loads++
+}
+
+// finishLoad replace the index by the friendships read merged with the journal, the index is kept when ok is false
+func (g *GraphIndex) finishLoad(listFriendships []Friendship, ok bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	journal := g.journal
+	g.loads--
+	if g.loads == 0 {
+		g.journal = nil
+	}
+	if !ok {
+		return
+	}
+
+	pairs := map[[2]string]*graphPair{}
+	for _, friendship := range listFriendships {
+		key := [2]string{friendship.FirstUser, friendship.SecondUser}
+		pair := &graphPair{friendship: friendship}
+		if indexed, ok := g.pairs[key]; ok {
+			pair.at = indexed.at
+		}
+		pairs[key] = pair
+	}
+	for key, write := range journal {
+		if pair, ok := pairs[key]; !ok || !pair.at.After(write.at) {
+			pairs[key] = write
+		}
+	}
+
+	neighbors := map[string][]string{}
+	for key, pair := range pairs {
+		if !pair.removed {
+			neighbors[key[0]] = append(neighbors[key[0]], key[1])
+			neighbors[key[1]] = append(neighbors[key[1]], key[0])
+		}
+	}
+	for _, list := range neighbors {
+		sort.Strings(list)
+	}
+
+	g.pairs = pairs
+	g.neighbors = neighbors
+	g.clock++
+	g.base = g.clock
+	g.versions = map[string]uint64{}
+	g.loaded = true
+}
+
+// Version return the version of an user in the index, it changes with each load and each write of its friendships
+func (g *GraphIndex) Version(email string) uint64 {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if version, ok := g.versions[email]; ok {
+		return version
+	}
+	return g.base
+}
+
+// Set write the friendship of a pair, it is ignored when a newer write of the pair was applied
+func (g *GraphIndex) Set(friendship Friendship, at time.Time) {
+	g.apply(friendship, false, at)
+}
+
+// Remove delete the friendship of a pair, it is ignored when a newer write of the pair was applied
+func (g *GraphIndex) Remove(a string, b string, at time.Time) {
+	first, second := canonicalPair(a, b)
+	g.apply(Friendship{FirstUser: first, SecondUser: second}, true, at)
+}
+
+func (g *GraphIndex) apply(friendship Friendship, removed bool, at time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	key := [2]string{friendship.FirstUser, friendship.SecondUser}
+	write := &graphPair{friendship: friendship, removed: removed, at: at}
+	if g.journal != nil {
+		if journaled, ok := g.journal[key]; !ok || !journaled.at.After(at) {
+			g.journal[key] = write
+		}
+	}
+
+	pair, ok := g.pairs[key]
+	if ok && pair.at.After(at) {
+		return
+	}
+
+	existed := ok && !pair.removed
+	g.pairs[key] = write
+	g.clock++
+	g.versions[key[0]] = g.clock
+	g.versions[key[1]] = g.clock
+
+	if !existed && !removed {
+		g.neighbors[key[0]] = insertSorted(g.neighbors[key[0]], key[1])
+		g.neighbors[key[1]] = insertSorted(g.neighbors[key[1]], key[0])
+	}
+	if existed && removed {
+		g.neighbors[key[0]] = removeSorted(g.neighbors[key[0]], key[1])
+		g.neighbors[key[1]] = removeSorted(g.neighbors[key[1]], key[0])
+	}
+}
+
+// pair return the friendship of a pair, it must be called with the lock held
+func (g *GraphIndex) pair(a string, b string) (*Friendship, bool) {
+	first, second := canonicalPair(a, b)
+	pair, ok := g.pairs[[2]string{first, second}]
+	if !ok || pair.removed {
+		return nil, false
+	}
+	return &pair.friendship, true
+}
+
+// Friends return the friends of email, follow only and blocked pairs excluded, like GetFriendsList
+func (g *GraphIndex) Friends(email string) []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.filterNeighbors(email, func(friendship *Friendship) bool {
+		return friendship.friends()
+	})
+}
+
+// Blocked return the users in a blocked pair with email, whoever blocks
+func (g *GraphIndex) Blocked(email string) []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.filterNeighbors(email, func(friendship *Friendship) bool {
+		return friendship.blocked()
+	})
+}
+
+// Followers return the users following email in a pair which is not blocked
+func (g *GraphIndex) Followers(email string) []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.filterNeighbors(email, func(friendship *Friendship) bool {
+		other := friendship.FirstUser
+		if other == email {
+			other = friendship.SecondUser
+		}
+		return friendship.follows(other) && !friendship.blocked()
+	})
+}
+
+// MutualFriends return the users are friends of both users, none when they block each other,
+// follow only pairs and users in a blocked pair with one of them are excluded, like GetMutualFriendsList
+func (g *GraphIndex) MutualFriends(a string, b string) []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if friendship, ok := g.pair(a, b); ok && friendship.blocked() {
+		return []string{}
+	}
+
+	listA, listB := g.neighbors[a], g.neighbors[b]
+	mutual := []string{}
+	for i, j := 0, 0; i < len(listA) && j < len(listB); {
+		switch {
+		case listA[i] < listB[j]:
+			i++
+		case listA[i] > listB[j]:
+			j++
+		default:
+			other := listA[i]
+			first, _ := g.pair(a, other)
+			second, _ := g.pair(b, other)
+			if first.friends() && second.friends() {
+				mutual = append(mutual, other)
+			}
+			i++
+			j++
+		}
+	}
+	return mutual
+}
+
+// Path return the shortest chain of friends from a to b included, found by a breadth first search of at most
+// maxDepth friendships. It is nil when the users are not connected within maxDepth
+func (g *GraphIndex) Path(a string, b string, maxDepth int) []string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if a == b {
+		return []string{a}
+	}
+
+	parent := map[string]string{a: ""}
+	frontier := []string{a}
+	for depth := 0; depth < maxDepth && len(frontier) > 0; depth++ {
+		next := []string{}
+		for _, current := range frontier {
+			for _, other := range g.neighbors[current] {
+				if _, visited := parent[other]; visited {
+					continue
+				}
+				friendship, _ := g.pair(current, other)
+				if !friendship.friends() {
+					continue
+				}
+
+				parent[other] = current
+				if other == b {
+					path := []string{b}
+					for node := current; node != ""; node = parent[node] {
+						path = append([]string{node}, path...)
+					}
+					return path
+				}
+				next = append(next, other)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// Check compare the index with the friendships table
+func (g *GraphIndex) Check(ctx context.Context, dbconn *gorm.DB) (GraphIndexReport, error) {
+	report := GraphIndexReport{}
+	listFriendships := []Friendship{}
+	rs := dbconn.WithContext(ctx).Find(&listFriendships)
+	if rs.Error != nil {
+		return report, rs.Error
+	}
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	seen := map[[2]string]bool{}
+	for _, friendship := range listFriendships {
+		seen[[2]string{friendship.FirstUser, friendship.SecondUser}] = true
+		indexed, ok := g.pair(friendship.FirstUser, friendship.SecondUser)
+		switch {
+		case !ok:
+			report.Missing++
+		case indexed.IsFriend != friendship.IsFriend ||
+			indexed.FirstFollowsSecond != friendship.FirstFollowsSecond || indexed.SecondFollowsFirst != friendship.SecondFollowsFirst ||
+			indexed.FirstBlocksSecond != friendship.FirstBlocksSecond || indexed.SecondBlocksFirst != friendship.SecondBlocksFirst:
+			report.Mismatched++
+		}
+	}
+
+	for key, pair := range g.pairs {
+		if !pair.removed && !seen[key] {
+			report.Extra++
+		}
+	}
+	return report, nil
+}
+
+// prune remove the removed friendships written before the time
+func (g *GraphIndex) prune(before time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for key, pair := range g.pairs {
+		if pair.removed && pair.at.Before(before) {
+			delete(g.pairs, key)
+		}
+	}
+}
+
+// Verify load the index then check it against the table every interval, it is reloaded when they differ,
+// e.g. after a repair of the consistency check or a write of another process. Removed friendships older than
+// removedRetention are pruned at each check. It returns when ctx is done
+func (g *GraphIndex) Verify(ctx context.Context, dbconn *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		g.prune(time.Now().Add(-removedRetention))
+		if !g.Loaded() {
+			if err := g.Load(ctx, dbconn); err != nil {
+				logger.Default().Warn("load graph index failed", "error", err)
+			}
+		} else if report, err := g.Check(ctx, dbconn); err != nil {
+			logger.Default().Warn("check graph index failed", "error", err)
+		} else if !report.Consistent() {
+			logger.Default().Warn("graph index differs from friendships, reloading it",
+				"missing", report.Missing, "extra", report.Extra, "mismatched", report.Mismatched)
+			if err := g.Load(ctx, dbconn); err != nil {
+				logger.Default().Warn("load graph index failed", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// filterNeighbors return the neighbors of email whose friendship is kept by keep, it must be called with the lock held
+func (g *GraphIndex) filterNeighbors(email string, keep func(friendship *Friendship) bool) []string {
+	list := []string{}
+	for _, other := range g.neighbors[email] {
+		if friendship, ok := g.pair(email, other); ok && keep(friendship) {
+			list = append(list, other)
+		}
+	}
+	return list
+}
+
+func insertSorted(list []string, element string) []string {
+	i := sort.SearchStrings(list, element)
+	if i < len(list) && list[i] == element {
+		return list
+	}
+	list = append(list, "")
+	copy(list[i+1:], list[i:])
+	list[i] = element
+	return list
+}
+
+func removeSorted(list []string, element string) []string {
+	i := sort.SearchStrings(list, element)
+	if i < len(list) && list[i] == element {
+		return append(list[:i], list[i+1:]...)
+	}
+	return list
+}
@@ -0,0 +1,189 @@
+package friendship
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"friend_connection_rest_api/services/user"
+	"friend_connection_rest_api/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGraphIndex(friendships ...Friendship) *GraphIndex {
+	index := NewGraphIndex()
+	index.loaded = true
+	at := time.Now()
+	for _, friendship := range friendships {
+		index.Set(friendship, at)
+	}
+	return index
+}
+
+func TestGraphIndexQueries(t *testing.T) {
+	index := newTestGraphIndex(
+		Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true, FirstFollowsSecond: true, SecondFollowsFirst: true},
+		Friendship{FirstUser: "a", SecondUser: "c", IsFriend: true, FirstFollowsSecond: true, SecondFollowsFirst: true},
+		Friendship{FirstUser: "b", SecondUser: "c", IsFriend: true, FirstFollowsSecond: true, SecondFollowsFirst: true},
+		Friendship{FirstUser: "b", SecondUser: "d", SecondFollowsFirst: true},
+		Friendship{FirstUser: "a", SecondUser: "e", FirstBlocksSecond: true},
+		Friendship{FirstUser: "c", SecondUser: "e", IsFriend: true, FirstFollowsSecond: true, SecondFollowsFirst: true},
+		Friendship{FirstUser: "d", SecondUser: "f", IsFriend: true, FirstFollowsSecond: true, SecondFollowsFirst: true},
+	)
+
+	// Blocked pair is not a friend
+	assert.Equal(t, []string{"b", "c"}, index.Friends("a"))
+	assert.Equal(t, []string{"e"}, index.Blocked("a"))
+
+	// Follow only pair is not a friend
+	assert.Equal(t, []string{"a", "c"}, index.Friends("b"))
+
+	// Subscriber follows without being a friend
+	assert.Equal(t, []string{"a", "c", "d"}, index.Followers("b"))
+	assert.Equal(t, []string{"f"}, index.Followers("d"))
+
+	// Users in a blocked pair with one of them are not mutual friends
+	assert.Equal(t, []string{"c"}, index.MutualFriends("a", "b"))
+	assert.Equal(t, []string{"b"}, index.MutualFriends("a", "c"))
+	assert.Equal(t, []string{}, index.MutualFriends("a", "e"))
+
+	// Follow only pair is not a friendship
+	assert.Equal(t, []string{}, index.MutualFriends("b", "f"))
+
+	// Path follows friends only, a subscription or a block is not a link
+	assert.Equal(t, []string{"a", "c"}, index.Path("a", "c", 3))
+	assert.Equal(t, []string{"a", "c", "e"}, index.Path("a", "e", 3))
+	assert.Nil(t, index.Path("a", "d", 3))
+	assert.Nil(t, index.Path("a", "e", 1))
+	assert.Equal(t, []string{"a"}, index.Path("a", "a", 3))
+}
+
+func TestGraphIndexWrites(t *testing.T) {
+	index := newTestGraphIndex()
+	at := time.Now()
+
+	index.Set(Friendship{FirstUser: "a", SecondUser: "c", IsFriend: true}, at)
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true}, at)
+	assert.Equal(t, []string{"b", "c"}, index.Friends("a"))
+
+	// Older write applied late is ignored
+	index.Remove("b", "a", at.Add(time.Second))
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true}, at)
+	assert.Equal(t, []string{"c"}, index.Friends("a"))
+	assert.Equal(t, []string{}, index.Friends("b"))
+
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true}, at.Add(2*time.Second))
+	assert.Equal(t, []string{"b", "c"}, index.Friends("a"))
+
+	// Update keep the pair once in the neighbors
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", FirstBlocksSecond: true}, at.Add(3*time.Second))
+	assert.Equal(t, []string{"c"}, index.Friends("a"))
+	assert.Equal(t, []string{"b"}, index.Blocked("a"))
+}
+
+func TestGraphIndexLoad(t *testing.T) {
+	index := newTestGraphIndex()
+	at := time.Now()
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true}, at)
+	index.Set(Friendship{FirstUser: "a", SecondUser: "c", IsFriend: true}, at)
+	version := index.Version("a")
+
+	// Writes applied while the table is read are kept, rows which were not written since are replaced
+	index.beginLoad()
+	index.Set(Friendship{FirstUser: "a", SecondUser: "d", IsFriend: true}, at.Add(time.Second))
+	index.Remove("a", "b", at.Add(time.Second))
+	index.finishLoad([]Friendship{
+		{FirstUser: "a", SecondUser: "b", IsFriend: true},
+		{FirstUser: "a", SecondUser: "e", IsFriend: true},
+	}, true)
+	assert.Equal(t, []string{"d", "e"}, index.Friends("a"))
+	assert.NotEqual(t, version, index.Version("a"))
+
+	// Row keeps the time of the write of the index, an older write applied late is ignored
+	index.Set(Friendship{FirstUser: "a", SecondUser: "d", FirstBlocksSecond: true}, at)
+	assert.Equal(t, []string{"d", "e"}, index.Friends("a"))
+
+	// Failed load keeps the index
+	index.beginLoad()
+	index.finishLoad(nil, false)
+	assert.Equal(t, []string{"d", "e"}, index.Friends("a"))
+	assert.Nil(t, index.journal)
+}
+
+func TestGraphIndexPrune(t *testing.T) {
+	index := newTestGraphIndex()
+	at := time.Now()
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true}, at)
+	index.Remove("a", "b", at)
+	index.Remove("a", "c", at.Add(time.Minute))
+
+	// Only removed friendships written before the time are pruned
+	index.prune(at.Add(time.Second))
+	assert.Equal(t, 1, len(index.pairs))
+	index.Set(Friendship{FirstUser: "a", SecondUser: "b", IsFriend: true}, at)
+	index.Set(Friendship{FirstUser: "a", SecondUser: "c", IsFriend: true}, at)
+	assert.Equal(t, []string{"b"}, index.Friends("a"))
+
+	// Version of both users changes with each applied write only
+	version := index.Version("b")
+	index.Set(Friendship{FirstUser: "a", SecondUser: "c", IsFriend: true}, at)
+	assert.Equal(t, version, index.Version("b"))
+	index.Remove("a", "b", at.Add(time.Second))
+	assert.NotEqual(t, version, index.Version("b"))
+	assert.Equal(t, index.Version("a"), index.Version("b"))
+}
+
+func TestGraphIndexConsistency(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+
+	// Index is updated when writes are committed, so the users are not created in a test transaction
+	users, ok := insertUsersTest(dbconn, 3)
+	assert.Equal(t, true, ok)
+	defer func() {
+		dbconn.Unscoped().Where("first_user IN ? OR second_user IN ?", users, users).Delete(&Friendship{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.VerificationToken{})
+		dbconn.Unscoped().Where("email IN ?", users).Delete(&user.Users{})
+		dbconn.Where("email IN ?", users).Delete(&RelationshipVersion{})
+	}()
+
+	index := NewGraphIndex()
+	assert.NoError(t, index.Load(ctx, dbconn))
+	friendshipManager := NewFriendshipManager(dbconn).WithGraphIndex(index, ParseGraphQueries("friends,mutual_friends,followers"))
+
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	assert.NoError(t, friendshipManager.MakeFriend(ctx, FrienshipServiceInput{RequestEmail: users[1], TargetEmail: users[2]}))
+	assert.NoError(t, friendshipManager.Subscribe(ctx, FrienshipServiceInput{RequestEmail: users[2], TargetEmail: users[0]}))
+
+	// Index and SQL answer the same
+	sqlManager := NewFriendshipManager(dbconn)
+	for _, email := range users {
+		indexed, err := friendshipManager.GetFriendsList(ctx, user.Users{Email: email}, email)
+		assert.NoError(t, err)
+		queried, err := sqlManager.GetFriendsList(ctx, user.Users{Email: email}, email)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, queried, indexed)
+	}
+	indexed, err := friendshipManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}, users[0])
+	assert.NoError(t, err)
+	queried, err := sqlManager.GetMutualFriendsList(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[2]}, users[0])
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, queried, indexed)
+
+	report, err := index.Check(ctx, dbconn)
+	assert.NoError(t, err)
+	assert.True(t, report.Consistent())
+
+	// Write of another process is found by the check
+	assert.NoError(t, sqlManager.Block(ctx, FrienshipServiceInput{RequestEmail: users[0], TargetEmail: users[1]}))
+	report, err = index.Check(ctx, dbconn)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Mismatched)
+
+	assert.NoError(t, index.Load(ctx, dbconn))
+	report, err = index.Check(ctx, dbconn)
+	assert.NoError(t, err)
+	assert.True(t, report.Consistent())
+	assert.Equal(t, []string{users[1]}, index.Blocked(users[0]))
+}
@@ -0,0 +1,16 @@
+package health
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type HealthMockService struct {
+	mock.Mock
+}
+
+func (_m *HealthMockService) CheckReady(ctx context.Context) error {
+	args := _m.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+
+	migration "friend_connection_rest_api/migrations"
+
+	"gorm.io/gorm"
+)
+
+type HealthServices interface {
+	CheckReady(ctx context.Context) error
+}
+
+// HealthManager is the implementation of health service
+type HealthManager struct {
+	dbconn *gorm.DB
+}
+
+// NewHealthManager initializes health service
+func NewHealthManager(dbconn *gorm.DB) *HealthManager {
+	return &HealthManager{
+		dbconn: dbconn,
+	}
+}
+
+// CheckReady return an error when the database can not be reached or the migrations are not applied
+func (m *HealthManager) CheckReady(ctx context.Context) error {
+	sqlDB, err := m.dbconn.DB()
+	if err != nil {
+		return err
+	}
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return err
+	}
+	return migration.CheckMigration(m.dbconn.WithContext(ctx))
+}
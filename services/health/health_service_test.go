@@ -0,0 +1,26 @@
+package health
+
+import (
+	"context"
+	"testing"
+
+	migration "friend_connection_rest_api/migrations"
+	"friend_connection_rest_api/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReady(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	assert.NoError(t, migration.InitMigration(dbconn))
+
+	healthManager := NewHealthManager(dbconn)
+	assert.NoError(t, healthManager.CheckReady(ctx))
+
+	// Migration is not applied while the table is missing in the transaction
+	tx := dbconn.Begin()
+	defer tx.Rollback()
+	assert.NoError(t, tx.Migrator().DropTable("circle_members"))
+	assert.Equal(t, "Migration Not Applied", migration.CheckMigration(tx).Error())
+}
@@ -0,0 +1,16 @@
+package idempotency
+
+import (
+	"time"
+)
+
+// IdempotencyKey store the respone of a request sent with an Idempotency-Key header, only the hash of the key is stored.
+// Status is zero while the request is processed
+type IdempotencyKey struct {
+	KeyHash     string    `json:"-" gorm:"column:key_hash; primaryKey"`
+	Fingerprint string    `json:"-" gorm:"column:fingerprint"`
+	Status      int       `json:"status" gorm:"column:status"`
+	Body        []byte    `json:"-" gorm:"column:body"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"column:expires_at; index"`
+}
@@ -0,0 +1,27 @@
+package idempotency
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type IdempotencyMockService struct {
+	mock.Mock
+}
+
+func (_m *IdempotencyMockService) Begin(ctx context.Context, key string, fingerprint string) (*IdempotencyKey, error) {
+	args := _m.Called(ctx, key, fingerprint)
+	record, _ := args.Get(0).(*IdempotencyKey)
+	return record, args.Error(1)
+}
+
+func (_m *IdempotencyMockService) Complete(ctx context.Context, key string, status int, body []byte) error {
+	args := _m.Called(ctx, key, status, body)
+	return args.Error(0)
+}
+
+func (_m *IdempotencyMockService) Release(ctx context.Context, key string) error {
+	args := _m.Called(ctx, key)
+	return args.Error(0)
+}
@@ -0,0 +1,102 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"friend_connection_rest_api/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrKeyReused is returned when a key is sent again with another request
+	ErrKeyReused = errors.New("Idempotency Key Was Used With Another Request")
+	// ErrKeyInProgress is returned when a key is sent again while its first request is processed
+	ErrKeyInProgress = errors.New("Request With This Idempotency Key Is In Progress")
+)
+
+// processingTimeout is the time after which a key still processed is considered abandoned, e.g. the server stopped
+const processingTimeout = 10 * time.Minute
+
+type IdempotencyServices interface {
+	Begin(ctx context.Context, key string, fingerprint string) (*IdempotencyKey, error)
+	Complete(ctx context.Context, key string, status int, body []byte) error
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyManager is the implementation of idempotency service
+type IdempotencyManager struct {
+	dbconn *gorm.DB
+	ttl    time.Duration
+}
+
+// NewIdempotencyManager initializes idempotency service, keys can be used again after ttl
+func NewIdempotencyManager(dbconn *gorm.DB, ttl time.Duration) *IdempotencyManager {
+	return &IdempotencyManager{
+		dbconn: dbconn,
+		ttl:    ttl,
+	}
+}
+
+func (m *IdempotencyManager) withContext(ctx context.Context) *IdempotencyManager {
+	return &IdempotencyManager{
+		dbconn: m.dbconn.WithContext(ctx),
+		ttl:    m.ttl,
+	}
+}
+
+// Begin record the key for the request of fingerprint, it returns nil when the request has to be processed
+// or the stored respone of the first request with this key
+func (m *IdempotencyManager) Begin(ctx context.Context, key string, fingerprint string) (*IdempotencyKey, error) {
+	m = m.withContext(ctx)
+	now := time.Now()
+
+	// Expired and abandoned keys are removed so they can be used again
+	rs := m.dbconn.Where("expires_at < ? OR (status = 0 AND created_at < ?)", now, now.Add(-processingTimeout)).Delete(&IdempotencyKey{})
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+
+	record := IdempotencyKey{KeyHash: utils.HashToken(key), Fingerprint: fingerprint, CreatedAt: now, ExpiresAt: now.Add(m.ttl)}
+	rs = m.dbconn.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+	if rs.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	stored := IdempotencyKey{}
+	rs = m.dbconn.Where("key_hash = ?", record.KeyHash).First(&stored)
+	if errors.Is(rs.Error, gorm.ErrRecordNotFound) {
+		// First request was released in the meantime
+		return m.Begin(ctx, key, fingerprint)
+	}
+	if rs.Error != nil {
+		return nil, rs.Error
+	}
+
+	if stored.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if stored.Status == 0 {
+		return nil, ErrKeyInProgress
+	}
+	return &stored, nil
+}
+
+// Complete store the respone of the request of key, it is returned by Begin until the key expires
+func (m *IdempotencyManager) Complete(ctx context.Context, key string, status int, body []byte) error {
+	m = m.withContext(ctx)
+	return m.dbconn.Model(&IdempotencyKey{}).Where("key_hash = ?", utils.HashToken(key)).
+		Updates(map[string]interface{}{"status": status, "body": body}).Error
+}
+
+// Release remove the key of a request which failed, so it can be retried
+func (m *IdempotencyManager) Release(ctx context.Context, key string) error {
+	m = m.withContext(ctx)
+	return m.dbconn.Where("key_hash = ? AND status = 0", utils.HashToken(key)).Delete(&IdempotencyKey{}).Error
+}
@@ -0,0 +1,61 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"friend_connection_rest_api/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+	defer tx.Rollback()
+	assert.NoError(t, tx.AutoMigrate(&IdempotencyKey{}))
+
+	idempotencyMana := NewIdempotencyManager(tx, time.Hour)
+
+	// First request is processed
+	record, err := idempotencyMana.Begin(ctx, "key-1", "fingerprint-1")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	_, err = idempotencyMana.Begin(ctx, "key-1", "fingerprint-1")
+	assert.Equal(t, ErrKeyInProgress, err)
+
+	_, err = idempotencyMana.Begin(ctx, "key-1", "fingerprint-2")
+	assert.Equal(t, ErrKeyReused, err)
+
+	// Retry get the stored respone
+	assert.NoError(t, idempotencyMana.Complete(ctx, "key-1", http.StatusOK, []byte(`{"success":true}`)))
+	record, err = idempotencyMana.Begin(ctx, "key-1", "fingerprint-1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, http.StatusOK, record.Status)
+		assert.Equal(t, `{"success":true}`, string(record.Body))
+	}
+
+	// Completed key is not released
+	assert.NoError(t, idempotencyMana.Release(ctx, "key-1"))
+	record, _ = idempotencyMana.Begin(ctx, "key-1", "fingerprint-1")
+	assert.NotNil(t, record)
+
+	// Released key can be used again
+	_, err = idempotencyMana.Begin(ctx, "key-2", "fingerprint-1")
+	assert.NoError(t, err)
+	assert.NoError(t, idempotencyMana.Release(ctx, "key-2"))
+	record, err = idempotencyMana.Begin(ctx, "key-2", "fingerprint-2")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	// Expired key can be used again
+	tx.Model(&IdempotencyKey{}).Where("key_hash = ?", utils.HashToken("key-1")).Update("expires_at", time.Now().Add(-time.Minute))
+	record, err = idempotencyMana.Begin(ctx, "key-1", "fingerprint-2")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"friend_connection_rest_api/utils/logger"
+)
+
+// Mailer deliver a message to an email address
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// FileMailer is the implementation of Mailer for local runs,
+// messages are appended to a file. When no file is set only the recipient and the subject are logged,
+// the body is dropped because it has the verification token
+type FileMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileMailer initializes file mailer
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{
+		path: path,
+	}
+}
+
+func (m *FileMailer) Send(to string, subject string, body string) error {
+	if m.path == "" {
+		logger.Default().Info("email sent", "to", to, "subject", subject)
+		return nil
+	}
+
+	msg := fmt.Sprintf("Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), to, subject, body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(msg)
+	return err
+}
@@ -0,0 +1,14 @@
+package mailer
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+type MailerMock struct {
+	mock.Mock
+}
+
+func (_m *MailerMock) Send(to string, subject string, body string) error {
+	args := _m.Called(to, subject, body)
+	return args.Error(0)
+}
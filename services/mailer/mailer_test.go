@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"friend_connection_rest_api/utils/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "mail.log")
+	m := NewFileMailer(path)
+
+	assert.NoError(t, m.Send("first@gmail.com", "Hello", "first body"))
+	assert.NoError(t, m.Send("second@gmail.com", "Hello", "second body"))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "To: first@gmail.com"))
+	assert.True(t, strings.Contains(string(content), "second body"))
+}
+
+func TestFileMailerSendLog(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := logger.Default()
+	logger.SetDefault(logger.New(&buf, logger.LevelInfo, true))
+	defer logger.SetDefault(defaultLogger)
+
+	m := NewFileMailer("")
+	assert.NoError(t, m.Send("first@gmail.com", "Hello", "token secret"))
+
+	assert.True(t, strings.Contains(buf.String(), "Hello"))
+	assert.False(t, strings.Contains(buf.String(), "secret"))
+	assert.False(t, strings.Contains(buf.String(), "first@gmail.com"))
+}
@@ -0,0 +1,131 @@
+package unitofwork
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork run the checks and writes of a service method in one transaction
+type UnitOfWork interface {
+	Do(fn func(tx *gorm.DB) error) error
+}
+
+// GormUnitOfWork is the implementation of unit of work with gorm transactions.
+// A unit of work started with a transaction runs in a savepoint of it, so service methods
+// built on the transaction are composed into the larger transaction of the caller
+type GormUnitOfWork struct {
+	dbconn *gorm.DB
+}
+
+// NewGormUnitOfWork initializes unit of work
+func NewGormUnitOfWork(dbconn *gorm.DB) *GormUnitOfWork {
+	return &GormUnitOfWork{
+		dbconn: dbconn,
+	}
+}
+
+// committedError is an error returned by a unit of work whose writes are committed
+type committedError struct {
+	err error
+}
+
+func (e *committedError) Error() string {
+	return e.err.Error()
+}
+
+// Commit mark err as a result of the unit of work rather than a failure,
+// the writes are committed and Do returns err
+func Commit(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &committedError{err: err}
+}
+
+// Do commit the writes of fn when it returns nil or an error marked by Commit,
+// otherwise they are rolled back and the error of fn is returned unchanged
+func (u *GormUnitOfWork) Do(fn func(tx *gorm.DB) error) error {
+	var result error
+	var pool gorm.ConnPool
+	mark := 0
+	err := u.dbconn.Transaction(func(tx *gorm.DB) error {
+		pool = tx.Statement.ConnPool
+		mark = countHooks(pool)
+
+		err := fn(tx)
+		if committed, ok := err.(*committedError); ok {
+			result = committed.err
+			return nil
+		}
+		return err
+	})
+
+	if err != nil {
+		dropHooks(pool, mark)
+		return err
+	}
+
+	// Hooks of a nested unit of work wait for the transaction of the caller
+	if !inTransaction(u.dbconn) {
+		runHooks(pool)
+	}
+	return result
+}
+
+var (
+	hooksMutex sync.Mutex
+	// hooks registered by AfterCommit by transaction
+	hooks = map[gorm.ConnPool][]func(){}
+)
+
+// AfterCommit run fn when the transaction of tx is committed, fn is dropped when the unit of work
+// registering it is rolled back. It runs immediately when tx is not a transaction
+func AfterCommit(tx *gorm.DB, fn func()) {
+	if !inTransaction(tx) {
+		fn()
+		return
+	}
+
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	pool := tx.Statement.ConnPool
+	hooks[pool] = append(hooks[pool], fn)
+}
+
+func inTransaction(db *gorm.DB) bool {
+	committer, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok && committer != nil
+}
+
+func countHooks(pool gorm.ConnPool) int {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	return len(hooks[pool])
+}
+
+// dropHooks remove the hooks registered after mark
+func dropHooks(pool gorm.ConnPool, mark int) {
+	if pool == nil {
+		return
+	}
+
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	if mark == 0 {
+		delete(hooks, pool)
+	} else if len(hooks[pool]) > mark {
+		hooks[pool] = hooks[pool][:mark]
+	}
+}
+
+func runHooks(pool gorm.ConnPool) {
+	hooksMutex.Lock()
+	listHooks := hooks[pool]
+	delete(hooks, pool)
+	hooksMutex.Unlock()
+
+	for _, fn := range listHooks {
+		fn()
+	}
+}
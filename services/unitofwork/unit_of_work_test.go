@@ -0,0 +1,126 @@
+package unitofwork
+
+import (
+	"errors"
+	"testing"
+
+	"friend_connection_rest_api/utils"
+
+	randomData "github.com/Pallinder/go-randomdata"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// record is a table created only in the transaction of the test
+type record struct {
+	gorm.Model
+	Email string
+}
+
+func countRecords(tx *gorm.DB, email string) int64 {
+	var count int64
+	tx.Model(&record{}).Where("email = ?", email).Count(&count)
+	return count
+}
+
+func TestUnitOfWork(t *testing.T) {
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+	defer tx.Rollback()
+	assert.NoError(t, tx.Migrator().CreateTable(&record{}))
+
+	unitOfWork := NewGormUnitOfWork(tx)
+	anyError := errors.New("Any error")
+
+	// Writes are committed
+	committed := randomData.Email()
+	assert.NoError(t, unitOfWork.Do(func(tx *gorm.DB) error {
+		return tx.Create(&record{Email: committed}).Error
+	}))
+	assert.Equal(t, int64(1), countRecords(tx, committed))
+
+	// Writes are rolled back and the error is returned unchanged
+	rolledBack := randomData.Email()
+	err := unitOfWork.Do(func(tx *gorm.DB) error {
+		if err := tx.Create(&record{Email: rolledBack}).Error; err != nil {
+			return err
+		}
+		return anyError
+	})
+	assert.Equal(t, anyError, err)
+	assert.Equal(t, int64(0), countRecords(tx, rolledBack))
+
+	// Writes are committed and the marked error is returned
+	result := randomData.Email()
+	err = unitOfWork.Do(func(tx *gorm.DB) error {
+		if err := tx.Create(&record{Email: result}).Error; err != nil {
+			return err
+		}
+		return Commit(anyError)
+	})
+	assert.Equal(t, anyError, err)
+	assert.Equal(t, int64(1), countRecords(tx, result))
+
+	// Nested unit of work is rolled back alone, the outer one decide for all writes
+	outer, inner := randomData.Email(), randomData.Email()
+	err = unitOfWork.Do(func(tx *gorm.DB) error {
+		if err := tx.Create(&record{Email: outer}).Error; err != nil {
+			return err
+		}
+		err := NewGormUnitOfWork(tx).Do(func(tx *gorm.DB) error {
+			if err := tx.Create(&record{Email: inner}).Error; err != nil {
+				return err
+			}
+			return anyError
+		})
+		assert.Equal(t, anyError, err)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), countRecords(tx, outer))
+	assert.Equal(t, int64(0), countRecords(tx, inner))
+}
+
+func TestAfterCommit(t *testing.T) {
+	dbconn := utils.CreateConnection()
+	unitOfWork := NewGormUnitOfWork(dbconn)
+
+	listRun := []string{}
+	hook := func(name string) func() {
+		return func() { listRun = append(listRun, name) }
+	}
+
+	err := unitOfWork.Do(func(tx *gorm.DB) error {
+		AfterCommit(tx, hook("outer"))
+
+		// Hook of the nested unit of work rolled back is dropped
+		NewGormUnitOfWork(tx).Do(func(tx *gorm.DB) error {
+			AfterCommit(tx, hook("rolled back"))
+			return errors.New("Any error")
+		})
+
+		NewGormUnitOfWork(tx).Do(func(tx *gorm.DB) error {
+			AfterCommit(tx, hook("nested"))
+			return nil
+		})
+
+		// Hooks wait for the commit of the outer transaction
+		assert.Equal(t, []string{}, listRun)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer", "nested"}, listRun)
+
+	// Hooks of a rolled back transaction are dropped
+	listRun = []string{}
+	err = unitOfWork.Do(func(tx *gorm.DB) error {
+		AfterCommit(tx, hook("rolled back"))
+		return errors.New("Any error")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{}, listRun)
+
+	// Hook runs immediately without transaction
+	AfterCommit(dbconn, hook("immediate"))
+	assert.Equal(t, []string{"immediate"}, listRun)
+}
@@ -1,11 +1,63 @@
 package user
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Users struct {
 	gorm.Model
-	ID    uint64 `json:"id" gorm:"column:id; primaryKey"`
-	Email string `json:"email" gorm:"column:email; index:unique"`
+	ID       uint64 `json:"id" gorm:"column:id; primaryKey"`
+	Email    string `json:"email" gorm:"column:email; index:unique"`
+	Verified bool   `json:"verified" gorm:"column:verified; default:false"`
+}
+
+// VerificationToken is issued on user creation, only the hash of the token is stored
+type VerificationToken struct {
+	gorm.Model
+	Email     string    `json:"email" gorm:"column:email; index"`
+	TokenHash string    `json:"-" gorm:"column:token_hash; uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"column:expires_at"`
+}
+
+// AccessToken is issued when user confirm a verification token, only the hash of the token is stored
+type AccessToken struct {
+	gorm.Model
+	Email     string    `json:"email" gorm:"column:email; index"`
+	TokenHash string    `json:"-" gorm:"column:token_hash; uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"column:expires_at"`
+}
+
+const (
+	VisibilityEveryone = "everyone"
+	VisibilityFriends  = "friends"
+	VisibilityOnlyMe   = "only_me"
+)
+
+const (
+	FriendRequestEveryone         = "everyone"
+	FriendRequestFriendsOfFriends = "friends_of_friends"
+	FriendRequestNobody           = "nobody"
+)
+
+// PrivacySetting of an user, user has no setting use DefaultPrivacySetting
+type PrivacySetting struct {
+	gorm.Model
+	Email                   string `json:"email" gorm:"column:email; uniqueIndex"`
+	FriendList              string `json:"friend_list" gorm:"column:friend_list"`
+	MutualFriends           string `json:"mutual_friends" gorm:"column:mutual_friends"`
+	FriendRequest           string `json:"friend_request" gorm:"column:friend_request"`
+	NotifyNonFriendMentions bool   `json:"notify_non_friend_mentions" gorm:"column:notify_non_friend_mentions"`
+}
+
+// DefaultPrivacySetting keep everything public
+func DefaultPrivacySetting(email string) PrivacySetting {
+	return PrivacySetting{
+		Email:                   email,
+		FriendList:              VisibilityEveryone,
+		MutualFriends:           VisibilityEveryone,
+		FriendRequest:           FriendRequestEveryone,
+		NotifyNonFriendMentions: true,
+	}
 }
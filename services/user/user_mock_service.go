@@ -1,6 +1,8 @@
 package user
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -8,11 +10,35 @@ type UserMockService struct {
 	mock.Mock
 }
 
-func (_m *UserMockService) CreateNewUser(userMail Users) error {
-	args := _m.Called(userMail)
+func (_m *UserMockService) CreateNewUser(ctx context.Context, userMail Users) error {
+	args := _m.Called(ctx, userMail)
 	return args.Error(0)
 }
-func (_m *UserMockService) GetListUser() ([]string, error) {
-	args := _m.Called()
+func (_m *UserMockService) GetListUser(ctx context.Context, viewer string) ([]string, error) {
+	args := _m.Called(ctx, viewer)
 	return args.Get(0).([]string), args.Error(1)
 }
+func (_m *UserMockService) VerifyUser(ctx context.Context, email string, token string) (string, error) {
+	args := _m.Called(ctx, email, token)
+	return args.String(0), args.Error(1)
+}
+
+func (_m *UserMockService) Login(ctx context.Context, email string) error {
+	args := _m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (_m *UserMockService) Authenticate(ctx context.Context, accessToken string) (string, error) {
+	args := _m.Called(ctx, accessToken)
+	return args.String(0), args.Error(1)
+}
+
+func (_m *UserMockService) GetPrivacySetting(ctx context.Context, email string) (PrivacySetting, error) {
+	args := _m.Called(ctx, email)
+	return args.Get(0).(PrivacySetting), args.Error(1)
+}
+
+func (_m *UserMockService) UpdatePrivacySetting(ctx context.Context, setting PrivacySetting) error {
+	args := _m.Called(ctx, setting)
+	return args.Error(0)
+}
@@ -1,53 +1,289 @@
 package user
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
+
+	"friend_connection_rest_api/services/mailer"
+	"friend_connection_rest_api/services/unitofwork"
+	"friend_connection_rest_api/utils"
+	"friend_connection_rest_api/utils/logger"
 
 	"gorm.io/gorm"
 )
 
+const (
+	verificationTokenSize = 32
+	verificationTokenTTL  = 24 * time.Hour
+	accessTokenTTL        = 30 * 24 * time.Hour
+)
+
 type UserService interface {
-	CreateNewUser(userMail Users) error
-	GetListUser() ([]string, error)
+	CreateNewUser(ctx context.Context, userMail Users) error
+	GetListUser(ctx context.Context, viewer string) ([]string, error)
+	VerifyUser(ctx context.Context, email string, token string) (string, error)
+	Login(ctx context.Context, email string) error
+	Authenticate(ctx context.Context, accessToken string) (string, error)
+	GetPrivacySetting(ctx context.Context, email string) (PrivacySetting, error)
+	UpdatePrivacySetting(ctx context.Context, setting PrivacySetting) error
 }
 
 type UserRepo interface {
 }
 
+// VerifyHook is called in the transaction verifying the email address of a new user
+type VerifyHook func(tx *gorm.DB, email string) error
+
 type UserManager struct {
-	dbconn *gorm.DB
+	dbconn      *gorm.DB
+	mailer      mailer.Mailer
+	verifyHooks []VerifyHook
 }
 
 func NewUserManager(dbconn *gorm.DB) *UserManager {
 	return &UserManager{
 		dbconn: dbconn,
+		mailer: mailer.NewFileMailer(""),
 	}
 }
 
-func (m *UserManager) CreateNewUser(userMail Users) error {
+// WithMailer set the mailer used to deliver verification token
+func (m *UserManager) WithMailer(ml mailer.Mailer) *UserManager {
+	m.mailer = ml
+	return m
+}
+
+// WithVerifyHook register a hook will be called when a new user confirms the email address
+func (m *UserManager) WithVerifyHook(hook VerifyHook) *UserManager {
+	m.verifyHooks = append(m.verifyHooks, hook)
+	return m
+}
+
+// withContext return a manager whose queries are bound to ctx, the mailer and hooks are kept
+func (m *UserManager) withContext(ctx context.Context) *UserManager {
+	return &UserManager{dbconn: m.dbconn.WithContext(ctx), mailer: m.mailer, verifyHooks: m.verifyHooks}
+}
+
+// transaction run fn in a unit of work composed into the transaction of the manager if any
+func (m *UserManager) transaction(fn func(txManager *UserManager) error) error {
+	return unitofwork.NewGormUnitOfWork(m.dbconn).Do(func(tx *gorm.DB) error {
+		return fn(&UserManager{dbconn: tx, mailer: m.mailer, verifyHooks: m.verifyHooks})
+	})
+}
+
+func (m *UserManager) CreateNewUser(ctx context.Context, userMail Users) error {
+	m = m.withContext(ctx)
 
 	emailAddress := userMail.Email
 
-	IsExist, err := m.CheckUserExist([]string{emailAddress})
+	// User is created unverified, the token is delivered in the same transaction
+	userMail.Verified = false
+	return m.transaction(func(txManager *UserManager) error {
+		IsExist, err := txManager.CheckUserExist([]string{emailAddress})
+		if err != nil {
+			return err
+		}
+
+		if IsExist == true {
+			return errors.New("User is already exists!")
+		}
+
+		tx := txManager.dbconn
+		rs := tx.Create(&userMail)
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		if err := txManager.issueVerificationToken(tx, emailAddress); err != nil {
+			return err
+		}
+		unitofwork.AfterCommit(tx, func() {
+			logger.FromContext(ctx).Info("user created", "email", emailAddress)
+		})
+		return nil
+	})
+}
+
+// issueVerificationToken store the hash of a new token and send the token to user
+func (m *UserManager) issueVerificationToken(tx *gorm.DB, email string) error {
+	token, err := utils.GenerateToken(verificationTokenSize)
 	if err != nil {
 		return err
 	}
 
-	if IsExist == true {
-		return errors.New("User is already exists!")
+	verification := VerificationToken{
+		Email:     email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(verificationTokenTTL),
 	}
 
-	rs := m.dbconn.Create(&userMail)
+	rs := tx.Create(&verification)
 	if rs.Error != nil {
 		return rs.Error
 	}
-	return nil
+
+	body := fmt.Sprintf("Your verification token is: %s\nIt will expire at %s.", token, verification.ExpiresAt.Format(time.RFC1123Z))
+	return m.mailer.Send(email, "Verify your email address", body)
+}
+
+// VerifyUser confirm the email address of user with the token was sent and return an access token
+func (m *UserManager) VerifyUser(ctx context.Context, email string, token string) (string, error) {
+	m = m.withContext(ctx)
+
+	accessToken, err := utils.GenerateToken(verificationTokenSize)
+	if err != nil {
+		return "", err
+	}
+
+	err = unitofwork.NewGormUnitOfWork(m.dbconn).Do(func(tx *gorm.DB) error {
+		verification := VerificationToken{}
+		rs := tx.Where("email = ? AND token_hash = ?", email, utils.HashToken(token)).Limit(1).Find(&verification)
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		if rs.RowsAffected <= 0 {
+			return errors.New("Invalid Verification Token")
+		}
+
+		if time.Now().After(verification.ExpiresAt) {
+			return errors.New("Verification Token Expired")
+		}
+
+		rs = tx.Model(&Users{}).Where("email = ? AND verified = ?", email, false).Update("verified", true)
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		// Hooks run once, a token sent by Login is exchanged by an user already verified
+		if rs.RowsAffected > 0 {
+			for _, hook := range m.verifyHooks {
+				if err := hook(tx, email); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Token can be used only one time
+		rs = tx.Unscoped().Where("email = ?", email).Delete(&VerificationToken{})
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		rs = tx.Create(&AccessToken{Email: email, TokenHash: utils.HashToken(accessToken), ExpiresAt: time.Now().Add(accessTokenTTL)})
+		if rs.Error != nil {
+			return rs.Error
+		}
+		unitofwork.AfterCommit(tx, func() {
+			logger.FromContext(ctx).Info("user verified", "email", email)
+		})
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+	return accessToken, nil
+}
+
+// Login send a new verification token to a registered user, it is exchanged for an access token by VerifyUser
+func (m *UserManager) Login(ctx context.Context, email string) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *UserManager) error {
+		IsExist, err := txManager.CheckUserExist([]string{email})
+		if err != nil {
+			return err
+		}
+
+		if IsExist == false {
+			return errors.New("User Not Exist")
+		}
+
+		return txManager.issueVerificationToken(txManager.dbconn, email)
+	})
+}
+
+// Authenticate return the email of the owner of an access token
+func (m *UserManager) Authenticate(ctx context.Context, accessToken string) (string, error) {
+	m = m.withContext(ctx)
+
+	token := AccessToken{}
+	rs := m.dbconn.Where("token_hash = ?", utils.HashToken(accessToken)).Limit(1).Find(&token)
+	if rs.Error != nil {
+		return "", rs.Error
+	}
+
+	if rs.RowsAffected <= 0 || time.Now().After(token.ExpiresAt) {
+		return "", errors.New("Invalid Access Token")
+	}
+	return token.Email, nil
+}
+
+// GetPrivacySetting return privacy setting of user or the default setting when user has not set it
+func (m *UserManager) GetPrivacySetting(ctx context.Context, email string) (PrivacySetting, error) {
+	m = m.withContext(ctx)
+
+	setting := PrivacySetting{}
+	rs := m.dbconn.Where("email = ?", email).Limit(1).Find(&setting)
+	if rs.Error != nil {
+		return PrivacySetting{}, rs.Error
+	}
+
+	if rs.RowsAffected <= 0 {
+		return DefaultPrivacySetting(email), nil
+	}
+	return setting, nil
+}
+
+func (m *UserManager) UpdatePrivacySetting(ctx context.Context, setting PrivacySetting) error {
+	m = m.withContext(ctx)
+
+	return m.transaction(func(txManager *UserManager) error {
+		IsExist, err := txManager.CheckUserExist([]string{setting.Email})
+		if err != nil {
+			return err
+		}
+
+		if IsExist == false {
+			return errors.New("User Not Exist")
+		}
+
+		tx := txManager.dbconn
+		current := PrivacySetting{}
+		rs := tx.Where("email = ?", setting.Email).Limit(1).Find(&current)
+		if rs.Error != nil {
+			return rs.Error
+		}
+
+		if rs.RowsAffected <= 0 {
+			rs = tx.Create(&setting)
+			return rs.Error
+		}
+
+		// Select is needed so false value is updated
+		rs = tx.Model(&current).Select("friend_list", "mutual_friends", "friend_request", "notify_non_friend_mentions").Updates(&setting)
+		return rs.Error
+	})
 }
 
-func (m *UserManager) GetListUser() ([]string, error) {
+// GetListUser return all users, users have a block with viewer are hidden when viewer is set
+func (m *UserManager) GetListUser(ctx context.Context, viewer string) ([]string, error) {
+	m = m.withContext(ctx)
+
 	listUser := []string{}
 
-	rs := m.dbconn.Select("email").Find(&Users{}).Scan(&listUser)
+	query := m.dbconn.Select("email")
+	if viewer != "" {
+		query = query.Where(`email NOT IN (SELECT second_user FROM friendships
+				WHERE first_user = @viewer AND (first_blocks_second = true OR second_blocks_first = true) AND deleted_at IS NULL
+			UNION SELECT first_user FROM friendships
+				WHERE second_user = @viewer AND (first_blocks_second = true OR second_blocks_first = true) AND deleted_at IS NULL)`, map[string]interface{}{"viewer": viewer})
+	}
+
+	rs := query.Find(&Users{}).Scan(&listUser)
 
 	if rs.Error != nil {
 		return nil, rs.Error
@@ -71,3 +307,16 @@ func (m *UserManager) CheckUserExist(emailAddress []string) (bool, error) {
 		return false, nil
 	}
 }
+
+// CheckUserVerified return true when all users confirmed their email address
+func (m *UserManager) CheckUserVerified(emailAddress []string) (bool, error) {
+
+	var count int
+
+	rs := m.dbconn.Select("COUNT(*)").Where("email IN ? AND verified = ?", emailAddress, true).Find(&Users{}).Scan(&count)
+
+	if rs.Error != nil {
+		return false, rs.Error
+	}
+	return count == len(emailAddress), nil
+}
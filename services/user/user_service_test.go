@@ -1,16 +1,21 @@
 package user
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 
+	"friend_connection_rest_api/services/mailer"
 	"friend_connection_rest_api/utils"
 
 	randomData "github.com/Pallinder/go-randomdata"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestCreateNewUser(t *testing.T) {
+	ctx := context.Background()
 	const numsUser int = 1
 	listUsers := []Users{}
 	for i := 0; i < numsUser; i++ {
@@ -41,30 +46,153 @@ func TestCreateNewUser(t *testing.T) {
 
 	for _, tc := range tcs {
 		t.Run(tc.scenario, func(t *testing.T) {
-			actualRs := userMana.CreateNewUser(tc.mockInput)
+			actualRs := userMana.CreateNewUser(ctx, tc.mockInput)
 			assert.Equal(t, tc.expectedError, actualRs)
 		})
 	}
 }
 
 func TestGetListUserSuccess(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	userMana := NewUserManager(dbconn)
 
-	actualRs, _ := userMana.GetListUser()
+	actualRs, _ := userMana.GetListUser(ctx, "")
 	assert.NotNil(t, actualRs)
 }
 
 func TestCheckUserExist(t *testing.T) {
+	ctx := context.Background()
 	dbconn := utils.CreateConnection()
 	tx := dbconn.Begin()
 
 	user := Users{Email: randomData.Email()}
 
 	userMana := NewUserManager(tx)
-	assert.NoError(t, userMana.CreateNewUser(user))
+	assert.NoError(t, userMana.CreateNewUser(ctx, user))
 
 	actualRs, err := userMana.CheckUserExist([]string{user.Email})
 	assert.Equal(t, true, actualRs)
 	assert.Nil(t, err)
 }
+
+func TestVerifyUser(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	email := randomData.Email()
+
+	// Capture the token was sent to user
+	var token string
+	mailerMock := new(mailer.MailerMock)
+	mailerMock.On("Send", email, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		body := args.String(2)
+		token = strings.TrimSpace(strings.Split(strings.Split(body, ":")[1], "\n")[0])
+	}).Return(nil)
+
+	userMana := NewUserManager(tx).WithMailer(mailerMock)
+	assert.NoError(t, userMana.CreateNewUser(ctx, Users{Email: email}))
+
+	verified, err := userMana.CheckUserVerified([]string{email})
+	assert.Nil(t, err)
+	assert.Equal(t, false, verified)
+
+	tcs := []struct {
+		scenario      string
+		mockToken     string
+		expectedError error
+	}{
+		{
+			scenario:      "Invalid token",
+			mockToken:     "invalidtoken",
+			expectedError: errors.New("Invalid Verification Token"),
+		},
+		{
+			scenario:      "success",
+			mockToken:     token,
+			expectedError: nil,
+		},
+		{
+			scenario:      "Token was used",
+			mockToken:     token,
+			expectedError: errors.New("Invalid Verification Token"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.scenario, func(t *testing.T) {
+			accessToken, actualRs := userMana.VerifyUser(ctx, email, tc.mockToken)
+			assert.Equal(t, tc.expectedError, actualRs)
+			if tc.expectedError == nil {
+				owner, err := userMana.Authenticate(ctx, accessToken)
+				assert.Nil(t, err)
+				assert.Equal(t, email, owner)
+			}
+		})
+	}
+
+	verified, err = userMana.CheckUserVerified([]string{email})
+	assert.Nil(t, err)
+	assert.Equal(t, true, verified)
+}
+
+func TestAuthenticateInvalidToken(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	userMana := NewUserManager(dbconn)
+
+	_, err := userMana.Authenticate(ctx, "invalidtoken")
+	assert.Equal(t, errors.New("Invalid Access Token"), err)
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	email := randomData.Email()
+	mailerMock := new(mailer.MailerMock)
+	mailerMock.On("Send", email, mock.Anything, mock.Anything).Return(nil)
+
+	userMana := NewUserManager(tx).WithMailer(mailerMock)
+	assert.Equal(t, errors.New("User Not Exist"), userMana.Login(ctx, email))
+
+	assert.NoError(t, userMana.CreateNewUser(ctx, Users{Email: email}))
+	assert.NoError(t, userMana.Login(ctx, email))
+	mailerMock.AssertNumberOfCalls(t, "Send", 2)
+}
+
+func TestPrivacySetting(t *testing.T) {
+	ctx := context.Background()
+	dbconn := utils.CreateConnection()
+	tx := dbconn.Begin()
+
+	email := randomData.Email()
+	userMana := NewUserManager(tx)
+	assert.NoError(t, userMana.CreateNewUser(ctx, Users{Email: email}))
+
+	setting, err := userMana.GetPrivacySetting(ctx, email)
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultPrivacySetting(email), setting)
+
+	for _, notify := range []bool{false, true} {
+		expected := PrivacySetting{
+			Email:                   email,
+			FriendList:              VisibilityOnlyMe,
+			MutualFriends:           VisibilityFriends,
+			FriendRequest:           FriendRequestNobody,
+			NotifyNonFriendMentions: notify,
+		}
+		assert.NoError(t, userMana.UpdatePrivacySetting(ctx, expected))
+
+		setting, err = userMana.GetPrivacySetting(ctx, email)
+		assert.Nil(t, err)
+		assert.Equal(t, expected.FriendList, setting.FriendList)
+		assert.Equal(t, expected.MutualFriends, setting.MutualFriends)
+		assert.Equal(t, expected.FriendRequest, setting.FriendRequest)
+		assert.Equal(t, notify, setting.NotifyNonFriendMentions)
+	}
+
+	assert.Equal(t, errors.New("User Not Exist"), userMana.UpdatePrivacySetting(ctx, DefaultPrivacySetting("usernotexist@notfound.com")))
+}
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"context"
+
+	"friend_connection_rest_api/utils/tracing"
+)
+
+// UserTracing trace each method of an user service, the spans of its queries are children of the method span
+type UserTracing struct {
+	next UserService
+}
+
+// NewUserTracing initializes user service tracing the methods of next
+func NewUserTracing(next UserService) *UserTracing {
+	return &UserTracing{
+		next: next,
+	}
+}
+
+func (t *UserTracing) CreateNewUser(ctx context.Context, userMail Users) error {
+	ctx, span := tracing.StartSpan(ctx, "UserService.CreateNewUser", tracing.Email("user.email", userMail.Email))
+	err := t.next.CreateNewUser(ctx, userMail)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+func (t *UserTracing) GetListUser(ctx context.Context, viewer string) ([]string, error) {
+	ctx, span := tracing.StartSpan(ctx, "UserService.GetListUser", tracing.Email("user.viewer", viewer))
+	rs, err := t.next.GetListUser(ctx, viewer)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *UserTracing) VerifyUser(ctx context.Context, email string, token string) (string, error) {
+	ctx, span := tracing.StartSpan(ctx, "UserService.VerifyUser", tracing.Email("user.email", email))
+	rs, err := t.next.VerifyUser(ctx, email, token)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *UserTracing) Login(ctx context.Context, email string) error {
+	ctx, span := tracing.StartSpan(ctx, "UserService.Login", tracing.Email("user.email", email))
+	err := t.next.Login(ctx, email)
+	tracing.EndSpan(span, err)
+	return err
+}
+
+// Authenticate does not record the access token
+func (t *UserTracing) Authenticate(ctx context.Context, accessToken string) (string, error) {
+	ctx, span := tracing.StartSpan(ctx, "UserService.Authenticate")
+	rs, err := t.next.Authenticate(ctx, accessToken)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *UserTracing) GetPrivacySetting(ctx context.Context, email string) (PrivacySetting, error) {
+	ctx, span := tracing.StartSpan(ctx, "UserService.GetPrivacySetting", tracing.Email("user.email", email))
+	rs, err := t.next.GetPrivacySetting(ctx, email)
+	tracing.EndSpan(span, err)
+	return rs, err
+}
+
+func (t *UserTracing) UpdatePrivacySetting(ctx context.Context, setting PrivacySetting) error {
+	ctx, span := tracing.StartSpan(ctx, "UserService.UpdatePrivacySetting", tracing.Email("user.email", setting.Email))
+	err := t.next.UpdatePrivacySetting(ctx, setting)
+	tracing.EndSpan(span, err)
+	return err
+}
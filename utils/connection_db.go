@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 
+	"friend_connection_rest_api/utils/logger"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -21,11 +23,12 @@ func CreateConnection() *gorm.DB {
 	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
 		"password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
-	db, err := gorm.Open(postgres.Open(psqlInfo), &gorm.Config{SkipDefaultTransaction: true})
+	// Queries are logged with the logger of the request context
+	db, err := gorm.Open(postgres.Open(psqlInfo), &gorm.Config{SkipDefaultTransaction: true, Logger: logger.NewGormLogger(logger.Default())})
 
 	if err != nil {
 		panic(err)
 	}
-	fmt.Println("Connect to database successfully")
+	logger.Default().Info("connected to database", "host", host, "dbname", dbname)
 	return db
 }
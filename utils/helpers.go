@@ -2,10 +2,15 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 
 	"encoding/hex"
 	"io/ioutil"
+	"os"
 	"regexp"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -34,6 +39,39 @@ func GetMD5Hash(text string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// GenerateToken return a cryptographically random token encoded in hex
+func GenerateToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken return the hash of a token, only the hash is stored in database
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetEnvInt return the integer value of an environment variable or the default value when it is not set
+func GetEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetEnvDuration return the duration value of an environment variable, e.g. "30s", or the default value when it is not set
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 // LoadFixture will load and execute SQL queries from fixture file
 func LoadFixture(tx *gorm.DB, fixturePath string, rollBackName string) error {
 	if fixturePath != "" {
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration a query is logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger write the logs of gorm with the logger of the query context, so they have the request ID.
+// Queries are logged at debug level, slow queries at warn level and failed queries at error level
+type GormLogger struct {
+	logger *Logger
+}
+
+// NewGormLogger initializes gorm logger, logger is used when the query context has none
+func NewGormLogger(logger *Logger) *GormLogger {
+	return &GormLogger{logger: logger}
+}
+
+func (g *GormLogger) from(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return g.logger
+}
+
+// LogMode is ignored, the level of the logger is used
+func (g *GormLogger) LogMode(gormLogger.LogLevel) gormLogger.Interface {
+	return g
+}
+
+func (g *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	g.from(ctx).Info(fmt.Sprintf(msg, data...))
+}
+
+func (g *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	g.from(ctx).Warn(fmt.Sprintf(msg, data...))
+}
+
+func (g *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	g.from(ctx).Error(fmt.Sprintf(msg, data...))
+}
+
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	logger := g.from(ctx)
+	elapsed := time.Since(begin)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.Error("query failed", "sql", sql, "rows", rows, "latency_ms", elapsed.Milliseconds(), "error", err)
+	case elapsed > slowQueryThreshold && logger.Enabled(LevelWarn):
+		sql, rows := fc()
+		logger.Warn("slow query", "sql", sql, "rows", rows, "latency_ms", elapsed.Milliseconds())
+	case logger.Enabled(LevelDebug):
+		sql, rows := fc()
+		logger.Debug("query", "sql", sql, "rows", rows, "latency_ms", elapsed.Milliseconds())
+	}
+}
@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "INFO"
+}
+
+// ParseLevel return the level of its name, e.g. "debug", or LevelInfo when the name is unknown
+func ParseLevel(name string) Level {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug
+	case "warn", "warning":
+		return LevelWarn
+	case "error":
+		return LevelError
+	}
+	return LevelInfo
+}
+
+var emailRegex = regexp.MustCompile(`[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+`)
+
+// RedactEmails replace email addresses by a short hash, the same address always give the same hash
+// so entries of one user can still be correlated
+func RedactEmails(text string) string {
+	return emailRegex.ReplaceAllStringFunc(text, func(email string) string {
+		hash := sha256.Sum256([]byte(strings.ToLower(email)))
+		return "email:" + hex.EncodeToString(hash[:4])
+	})
+}
+
+// output is shared by a logger and the loggers derived from it
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Logger write one JSON object by entry with the message, the level and key/value attributes
+type Logger struct {
+	out    *output
+	level  Level
+	redact bool
+	attrs  []interface{}
+}
+
+// New initializes logger, email addresses in messages and values are redacted when redact is true
+func New(w io.Writer, level Level, redact bool) *Logger {
+	return &Logger{
+		out:    &output{w: w},
+		level:  level,
+		redact: redact,
+	}
+}
+
+// NewFromEnv initializes logger writing to stdout with LOG_LEVEL (default info),
+// email addresses are redacted unless LOG_REDACT_EMAILS is false
+func NewFromEnv() *Logger {
+	return New(os.Stdout, ParseLevel(os.Getenv("LOG_LEVEL")), os.Getenv("LOG_REDACT_EMAILS") != "false")
+}
+
+// With return a logger adding key/values to each entry
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	attrs := make([]interface{}, 0, len(l.attrs)+len(keyvals))
+	attrs = append(append(attrs, l.attrs...), keyvals...)
+	return &Logger{out: l.out, level: l.level, redact: l.redact, attrs: attrs}
+}
+
+// Enabled return true when entries of level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(LevelDebug, msg, keyvals...)
+}
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(LevelInfo, msg, keyvals...)
+}
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(LevelWarn, msg, keyvals...)
+}
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(LevelError, msg, keyvals...)
+}
+
+// Log write an entry when level is enabled, keyvals are pairs of a string key and a value
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	l.writeValue(&b, time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	l.writeValue(&b, level.String())
+	b.WriteString(`,"msg":`)
+	l.writeValue(&b, msg)
+
+	attrs := append(append([]interface{}{}, l.attrs...), keyvals...)
+	for i := 0; i < len(attrs); i += 2 {
+		key, ok := attrs[i].(string)
+		if !ok {
+			key = fmt.Sprint(attrs[i])
+		}
+		var value interface{} = "!MISSING"
+		if i+1 < len(attrs) {
+			value = attrs[i+1]
+		}
+		b.WriteString(",")
+		l.writeValue(&b, key)
+		b.WriteString(":")
+		l.writeValue(&b, value)
+	}
+	b.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	io.WriteString(l.out.w, b.String())
+}
+
+// writeValue encode value as JSON, errors, stringers and values can not be encoded are written as strings
+func (l *Logger) writeValue(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		value = l.redactString(v)
+	case error:
+		value = l.redactString(v.Error())
+	case time.Duration:
+		value = v.String()
+	case fmt.Stringer:
+		value = l.redactString(v.String())
+	case []string:
+		list := make([]string, len(v))
+		for i := range v {
+			list[i] = l.redactString(v[i])
+		}
+		value = list
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(l.redactString(fmt.Sprint(value)))
+	}
+	b.Write(encoded)
+}
+
+func (l *Logger) redactString(text string) string {
+	if !l.redact {
+		return text
+	}
+	return RedactEmails(text)
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = NewFromEnv()
+)
+
+// Default return the logger used when the context has none
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+// SetDefault replace the default logger
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
+
+type contextKey struct{}
+
+// WithContext return a context carrying l, it is used by the services and the queries of the request
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext return the logger of ctx or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
+	}
+	return Default()
+}
@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, LevelInfo, true).With("request_id", "abc")
+
+	l.Debug("not written")
+	l.Info("user created", "email", "abc@gmail.com", "count", 2, "error", errors.New("User abc@gmail.com Not Exist"))
+
+	redacted := RedactEmails("abc@gmail.com")
+	assert.Regexp(t, `^email:[0-9a-f]{8}$`, redacted)
+	assert.Equal(t, redacted, RedactEmails("ABC@gmail.com"))
+	assert.Regexp(t, `^\{"time":"[^"]+","level":"INFO","msg":"user created","request_id":"abc","email":"`+redacted+`","count":2,"error":"User `+redacted+` Not Exist"\}\n$`, out.String())
+
+	// Email addresses are kept when redaction is disabled
+	out.Reset()
+	New(&out, LevelDebug, false).Debug("query", "sql", "SELECT * FROM users WHERE email = 'abc@gmail.com'")
+	assert.Contains(t, out.String(), `"level":"DEBUG"`)
+	assert.Contains(t, out.String(), "abc@gmail.com")
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, LevelDebug, ParseLevel("debug"))
+	assert.Equal(t, LevelWarn, ParseLevel("WARN"))
+	assert.Equal(t, LevelError, ParseLevel("error"))
+	assert.Equal(t, LevelInfo, ParseLevel(""))
+}
+
+func TestFromContext(t *testing.T) {
+	l := New(&bytes.Buffer{}, LevelInfo, true)
+	assert.Equal(t, l, FromContext(WithContext(context.Background(), l)))
+	assert.Equal(t, Default(), FromContext(context.Background()))
+}
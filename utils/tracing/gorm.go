@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey is the key of the span of a query in the gorm statement
+const spanKey = "tracing:span"
+
+// RegisterDB start a span for every query of db, it is a child of the span of the query context.
+// The statement is recorded with its placeholders, the values are not
+func RegisterDB(db *gorm.DB) error {
+	callback := db.Callback()
+	registers := []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", startQuery("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", endQuery),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", startQuery("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", endQuery),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", startQuery("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", endQuery),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuery("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", endQuery),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", startQuery("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", endQuery),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", startQuery("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", endQuery),
+	}
+	for _, err := range registers {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startQuery(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+
+		_, span := Tracer().Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, attribute.String("db.operation", operation)))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endQuery(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	span.SetAttributes(
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		semconv.DBSQLTableKey.String(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+
+	var err error
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		err = db.Error
+	}
+	EndSpan(span, err)
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"friend_connection_rest_api/utils/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer of the spans of this service
+const instrumentationName = "friend_connection_rest_api"
+
+// ServiceName is the service.name resource of the spans
+const ServiceName = "friend-connection-rest-api"
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// NewExporter return the span exporter of kind, it is nil for ExporterNone or an empty kind.
+// The OTLP exporter sends to OTEL_EXPORTER_OTLP_ENDPOINT over HTTP
+func NewExporter(ctx context.Context, kind string) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	return nil, errors.New("Tracing Exporter Invalid")
+}
+
+// Setup register a tracer provider sending spans to exporter and the W3C trace context propagator,
+// spans are exported in batch unless sync is true, which is used by tests reading an in-memory exporter
+func Setup(exporter sdktrace.SpanExporter, sync bool) *sdktrace.TracerProvider {
+	processor := sdktrace.WithBatcher(exporter)
+	if sync {
+		processor = sdktrace.WithSyncer(exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider
+}
+
+// Tracer return the tracer of this service from the registered provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartSpan start a span as a child of the span of ctx
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan record err on span and end it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Email return an attribute of an email address, it is redacted the same way as in the logs
+func Email(key string, email string) attribute.KeyValue {
+	return attribute.String(key, logger.RedactEmails(email))
+}